@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"html/template"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"shift-change-app/internal/database"
 	"shift-change-app/internal/handler"
+	"shift-change-app/internal/outbox"
 	"shift-change-app/internal/router"
+	"shift-change-app/internal/worker"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -18,6 +26,9 @@ import (
 	"github.com/line/line-bot-sdk-go/v7/linebot"
 )
 
+// SHUTDOWN_TIMEOUT が未設定・不正なときに停止を待つ時間（Render は SIGTERM の30秒後に強制終了する）
+const defaultShutdownTimeout = 25 * time.Second
+
 type Template struct {
 	templates *template.Template
 }
@@ -42,8 +53,6 @@ func main() {
 	if err != nil {
 		log.Fatal("failed to open db connection:", err)
 	}
-	defer db.Close()
-
 	queries := database.New(db)
 
 	channelToken := os.Getenv("CHANNEL_TOKEN")
@@ -55,14 +64,41 @@ func main() {
 		log.Fatal("CHANNEL_SECRET is not set")
 	}
 
-	bot, err := linebot.New(channelSecret, channelToken)
+	bot, err := linebot.New(channelSecret, channelToken, linebot.WithHTTPClient(outbox.NewHTTPClient()))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	h := handler.NewHandler(db, queries, bot)
+	verifier, err := handler.NewTokenVerifierFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	StartReminderWorker(queries, bot)
+	sessions, err := handler.NewSessionSignerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notifier := handler.NewLineNotifier(bot)
+	h := handler.NewHandler(db, queries, notifier, channelSecret, sessions)
+
+	// SIGINT / SIGTERM（Render の再デプロイなど）で止める
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// リマインドと LINE 通知の送信（cmd/worker を別に動かす場合は RUN_WORKERS=0）
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	workersDone := make(chan struct{})
+	if worker.EnabledInAPI() {
+		go func() {
+			defer close(workersDone)
+			worker.Run(workerCtx, db, queries, notifier)
+		}()
+	} else {
+		log.Println("[BOOT] background workers are disabled (RUN_WORKERS=0)")
+		close(workersDone)
+	}
 
 	e := echo.New()
 	e.Use(middleware.Logger())
@@ -74,7 +110,7 @@ func main() {
 	}
 	e.Renderer = t
 
-	router.SetupRoutes(e, h)
+	router.SetupRoutes(e, h, verifier)
 
 	log.Printf("[BOOT] APP_ENV=%q DEV_AUTH_TOKEN=%q", os.Getenv("APP_ENV"), os.Getenv("DEV_AUTH_TOKEN"))
 	e.Logger.Infof("APP_ENV=%q DEV_AUTH_TOKEN=%q", os.Getenv("APP_ENV"), os.Getenv("DEV_AUTH_TOKEN"))
@@ -85,5 +121,59 @@ func main() {
 	}
 	log.Println("[BOOT] about to start server on PORT =", httpPort)
 
-	e.Logger.Fatal(e.Start(":" + httpPort))
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := e.Start(":" + httpPort); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Println("[SHUTDOWN] signal received")
+	case err := <-serverErr:
+		log.Println("[SHUTDOWN] server stopped:", err)
+	}
+
+	shutdown(e, h, cancelWorkers, workersDone, shutdownTimeoutFromEnv())
+
+	if err := db.Close(); err != nil {
+		log.Println("[SHUTDOWN] failed to close db:", err)
+	}
+	log.Println("[SHUTDOWN] done")
+}
+
+// 受付中のリクエストとバックグラウンドの Webhook 処理を終えてからワーカーを止める。timeout を過ぎたら待つのをやめる
+func shutdown(e *echo.Echo, h *handler.Handler, cancelWorkers context.CancelFunc, workersDone <-chan struct{}, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		log.Println("[SHUTDOWN] failed to drain http requests:", err)
+	}
+	if err := h.WaitWebhooks(ctx); err != nil {
+		log.Println("[SHUTDOWN] timed out waiting for webhook events")
+	}
+
+	// 送信中の通知は送り終えてから止まる（取り出し済みで未送信の通知は outbox に戻る）
+	cancelWorkers()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		log.Println("[SHUTDOWN] timed out waiting for background workers")
+	}
+}
+
+// 停止を待つ時間（例: SHUTDOWN_TIMEOUT=20s）
+func shutdownTimeoutFromEnv() time.Duration {
+	v := os.Getenv("SHUTDOWN_TIMEOUT")
+	if v == "" {
+		return defaultShutdownTimeout
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("[WARN] invalid SHUTDOWN_TIMEOUT %q; using %s", v, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return d
 }
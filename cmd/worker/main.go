@@ -0,0 +1,62 @@
+// cmd/worker はリマインドと LINE 通知の送信だけを動かすプロセス
+// API サーバーを複数台にする場合は、API 側を RUN_WORKERS=0 にしてこちらを動かす
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"os/signal"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/handler"
+	"shift-change-app/internal/outbox"
+	"shift-change-app/internal/worker"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		log.Fatal("failed to open db connection:", err)
+	}
+	defer db.Close()
+
+	queries := database.New(db)
+
+	channelToken := os.Getenv("CHANNEL_TOKEN")
+	if channelToken == "" {
+		log.Fatal("CHANNEL_TOKEN is not set")
+	}
+	channelSecret := os.Getenv("CHANNEL_SECRET")
+	if channelSecret == "" {
+		log.Fatal("CHANNEL_SECRET is not set")
+	}
+
+	bot, err := linebot.New(channelSecret, channelToken, linebot.WithHTTPClient(outbox.NewHTTPClient()))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// SIGINT / SIGTERM で実行中の処理を終えてから止まる
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("[BOOT] worker started")
+	worker.Run(ctx, db, queries, handler.NewLineNotifier(bot))
+	log.Println("[BOOT] worker stopped")
+}
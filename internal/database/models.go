@@ -6,26 +6,54 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type GroupMember struct {
-	UserID   uuid.UUID `json:"user_id"`
-	GroupID  uuid.UUID `json:"group_id"`
-	Role     string    `json:"role"`
-	JoinedAt time.Time `json:"joined_at"`
+	UserID     uuid.UUID     `json:"user_id"`
+	GroupID    uuid.UUID     `json:"group_id"`
+	Role       string        `json:"role"`
+	JoinedAt   time.Time     `json:"joined_at"`
+	Status     string        `json:"status"`
+	ApprovedBy uuid.NullUUID `json:"approved_by"`
 }
 
 type JobGroup struct {
-	ID             uuid.UUID    `json:"id"`
-	Name           string       `json:"name"`
-	InvitationCode string       `json:"invitation_code"`
-	OwnerID        uuid.UUID    `json:"owner_id"`
-	CreatedAt      time.Time    `json:"created_at"`
-	UpdatedAt      time.Time    `json:"updated_at"`
-	DeletedAt      sql.NullTime `json:"deleted_at"`
+	ID                     uuid.UUID      `json:"id"`
+	Name                   string         `json:"name"`
+	InvitationCode         string         `json:"invitation_code"`
+	OwnerID                uuid.UUID      `json:"owner_id"`
+	CreatedAt              time.Time      `json:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at"`
+	DeletedAt              sql.NullTime   `json:"deleted_at"`
+	AcceptMode             string         `json:"accept_mode"`
+	InvitationExpiresAt    sql.NullTime   `json:"invitation_expires_at"`
+	InvitationMaxUses      sql.NullInt32  `json:"invitation_max_uses"`
+	InvitationUseCount     int32          `json:"invitation_use_count"`
+	RequireJoinApproval    bool           `json:"require_join_approval"`
+	ReminderOffsetsMinutes []int32        `json:"reminder_offsets_minutes"`
+	LineChatID             sql.NullString `json:"line_chat_id"`
+	NotifyMode             string         `json:"notify_mode"`
+}
+
+type NotificationOutbox struct {
+	ID            uuid.UUID       `json:"id"`
+	Kind          string          `json:"kind"`
+	Recipients    []string        `json:"recipients"`
+	Messages      json.RawMessage `json:"messages"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	MaxAttempts   int32           `json:"max_attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LockedUntil   sql.NullTime    `json:"locked_until"`
+	LastError     sql.NullString  `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	SentAt        sql.NullTime    `json:"sent_at"`
+	RetryKey      uuid.UUID       `json:"retry_key"`
 }
 
 type ShiftTrade struct {
@@ -41,6 +69,54 @@ type ShiftTrade struct {
 	UpdatedAt         time.Time     `json:"updated_at"`
 	IsPaid            bool          `json:"is_paid"`
 	Details           string        `json:"details"`
+	CancelRequestedAt sql.NullTime  `json:"cancel_requested_at"`
+	TradeType         string        `json:"trade_type"`
+	SwapParentID      uuid.NullUUID `json:"swap_parent_id"`
+	SegmentMinutes    int32         `json:"segment_minutes"`
+}
+
+type ShiftTradeCounterShift struct {
+	ID           uuid.UUID `json:"id"`
+	TradeID      uuid.UUID `json:"trade_id"`
+	ShiftStartAt time.Time `json:"shift_start_at"`
+	ShiftEndAt   time.Time `json:"shift_end_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ShiftTradeEvent struct {
+	ID         uuid.UUID      `json:"id"`
+	TradeID    uuid.UUID      `json:"trade_id"`
+	FromStatus sql.NullString `json:"from_status"`
+	ToStatus   string         `json:"to_status"`
+	ActorID    uuid.NullUUID  `json:"actor_id"`
+	Reason     string         `json:"reason"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type ShiftTradeReminder struct {
+	TradeID       uuid.UUID `json:"trade_id"`
+	OffsetMinutes int32     `json:"offset_minutes"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+type ShiftTradeSegment struct {
+	ID             uuid.UUID     `json:"id"`
+	TradeID        uuid.UUID     `json:"trade_id"`
+	SegmentStartAt time.Time     `json:"segment_start_at"`
+	SegmentEndAt   time.Time     `json:"segment_end_at"`
+	AcceptorID     uuid.NullUUID `json:"acceptor_id"`
+	AcceptedAt     sql.NullTime  `json:"accepted_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+type TradeApplication struct {
+	ID          uuid.UUID     `json:"id"`
+	TradeID     uuid.UUID     `json:"trade_id"`
+	ApplicantID uuid.UUID     `json:"applicant_id"`
+	Status      string        `json:"status"`
+	DecidedBy   uuid.NullUUID `json:"decided_by"`
+	DecidedAt   sql.NullTime  `json:"decided_at"`
+	CreatedAt   time.Time     `json:"created_at"`
 }
 
 type User struct {
@@ -51,4 +127,15 @@ type User struct {
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	BotReachable    bool           `json:"bot_reachable"`
+}
+
+type WebhookEvent struct {
+	WebhookEventID string       `json:"webhook_event_id"`
+	EventType      string       `json:"event_type"`
+	IsRedelivery   bool         `json:"is_redelivery"`
+	Attempts       int32        `json:"attempts"`
+	LockedUntil    time.Time    `json:"locked_until"`
+	ProcessedAt    sql.NullTime `json:"processed_at"`
+	ReceivedAt     time.Time    `json:"received_at"`
 }
@@ -6,6 +6,8 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,53 +15,196 @@ import (
 type Querier interface {
 	// シフト交代リクエストの応募
 	AcceptShiftTrade(ctx context.Context, arg AcceptShiftTradeParams) (ShiftTrade, error)
-	// 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする
-	CloseOpenShiftTradesByGroup(ctx context.Context, groupID uuid.UUID) (int64, error)
-	// 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする
+	// 分割募集の枠を引き受ける（まだ誰も引き受けていない枠だけ）
+	AcceptShiftTradeSegments(ctx context.Context, arg AcceptShiftTradeSegmentsParams) ([]ShiftTradeSegment, error)
+	AdvisoryUnlock(ctx context.Context, lockKey int64) (bool, error)
+	// 参加申請を承認する（参加日時は承認した時刻にする）
+	ApproveGroupMember(ctx context.Context, arg ApproveGroupMemberParams) (GroupMember, error)
+	// 応募を承認する
+	ApproveTradeApplication(ctx context.Context, arg ApproveTradeApplicationParams) (TradeApplication, error)
+	// 引き受けが取り消されたときに承認済みの応募を取り消し扱いにする
+	CancelApprovedTradeApplication(ctx context.Context, tradeID uuid.UUID) error
+	// 取り消した募集への応募中の応募を取り消し扱いにする（取り消した応募者を返す）
+	CancelPendingTradeApplications(ctx context.Context, tradeID uuid.UUID) ([]uuid.UUID, error)
+	// 送信時期が来た通知を取り出す（他のワーカーと取り合わないよう locked_until まで確保する）
+	ClaimDueNotifications(ctx context.Context, arg ClaimDueNotificationsParams) ([]NotificationOutbox, error)
+	// リマインドの段階を送信済みにする（0件なら他の実行がすでに送っている）
+	ClaimTradeReminder(ctx context.Context, arg ClaimTradeReminderParams) (int64, error)
+	// Webhook イベントの処理を始める（0件なら処理済み、または他のリクエストが処理中）
+	// 処理中のまま locked_until を過ぎたもの（途中で落ちた場合）は再送されたときに処理し直す
+	ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (int64, error)
+	// グループ管理者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+	CloseOpenShiftTradeInGroup(ctx context.Context, arg CloseOpenShiftTradeInGroupParams) (ShiftTrade, error)
+	// 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする（履歴も記録）
+	CloseOpenShiftTradesByGroup(ctx context.Context, arg CloseOpenShiftTradesByGroupParams) (int64, error)
+	// 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録）
 	CloseOpenShiftTradesByRequester(ctx context.Context, requesterID uuid.UUID) (int64, error)
+	// グループを抜けるメンバーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録し、閉じた募集の id を返す）
+	CloseOpenShiftTradesByRequesterInGroup(ctx context.Context, arg CloseOpenShiftTradesByRequesterInGroupParams) ([]uuid.UUID, error)
+	// 作成者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+	CloseOwnOpenShiftTrade(ctx context.Context, arg CloseOwnOpenShiftTradeParams) (ShiftTrade, error)
+	// 交換の引き受けが取り消されたとき「お返し」の募集をCLOSEDにする（履歴も記録）
+	CloseSwapReciprocalTrades(ctx context.Context, arg CloseSwapReciprocalTradesParams) (int64, error)
+	// 状態ごとの件数（確認用）
+	CountNotificationsByStatus(ctx context.Context) ([]CountNotificationsByStatusRow, error)
+	// 指定ユーザー以外のメンバー数（退会済みユーザーは除く）
+	CountOtherActiveMembers(ctx context.Context, arg CountOtherActiveMembersParams) (int64, error)
+	// 交換募集の差し出しシフトを登録
+	CreateCounterShift(ctx context.Context, arg CreateCounterShiftParams) (ShiftTradeCounterShift, error)
 	// グループ参加
 	CreateGroupMember(ctx context.Context, arg CreateGroupMemberParams) (GroupMember, error)
 	// グループ作成
 	CreateJobGroup(ctx context.Context, arg CreateJobGroupParams) (JobGroup, error)
 	// シフト交代リクエスト作成
 	CreateShiftTrade(ctx context.Context, arg CreateShiftTradeParams) (ShiftTrade, error)
+	// シフト交代リクエストの状態遷移を記録
+	CreateShiftTradeEvent(ctx context.Context, arg CreateShiftTradeEventParams) (ShiftTradeEvent, error)
+	// 分割募集の枠を登録
+	CreateShiftTradeSegment(ctx context.Context, arg CreateShiftTradeSegmentParams) (ShiftTradeSegment, error)
+	// 交換成立時に「お返し」の成立済み募集を作る（引き受け者のシフトを作成者が引き受ける）
+	CreateSwapReciprocalTrade(ctx context.Context, arg CreateSwapReciprocalTradeParams) (ShiftTrade, error)
+	// シフト交代リクエストへ応募する
+	// 取り下げ・却下・取り消し済みの応募は PENDING に戻す（応募中・承認済みなら何も返さない）
+	CreateTradeApplication(ctx context.Context, arg CreateTradeApplicationParams) (TradeApplication, error)
 	// internal/database/query.sql
 	// ユーザー作成
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	// シフト交代リクエストの削除
-	DeleteShiftTrade(ctx context.Context, arg DeleteShiftTradeParams) (int64, error)
-	// グループ所属チェック
+	// 引き受け者が取り消し依頼を断る
+	DeclineCancelAcceptance(ctx context.Context, arg DeclineCancelAcceptanceParams) (ShiftTrade, error)
+	// グループからメンバーを外す
+	DeleteGroupMember(ctx context.Context, arg DeleteGroupMemberParams) (int64, error)
+	// 古い送信済みの通知を消す
+	DeleteSentNotificationsBefore(ctx context.Context, sentAt sql.NullTime) (int64, error)
+	// 古い Webhook イベントの記録を消す
+	DeleteWebhookEventsBefore(ctx context.Context, before time.Time) (int64, error)
+	// 送信待ちの通知を積む
+	EnqueueNotification(ctx context.Context, arg EnqueueNotificationParams) (uuid.UUID, error)
+	// 分割募集の全ての枠が埋まったら成立にする（OPEN → FILLED）
+	// 1人で全枠を引き受けた場合だけ acceptor_id を入れる
+	FillSplitShiftTrade(ctx context.Context, id uuid.UUID) (ShiftTrade, error)
+	// グループの ADMIN の LINE ID（参加申請の通知用）
+	GetGroupAdminLineIDs(ctx context.Context, groupID uuid.UUID) ([]string, error)
+	// グループ所属チェック（承認待ちは含まない）
 	GetGroupMember(ctx context.Context, arg GetGroupMemberParams) (GroupMember, error)
-	// グループメンバー全員のLINE IDを取得 (通知用)
+	// グループメンバー全員のLINE IDを取得 (通知用、bot をブロックしている人は除く)
 	GetGroupMemberLineIDs(ctx context.Context, groupID uuid.UUID) ([]string, error)
+	// 所属の取得（承認待ちを含む）
+	GetGroupMembership(ctx context.Context, arg GetGroupMembershipParams) (GroupMember, error)
 	// グループ名取得
 	GetGroupName(ctx context.Context, id uuid.UUID) (string, error)
+	// 連携しているトークからグループを検索
+	GetJobGroupByChatID(ctx context.Context, lineChatID sql.NullString) (JobGroup, error)
 	// 招待コードでグループ検索
 	GetJobGroupByCode(ctx context.Context, invitationCode string) (JobGroup, error)
 	// IDでグループ情報を取得 (画面表示用)
 	GetJobGroupByID(ctx context.Context, id uuid.UUID) (JobGroup, error)
+	// owner 以外で最も古くからいる ADMIN（退会時の自動引き継ぎ先）
+	GetLongestStandingAdmin(ctx context.Context, arg GetLongestStandingAdminParams) (GroupMember, error)
+	// 応募を id で取得
+	GetTradeApplication(ctx context.Context, id uuid.UUID) (TradeApplication, error)
 	// シフト交代リクエストを id で取得
 	GetTradeByID(ctx context.Context, id uuid.UUID) (ShiftTrade, error)
+	// シフト交代リクエストを id で取得して行ロックする（トランザクション内で使う）
+	GetTradeByIDForUpdate(ctx context.Context, id uuid.UUID) (ShiftTrade, error)
 	// IDでユーザー情報を取得 (画面表示用)
 	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
 	// LINE IDでユーザー取得
 	GetUserByLineID(ctx context.Context, lineUserID string) (User, error)
+	// LINE のトークとグループを連携し、通知をトークに投稿するようにする
+	LinkJobGroupChat(ctx context.Context, arg LinkJobGroupChatParams) (JobGroup, error)
+	// 引き受け者がすでに引き受けている時間帯と重なるものを取得（グループをまたいで確認）
+	// 成立済み(FILLED)の募集と、分割募集で引き受けた枠が対象
+	ListAcceptorConflicts(ctx context.Context, arg ListAcceptorConflictsParams) ([]ListAcceptorConflictsRow, error)
+	// 交換募集の差し出しシフト一覧
+	ListCounterShifts(ctx context.Context, tradeID uuid.UUID) ([]ShiftTradeCounterShift, error)
+	// グループ内の募集中(OPEN)の交換募集の差し出しシフト一覧（ボード表示用）
+	ListCounterShiftsForOpenTrades(ctx context.Context, groupID uuid.UUID) ([]ShiftTradeCounterShift, error)
+	// DEAD になった通知（新しい順、確認用）
+	ListDeadNotifications(ctx context.Context, limit int32) ([]ListDeadNotificationsRow, error)
+	// リマインドを送る時期を過ぎた未成立シフトを取得 (リマインド通知用)
+	// 募集ごとに、時期を過ぎた段階のうち最も開始に近いもの（offset_minutes が最小）を1つ返す
+	// それ以下の段階を送信済みの募集は対象外（停止中に過ぎた段階をまとめて送らない）
+	ListDueTradeReminders(ctx context.Context, now time.Time) ([]ListDueTradeRemindersRow, error)
+	// グループのメンバー一覧（参加順）
+	ListGroupMembers(ctx context.Context, groupID uuid.UUID) ([]ListGroupMembersRow, error)
+	// ユーザーが owner のグループ一覧（退会時のオーナー引き継ぎ用）
+	ListJobGroupsOwnedBy(ctx context.Context, ownerID uuid.UUID) ([]JobGroup, error)
+	// 分割募集のまだ埋まっていない枠（リマインド・成立判定用）
+	ListOpenShiftTradeSegments(ctx context.Context, tradeID uuid.UUID) ([]ShiftTradeSegment, error)
 	// そのグループの「募集中(OPEN)」のシフト一覧を取得
 	ListOpenShiftTrades(ctx context.Context, groupID uuid.UUID) ([]ListOpenShiftTradesRow, error)
-	// 指定された時間範囲にある未成立シフトを取得 (リマインド通知用)
-	ListUnfilledShiftsInWindow(ctx context.Context, arg ListUnfilledShiftsInWindowParams) ([]ListUnfilledShiftsInWindowRow, error)
+	// 承認待ちの参加申請一覧（申請順）
+	ListPendingGroupMembers(ctx context.Context, groupID uuid.UUID) ([]ListPendingGroupMembersRow, error)
+	// グループ内の募集中(OPEN)の分割募集の枠一覧（ボード表示用）
+	ListSegmentsForOpenTrades(ctx context.Context, groupID uuid.UUID) ([]ListSegmentsForOpenTradesRow, error)
+	// シフト交代リクエストの状態遷移履歴を取得（古い順）
+	ListShiftTradeEvents(ctx context.Context, tradeID uuid.UUID) ([]ListShiftTradeEventsRow, error)
+	// 分割募集の枠一覧（引き受け者名つき）
+	ListShiftTradeSegments(ctx context.Context, tradeID uuid.UUID) ([]ListShiftTradeSegmentsRow, error)
+	// シフト交代リクエストへの応募一覧（応募順）
+	ListTradeApplications(ctx context.Context, tradeID uuid.UUID) ([]ListTradeApplicationsRow, error)
 	// ユーザーが所属しているグループ一覧を取得
 	ListUserGroups(ctx context.Context, userID uuid.UUID) ([]ListUserGroupsRow, error)
-	// 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの)
+	// 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの（分割募集の一部を含む）)
 	ListUserTrades(ctx context.Context, requesterID uuid.UUID) ([]ShiftTrade, error)
-	// 謝礼を支払い済みにする
+	// 引き受ける人の行をロックし、同じ人の引き受け（重なりのチェックから成立まで）を直列にする
+	LockUserForAcceptance(ctx context.Context, id uuid.UUID) error
+	// 再送しても送れない通知を DEAD にする
+	MarkNotificationDead(ctx context.Context, arg MarkNotificationDeadParams) error
+	// 送信済みにする
+	MarkNotificationSent(ctx context.Context, id uuid.UUID) error
+	// 謝礼を支払い済みにする（FILLED → COMPLETED）
+	// 交換の「お返し」の募集は謝礼がないので対象外
 	MarkTradeAsPaid(ctx context.Context, arg MarkTradeAsPaidParams) (ShiftTrade, error)
+	// Webhook イベントを処理済みにする
+	MarkWebhookEventProcessed(ctx context.Context, webhookEventID string) error
+	// 参加申請を見送る
+	RejectGroupMember(ctx context.Context, arg RejectGroupMemberParams) (int64, error)
+	// 承認時に残りの応募をまとめて却下する
+	RejectPendingTradeApplications(ctx context.Context, arg RejectPendingTradeApplicationsParams) ([]uuid.UUID, error)
+	// 応募を却下する
+	RejectTradeApplication(ctx context.Context, arg RejectTradeApplicationParams) (TradeApplication, error)
+	// グループを抜けるメンバーが引き受けた分割募集の枠を空きに戻す（まだ始まっていない枠だけ）
+	ReleaseAcceptorSegmentsInGroup(ctx context.Context, arg ReleaseAcceptorSegmentsInGroupParams) ([]ShiftTradeSegment, error)
+	// 取り出したが送らなかった通知を戻す（停止時。試行回数も戻す）
+	ReleaseNotificationClaims(ctx context.Context, ids []uuid.UUID) error
+	// 処理に失敗した Webhook イベントを、再送されたときに処理し直せるようにする
+	ReleaseWebhookEvent(ctx context.Context, webhookEventID string) error
+	// 引き受けを取り消して募集を再開する（FILLED → OPEN）
+	// 締め切り（シフト開始の一定時間前）を過ぎたものは対象外
+	ReopenShiftTrade(ctx context.Context, arg ReopenShiftTradeParams) (ShiftTrade, error)
+	// 枠が空いた分割募集を募集中に戻す（FILLED → OPEN）
+	ReopenSplitShiftTrade(ctx context.Context, id uuid.UUID) (ShiftTrade, error)
+	// 作成者が引き受けの取り消しを依頼する（引き受け者の同意待ちにする）
+	RequestCancelAcceptance(ctx context.Context, arg RequestCancelAcceptanceParams) (ShiftTrade, error)
+	// 招待コードを再発行する（有効期限・利用上限を設定し、利用回数をリセット）
+	RotateInvitationCode(ctx context.Context, arg RotateInvitationCodeParams) (JobGroup, error)
+	// 送信に失敗した通知を next_attempt_at に再送する
+	ScheduleNotificationRetry(ctx context.Context, arg ScheduleNotificationRetryParams) error
+	// bot の友だち追加・ブロック（follow / unfollow）や送信失敗に合わせて通知の可否を記録する
+	SetUserBotReachableByLineID(ctx context.Context, arg SetUserBotReachableByLineIDParams) (int64, error)
 	// グループを解散（論理削除）（ownerのみ）
 	SoftDeleteJobGroup(ctx context.Context, arg SoftDeleteJobGroupParams) (int64, error)
-	// グループ名を変更（ownerのみ）
+	// オーナーを譲る（現在の owner が変わっていないときだけ）
+	TransferJobGroupOwnership(ctx context.Context, arg TransferJobGroupOwnershipParams) (JobGroup, error)
+	// バックグラウンド処理の排他用 advisory lock を取る（取れなければ false）
+	TryAdvisoryLock(ctx context.Context, lockKey int64) (bool, error)
+	// LINE のトークとの連携を外し、通知をメンバー1人ずつに戻す（bot の退出・/unlink・別グループとの連携時）
+	UnlinkJobGroupChat(ctx context.Context, lineChatID sql.NullString) (int64, error)
+	// メンバーの役割を変更（参加承認待ちの申請は対象外）
+	UpdateGroupMemberRole(ctx context.Context, arg UpdateGroupMemberRoleParams) (GroupMember, error)
+	// グループ名を変更（権限チェックはハンドラ側: owner / ADMIN）
 	UpdateJobGroupName(ctx context.Context, arg UpdateJobGroupNameParams) (JobGroup, error)
+	// グループ設定を変更（引き受け方式・参加承認・リマインドの段階・通知の送り方）
+	UpdateJobGroupSettings(ctx context.Context, arg UpdateJobGroupSettingsParams) (JobGroup, error)
 	// シフト交代リクエストの詳細を編集
 	UpdateTradeDetails(ctx context.Context, arg UpdateTradeDetailsParams) (ShiftTrade, error)
+	// 招待コードの利用回数を1つ増やす（期限切れ・上限到達なら何も返さない）
+	UseInvitationCode(ctx context.Context, arg UseInvitationCodeParams) (JobGroup, error)
+	// グループを抜けるメンバーの応募中の応募を取り下げ扱いにする
+	WithdrawPendingApplicationsInGroup(ctx context.Context, arg WithdrawPendingApplicationsInGroupParams) (int64, error)
+	// 応募を取り下げる（応募者本人）
+	WithdrawTradeApplication(ctx context.Context, arg WithdrawTradeApplicationParams) (TradeApplication, error)
 	// id指定でユーザー論理削除
 	WithdrawUser(ctx context.Context, arg WithdrawUserParams) error
 }
@@ -22,13 +22,19 @@ SET line_user_id = $2,
 WHERE id = $1
   AND deleted_at IS NULL;
 
--- 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする
+-- 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録）
 -- name: CloseOpenShiftTradesByRequester :execrows
-UPDATE shift_trades
-SET status = 'CLOSED',
-    updated_at = NOW()
-WHERE requester_id = $1
-  AND status = 'OPEN';
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE requester_id = $1
+      AND status = 'OPEN'
+    RETURNING id, requester_id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', requester_id, 'USER_WITHDRAWN'
+FROM closed;
 
 -- グループ作成
 -- name: CreateJobGroup :one
@@ -36,10 +42,11 @@ INSERT INTO job_groups (name, invitation_code, owner_id)
 VALUES ($1, $2, $3)
     RETURNING *;
 
--- グループ参加
+-- グループ参加（すでにメンバー・申請中なら何もせず sql.ErrNoRows）
 -- name: CreateGroupMember :one
-INSERT INTO group_members (user_id, group_id, role)
-VALUES ($1, $2, $3)
+INSERT INTO group_members (user_id, group_id, role, status)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, group_id) DO NOTHING
     RETURNING *;
 
 -- グループ名取得
@@ -48,9 +55,19 @@ SELECT name FROM job_groups
 WHERE id = $1
   AND deleted_at IS NULL;
 
--- グループ所属チェック
+-- グループ所属チェック（承認待ちは含まない）
 -- name: GetGroupMember :one
 SELECT gm.*
+FROM group_members gm
+         JOIN job_groups g ON g.id = gm.group_id
+WHERE gm.group_id = $1
+  AND gm.user_id = $2
+  AND gm.status = 'ACTIVE'
+  AND g.deleted_at IS NULL;
+
+-- 所属の取得（承認待ちを含む）
+-- name: GetGroupMembership :one
+SELECT gm.*
 FROM group_members gm
          JOIN job_groups g ON g.id = gm.group_id
 WHERE gm.group_id = $1
@@ -63,6 +80,7 @@ SELECT g.id, g.name, g.invitation_code, gm.role
 FROM job_groups g
          JOIN group_members gm ON g.id = gm.group_id
 WHERE gm.user_id = $1
+  AND gm.status = 'ACTIVE'
   AND g.deleted_at IS NULL
 ORDER BY g.created_at DESC;
 
@@ -76,25 +94,26 @@ LIMIT 1;
 -- シフト交代リクエスト作成
 -- name: CreateShiftTrade :one
 INSERT INTO shift_trades (
-    group_id, requester_id, shift_start_at, shift_end_at, bounty_description
+    group_id, requester_id, shift_start_at, shift_end_at, bounty_description, trade_type, segment_minutes
 ) VALUES (
-             $1, $2, $3, $4, $5
+             $1, $2, $3, $4, $5, $6, $7
          )
     RETURNING *;
 
 -- そのグループの「募集中(OPEN)」のシフト一覧を取得
 -- name: ListOpenShiftTrades :many
 SELECT
-    t.id, t.shift_start_at, t.shift_end_at, t.bounty_description, t.created_at,
+    t.id, t.shift_start_at, t.shift_end_at, t.bounty_description, t.created_at, t.trade_type, t.segment_minutes,
     u.display_name as requester_name,
-    u.profile_image_url as requester_image
+    u.profile_image_url as requester_image,
+    u.bot_reachable as requester_bot_reachable
 FROM shift_trades t
          JOIN users u ON t.requester_id = u.id
 WHERE t.group_id = $1 AND t.status = 'OPEN'
   AND t.shift_start_at > NOW()
 ORDER BY t.shift_start_at ASC;
 
--- シフト交代リクエストの応募
+-- シフト交代リクエストの応募（シフトが始まっていたら引き受けない）
 -- name: AcceptShiftTrade :one
 UPDATE shift_trades
 SET
@@ -104,18 +123,74 @@ SET
 WHERE
     id = $2
     AND status = 'OPEN'
+    AND shift_start_at > NOW()
     AND requester_id != $1
     AND EXISTS (
       SELECT 1
       FROM group_members gm
-      WHERE gm.user_id = $1 AND gm.group_id = $3
+      WHERE gm.user_id = $1 AND gm.group_id = $3 AND gm.status = 'ACTIVE'
     )
 RETURNING *;
 
--- シフト交代リクエストの削除
--- name: DeleteShiftTrade :execrows
-DELETE FROM shift_trades
-WHERE id = $1 AND requester_id = $2 AND status = 'OPEN';
+-- 作成者が引き受けの取り消しを依頼する（引き受け者の同意待ちにする）
+-- name: RequestCancelAcceptance :one
+UPDATE shift_trades
+SET cancel_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND requester_id = $2
+  AND status = 'FILLED'
+RETURNING *;
+
+-- 引き受け者が取り消し依頼を断る
+-- name: DeclineCancelAcceptance :one
+UPDATE shift_trades
+SET cancel_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND acceptor_id = $2
+  AND status = 'FILLED'
+  AND cancel_requested_at IS NOT NULL
+RETURNING *;
+
+-- 引き受けを取り消して募集を再開する（FILLED → OPEN）
+-- 締め切り（シフト開始の一定時間前）を過ぎたものは対象外
+-- name: ReopenShiftTrade :one
+UPDATE shift_trades
+SET acceptor_id = NULL,
+    status = 'OPEN',
+    cancel_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND acceptor_id = $2
+  AND status = 'FILLED'
+  AND shift_start_at > $3
+RETURNING *;
+
+-- 作成者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+-- name: CloseOwnOpenShiftTrade :one
+UPDATE shift_trades
+SET status = 'CLOSED',
+    updated_at = NOW()
+WHERE id = $1 AND requester_id = $2 AND status = 'OPEN'
+RETURNING *;
+
+-- 取り消した募集への応募中の応募を取り消し扱いにする（取り消した応募者を返す）
+-- name: CancelPendingTradeApplications :many
+UPDATE trade_applications
+SET status = 'CANCELLED',
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'PENDING'
+RETURNING applicant_id;
+
+-- グループ管理者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+-- name: CloseOpenShiftTradeInGroup :one
+UPDATE shift_trades
+SET status = 'CLOSED',
+    updated_at = NOW()
+WHERE id = $1 AND group_id = $2 AND status = 'OPEN'
+RETURNING *;
 
 -- IDでユーザー情報を取得 (画面表示用)
 -- name: GetUserByID :one
@@ -127,41 +202,78 @@ SELECT * FROM job_groups
 WHERE id = $1
   AND deleted_at IS NULL;
 
--- グループメンバー全員のLINE IDを取得 (通知用)
+-- グループメンバー全員のLINE IDを取得 (通知用、bot をブロックしている人は除く)
 -- name: GetGroupMemberLineIDs :many
 SELECT u.line_user_id
 FROM group_members gm
          JOIN users u ON gm.user_id = u.id
 WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND u.bot_reachable
   AND u.line_user_id IS NOT NULL
   AND u.line_user_id != '';
 
--- 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの)
+-- 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの（分割募集の一部を含む）)
 -- name: ListUserTrades :many
 SELECT * FROM shift_trades
-WHERE (requester_id = $1 OR acceptor_id = $1)
+WHERE (requester_id = $1 OR acceptor_id = $1
+    OR EXISTS (
+        SELECT 1
+        FROM shift_trade_segments s
+        WHERE s.trade_id = shift_trades.id
+          AND s.acceptor_id = $1
+    ))
 ORDER BY shift_start_at DESC;
 
--- 謝礼を支払い済みにする
+-- 謝礼を支払い済みにする（FILLED → COMPLETED）
+-- 交換の「お返し」の募集は謝礼がないので対象外
 -- name: MarkTradeAsPaid :one
 UPDATE shift_trades
-SET is_paid = true, updated_at = NOW()
-WHERE id = $1 AND requester_id = $2
+SET is_paid = true,
+    status = 'COMPLETED',
+    updated_at = NOW()
+WHERE id = $1 AND requester_id = $2 AND status = 'FILLED'
+  AND swap_parent_id IS NULL
     RETURNING *;
 
--- 指定された時間範囲にある未成立シフトを取得 (リマインド通知用)
--- name: ListUnfilledShiftsInWindow :many
-SELECT t.*, u.line_user_id
+-- リマインドを送る時期を過ぎた未成立シフトを取得 (リマインド通知用)
+-- 募集ごとに、時期を過ぎた段階のうち最も開始に近いもの（offset_minutes が最小）を1つ返す
+-- それ以下の段階を送信済みの募集は対象外（停止中に過ぎた段階をまとめて送らない）
+-- name: ListDueTradeReminders :many
+SELECT t.id, t.group_id, t.requester_id, t.shift_start_at, t.shift_end_at, t.segment_minutes,
+       g.name AS group_name, u.line_user_id, u.bot_reachable,
+       MIN(o.offset_minutes)::int AS offset_minutes
 FROM shift_trades t
+         JOIN job_groups g ON t.group_id = g.id
          JOIN users u ON t.requester_id = u.id
+         CROSS JOIN LATERAL unnest(g.reminder_offsets_minutes) AS o(offset_minutes)
 WHERE t.status = 'OPEN'
-  AND t.shift_start_at >= $1
-  AND t.shift_start_at < $2;
+  AND t.shift_start_at > @now::timestamptz
+  AND t.shift_start_at - make_interval(mins => o.offset_minutes) <= @now::timestamptz
+  AND g.deleted_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM shift_trade_reminders r
+      WHERE r.trade_id = t.id
+        AND r.offset_minutes <= o.offset_minutes
+  )
+GROUP BY t.id, g.name, u.line_user_id, u.bot_reachable
+ORDER BY t.shift_start_at ASC;
+
+-- リマインドの段階を送信済みにする（0件なら他の実行がすでに送っている）
+-- name: ClaimTradeReminder :execrows
+INSERT INTO shift_trade_reminders (trade_id, offset_minutes)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
 
 -- シフト交代リクエストを id で取得
 -- name: GetTradeByID :one
 SELECT * FROM shift_trades WHERE id = $1;
 
+-- シフト交代リクエストを id で取得して行ロックする（トランザクション内で使う）
+-- name: GetTradeByIDForUpdate :one
+SELECT * FROM shift_trades WHERE id = $1 FOR UPDATE;
+
 -- シフト交代リクエストの詳細を編集
 -- name: UpdateTradeDetails :one
 UPDATE shift_trades
@@ -170,13 +282,12 @@ SET details = $3,
 WHERE id = $1 AND requester_id = $2
     RETURNING *;
 
--- グループ名を変更（ownerのみ）
+-- グループ名を変更（権限チェックはハンドラ側: owner / ADMIN）
 -- name: UpdateJobGroupName :one
 UPDATE job_groups
 SET name = $2,
     updated_at = NOW()
 WHERE id = $1
-  AND owner_id = $3
   AND deleted_at IS NULL
 RETURNING *;
 
@@ -189,10 +300,607 @@ WHERE id = $1
   AND owner_id = $2
   AND deleted_at IS NULL;
 
--- 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする
+-- 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする（履歴も記録）
 -- name: CloseOpenShiftTradesByGroup :execrows
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE group_id = $1
+      AND status = 'OPEN'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', $2, 'GROUP_DISSOLVED'
+FROM closed;
+
+-- シフト交代リクエストの状態遷移を記録
+-- name: CreateShiftTradeEvent :one
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+VALUES ($1, $2, $3, $4, $5)
+    RETURNING *;
+
+-- シフト交代リクエストの状態遷移履歴を取得（古い順）
+-- name: ListShiftTradeEvents :many
+SELECT e.id, e.trade_id, e.from_status, e.to_status, e.actor_id, e.reason, e.created_at,
+       u.display_name AS actor_name
+FROM shift_trade_events e
+         LEFT JOIN users u ON e.actor_id = u.id
+WHERE e.trade_id = $1
+ORDER BY e.created_at ASC, e.id ASC;
+
+-- グループ設定を変更（引き受け方式・参加承認・リマインドの段階・通知の送り方）
+-- name: UpdateJobGroupSettings :one
+UPDATE job_groups
+SET accept_mode = $2,
+    require_join_approval = $3,
+    reminder_offsets_minutes = $4,
+    notify_mode = $5,
+    updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- シフト交代リクエストへ応募する
+-- 取り下げ・却下・取り消し済みの応募は PENDING に戻す（応募中・承認済みなら何も返さない）
+-- name: CreateTradeApplication :one
+INSERT INTO trade_applications (trade_id, applicant_id)
+VALUES ($1, $2)
+ON CONFLICT (trade_id, applicant_id) DO UPDATE
+SET status = 'PENDING',
+    decided_by = NULL,
+    decided_at = NULL,
+    created_at = NOW()
+WHERE trade_applications.status NOT IN ('PENDING', 'APPROVED')
+RETURNING *;
+
+-- 応募を id で取得
+-- name: GetTradeApplication :one
+SELECT * FROM trade_applications WHERE id = $1;
+
+-- シフト交代リクエストへの応募一覧（応募順）
+-- name: ListTradeApplications :many
+SELECT a.id, a.trade_id, a.applicant_id, a.status, a.decided_by, a.decided_at, a.created_at,
+       u.display_name AS applicant_name,
+       u.profile_image_url AS applicant_image
+FROM trade_applications a
+         JOIN users u ON a.applicant_id = u.id
+WHERE a.trade_id = $1
+  AND u.deleted_at IS NULL
+ORDER BY a.created_at ASC;
+
+-- 応募を承認する
+-- name: ApproveTradeApplication :one
+UPDATE trade_applications
+SET status = 'APPROVED',
+    decided_by = $3,
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND status = 'PENDING'
+RETURNING *;
+
+-- 応募を却下する
+-- name: RejectTradeApplication :one
+UPDATE trade_applications
+SET status = 'REJECTED',
+    decided_by = $3,
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND status = 'PENDING'
+RETURNING *;
+
+-- 承認時に残りの応募をまとめて却下する
+-- name: RejectPendingTradeApplications :many
+UPDATE trade_applications
+SET status = 'REJECTED',
+    decided_by = $2,
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'PENDING'
+RETURNING applicant_id;
+
+-- 応募を取り下げる（応募者本人）
+-- name: WithdrawTradeApplication :one
+UPDATE trade_applications
+SET status = 'WITHDRAWN',
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND applicant_id = $3
+  AND status = 'PENDING'
+RETURNING *;
+
+-- 引き受けが取り消されたときに承認済みの応募を取り消し扱いにする
+-- name: CancelApprovedTradeApplication :exec
+UPDATE trade_applications
+SET status = 'CANCELLED',
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'APPROVED';
+
+-- 交換募集の差し出しシフトを登録
+-- name: CreateCounterShift :one
+INSERT INTO shift_trade_counter_shifts (trade_id, shift_start_at, shift_end_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- 交換募集の差し出しシフト一覧
+-- name: ListCounterShifts :many
+SELECT * FROM shift_trade_counter_shifts
+WHERE trade_id = $1
+ORDER BY shift_start_at ASC;
+
+-- グループ内の募集中(OPEN)の交換募集の差し出しシフト一覧（ボード表示用）
+-- name: ListCounterShiftsForOpenTrades :many
+SELECT cs.*
+FROM shift_trade_counter_shifts cs
+         JOIN shift_trades t ON cs.trade_id = t.id
+WHERE t.group_id = $1
+  AND t.status = 'OPEN'
+ORDER BY cs.shift_start_at ASC;
+
+-- 交換成立時に「お返し」の成立済み募集を作る（引き受け者のシフトを作成者が引き受ける）
+-- name: CreateSwapReciprocalTrade :one
+INSERT INTO shift_trades (
+    group_id, requester_id, acceptor_id, shift_start_at, shift_end_at,
+    bounty_description, trade_type, status, swap_parent_id
+) VALUES (
+             $1, $2, $3, $4, $5, '交換', 'SWAP', 'FILLED', $6
+         )
+    RETURNING *;
+
+-- 交換の引き受けが取り消されたとき「お返し」の募集をCLOSEDにする（履歴も記録）
+-- name: CloseSwapReciprocalTrades :execrows
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE swap_parent_id = $1
+      AND status = 'FILLED'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'FILLED', 'CLOSED', $2, 'SWAP_CANCELLED'
+FROM closed;
+
+-- 分割募集の枠を登録
+-- name: CreateShiftTradeSegment :one
+INSERT INTO shift_trade_segments (trade_id, segment_start_at, segment_end_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- 分割募集の枠一覧（引き受け者名つき）
+-- name: ListShiftTradeSegments :many
+SELECT s.id, s.trade_id, s.segment_start_at, s.segment_end_at, s.acceptor_id, s.accepted_at,
+       u.display_name AS acceptor_name
+FROM shift_trade_segments s
+         LEFT JOIN users u ON s.acceptor_id = u.id
+WHERE s.trade_id = $1
+ORDER BY s.segment_start_at ASC;
+
+-- グループ内の募集中(OPEN)の分割募集の枠一覧（ボード表示用）
+-- name: ListSegmentsForOpenTrades :many
+SELECT s.id, s.trade_id, s.segment_start_at, s.segment_end_at, s.acceptor_id, s.accepted_at,
+       u.display_name AS acceptor_name
+FROM shift_trade_segments s
+         JOIN shift_trades t ON s.trade_id = t.id
+         LEFT JOIN users u ON s.acceptor_id = u.id
+WHERE t.group_id = $1
+  AND t.status = 'OPEN'
+ORDER BY s.segment_start_at ASC;
+
+-- 分割募集の枠を引き受ける（まだ誰も引き受けていない枠だけ。シフトが始まっていたら引き受けない）
+-- name: AcceptShiftTradeSegments :many
+UPDATE shift_trade_segments
+SET acceptor_id = $1,
+    accepted_at = NOW()
+WHERE trade_id = $2
+  AND id = ANY(@segment_ids::uuid[])
+  AND acceptor_id IS NULL
+  AND EXISTS (
+      SELECT 1
+      FROM shift_trades t
+      WHERE t.id = shift_trade_segments.trade_id
+        AND t.shift_start_at > NOW()
+  )
+RETURNING *;
+
+-- 分割募集のまだ埋まっていない枠（リマインド・成立判定用）
+-- name: ListOpenShiftTradeSegments :many
+SELECT * FROM shift_trade_segments
+WHERE trade_id = $1
+  AND acceptor_id IS NULL
+ORDER BY segment_start_at ASC;
+
+-- 分割募集の全ての枠が埋まったら成立にする（OPEN → FILLED）
+-- 1人で全枠を引き受けた場合だけ acceptor_id を入れる
+-- name: FillSplitShiftTrade :one
 UPDATE shift_trades
-SET status = 'CLOSED',
+SET status = 'FILLED',
+    acceptor_id = (
+        SELECT s.acceptor_id
+        FROM shift_trade_segments s
+        WHERE s.trade_id = shift_trades.id
+        GROUP BY s.acceptor_id
+        HAVING COUNT(*) = (SELECT COUNT(*) FROM shift_trade_segments s2 WHERE s2.trade_id = shift_trades.id)
+    ),
+    updated_at = NOW()
+WHERE shift_trades.id = @id
+  AND shift_trades.status = 'OPEN'
+  AND NOT EXISTS (
+      SELECT 1
+      FROM shift_trade_segments s
+      WHERE s.trade_id = shift_trades.id
+        AND s.acceptor_id IS NULL
+  )
+RETURNING *;
+
+-- 引き受け者がすでに引き受けている時間帯と重なるものを取得（グループをまたいで確認）
+-- 成立済み(FILLED)・支払い済み(COMPLETED)の募集と、分割募集で引き受けた枠が対象
+-- name: ListAcceptorConflicts :many
+SELECT t.id AS trade_id, t.group_id, g.name AS group_name,
+       t.shift_start_at AS start_at, t.shift_end_at AS end_at
+FROM shift_trades t
+         JOIN job_groups g ON t.group_id = g.id
+WHERE t.acceptor_id = @acceptor_id
+  AND t.status IN ('FILLED', 'COMPLETED')
+  AND t.segment_minutes = 0
+  AND t.id <> @exclude_trade_id
+  AND t.shift_start_at < @end_at
+  AND t.shift_end_at > @start_at
+UNION ALL
+SELECT t.id AS trade_id, t.group_id, g.name AS group_name,
+       s.segment_start_at AS start_at, s.segment_end_at AS end_at
+FROM shift_trade_segments s
+         JOIN shift_trades t ON s.trade_id = t.id
+         JOIN job_groups g ON t.group_id = g.id
+WHERE s.acceptor_id = @acceptor_id
+  AND t.status IN ('OPEN', 'FILLED', 'COMPLETED')
+  AND t.id <> @exclude_trade_id
+  AND s.segment_start_at < @end_at
+  AND s.segment_end_at > @start_at
+ORDER BY start_at ASC;
+
+-- メンバーの役割を変更（参加承認待ちの申請は対象外）
+-- name: UpdateGroupMemberRole :one
+UPDATE group_members
+SET role = $3
+WHERE group_id = $1
+  AND user_id = $2
+  AND status = 'ACTIVE'
+RETURNING *;
+
+-- グループのメンバー一覧（参加順）
+-- name: ListGroupMembers :many
+SELECT gm.user_id, u.display_name, u.profile_image_url, gm.role, gm.joined_at,
+       (g.owner_id = gm.user_id) AS is_owner, u.bot_reachable
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+         JOIN job_groups g ON gm.group_id = g.id
+WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND u.deleted_at IS NULL
+  AND g.deleted_at IS NULL
+ORDER BY gm.joined_at ASC;
+
+-- グループを抜けるメンバーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録し、閉じた募集の id を返す）
+-- name: CloseOpenShiftTradesByRequesterInGroup :many
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE requester_id = @requester_id
+      AND group_id = @group_id
+      AND status = 'OPEN'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', @actor_id, @reason
+FROM closed
+RETURNING trade_id;
+
+-- グループを抜けるメンバーの応募中の応募を取り下げ扱いにする
+-- name: WithdrawPendingApplicationsInGroup :execrows
+UPDATE trade_applications a
+SET status = 'WITHDRAWN',
+    decided_at = NOW()
+FROM shift_trades t
+WHERE a.trade_id = t.id
+  AND a.applicant_id = $1
+  AND t.group_id = $2
+  AND a.status = 'PENDING';
+
+-- グループを抜けるメンバーが引き受けた分割募集の枠を空きに戻す（まだ始まっていない枠だけ）
+-- name: ReleaseAcceptorSegmentsInGroup :many
+UPDATE shift_trade_segments s
+SET acceptor_id = NULL,
+    accepted_at = NULL
+FROM shift_trades t
+WHERE s.trade_id = t.id
+  AND s.acceptor_id = @acceptor_id
+  AND t.group_id = @group_id
+  AND t.status IN ('OPEN', 'FILLED')
+  AND s.segment_start_at > NOW()
+RETURNING s.*;
+
+-- 枠が空いた分割募集を募集中に戻す（FILLED → OPEN）
+-- name: ReopenSplitShiftTrade :one
+UPDATE shift_trades
+SET acceptor_id = NULL,
+    status = 'OPEN',
     updated_at = NOW()
+WHERE id = $1
+  AND status = 'FILLED'
+  AND segment_minutes > 0
+RETURNING *;
+
+-- グループからメンバーを外す
+-- name: DeleteGroupMember :execrows
+DELETE FROM group_members
 WHERE group_id = $1
-  AND status = 'OPEN';
+  AND user_id = $2;
+
+-- 招待コードを再発行する（有効期限・利用上限を設定し、利用回数をリセット）
+-- name: RotateInvitationCode :one
+UPDATE job_groups
+SET invitation_code = $2,
+    invitation_expires_at = $3,
+    invitation_max_uses = $4,
+    invitation_use_count = 0,
+    updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- 招待コードの利用回数を1つ増やす（期限切れ・上限到達なら何も返さない）
+-- name: UseInvitationCode :one
+UPDATE job_groups
+SET invitation_use_count = invitation_use_count + 1
+WHERE id = $1
+  AND invitation_code = $2
+  AND deleted_at IS NULL
+  AND (invitation_expires_at IS NULL OR invitation_expires_at > NOW())
+  AND (invitation_max_uses IS NULL OR invitation_use_count < invitation_max_uses)
+RETURNING *;
+
+-- 承認待ちの参加申請一覧（申請順）
+-- name: ListPendingGroupMembers :many
+SELECT gm.user_id, u.display_name, u.profile_image_url, gm.joined_at AS requested_at
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.status = 'PENDING'
+  AND u.deleted_at IS NULL
+ORDER BY gm.joined_at ASC;
+
+-- 参加申請を承認する（参加日時は承認した時刻にする）
+-- name: ApproveGroupMember :one
+UPDATE group_members
+SET status = 'ACTIVE',
+    approved_by = @approved_by,
+    joined_at = NOW()
+WHERE group_id = @group_id
+  AND user_id = @user_id
+  AND status = 'PENDING'
+RETURNING *;
+
+-- 参加申請を見送る
+-- name: RejectGroupMember :execrows
+DELETE FROM group_members
+WHERE group_id = $1
+  AND user_id = $2
+  AND status = 'PENDING';
+
+-- グループの ADMIN の LINE ID（参加申請の通知用）
+-- name: GetGroupAdminLineIDs :many
+SELECT u.line_user_id
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND gm.role = 'ADMIN'
+  AND u.deleted_at IS NULL
+  AND u.bot_reachable
+  AND u.line_user_id IS NOT NULL
+  AND u.line_user_id != '';
+
+-- ユーザーが owner のグループ一覧（退会時のオーナー引き継ぎ用）
+-- name: ListJobGroupsOwnedBy :many
+SELECT * FROM job_groups
+WHERE owner_id = $1
+  AND deleted_at IS NULL
+ORDER BY created_at ASC;
+
+-- オーナーを譲る（現在の owner が変わっていないときだけ）
+-- name: TransferJobGroupOwnership :one
+UPDATE job_groups
+SET owner_id = @new_owner_id,
+    updated_at = NOW()
+WHERE id = @id
+  AND owner_id = @current_owner_id
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- owner 以外で最も古くからいる ADMIN（退会時の自動引き継ぎ先）
+-- name: GetLongestStandingAdmin :one
+SELECT gm.*
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.user_id != $2
+  AND gm.status = 'ACTIVE'
+  AND gm.role = 'ADMIN'
+  AND u.deleted_at IS NULL
+ORDER BY gm.joined_at ASC
+LIMIT 1;
+
+-- 指定ユーザー以外のメンバー数（退会済みユーザーは除く）
+-- name: CountOtherActiveMembers :one
+SELECT COUNT(*)
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.user_id != $2
+  AND gm.status = 'ACTIVE'
+  AND u.deleted_at IS NULL;
+
+-- 送信待ちの通知を積む
+-- name: EnqueueNotification :one
+INSERT INTO notification_outbox (kind, recipients, messages)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- 送信時期が来た通知を取り出す（他のワーカーと取り合わないよう locked_until まで確保する）
+-- name: ClaimDueNotifications :many
+UPDATE notification_outbox
+SET attempts = attempts + 1,
+    locked_until = @locked_until,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT o.id
+    FROM notification_outbox o
+    WHERE o.status = 'PENDING'
+      AND o.next_attempt_at <= NOW()
+      AND (o.locked_until IS NULL OR o.locked_until < NOW())
+    ORDER BY o.next_attempt_at ASC
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- 取り出したが送らなかった通知を戻す（停止時。試行回数も戻す）
+-- name: ReleaseNotificationClaims :exec
+UPDATE notification_outbox
+SET attempts = GREATEST(attempts - 1, 0),
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = ANY(@ids::uuid[])
+  AND status = 'PENDING';
+
+-- 送信済みにする
+-- name: MarkNotificationSent :exec
+UPDATE notification_outbox
+SET status = 'SENT',
+    sent_at = NOW(),
+    locked_until = NULL,
+    last_error = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- 送信に失敗した通知を next_attempt_at に再送する
+-- name: ScheduleNotificationRetry :exec
+UPDATE notification_outbox
+SET next_attempt_at = $2,
+    last_error = $3,
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- 再送しても送れない通知を DEAD にする
+-- name: MarkNotificationDead :exec
+UPDATE notification_outbox
+SET status = 'DEAD',
+    last_error = $2,
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- 状態ごとの件数（確認用）
+-- name: CountNotificationsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM notification_outbox
+GROUP BY status
+ORDER BY status;
+
+-- DEAD になった通知（新しい順、確認用。宛先やエラー内容は返さない）
+-- name: ListDeadNotifications :many
+SELECT id, kind, attempts, created_at, updated_at
+FROM notification_outbox
+WHERE status = 'DEAD'
+ORDER BY updated_at DESC
+LIMIT $1;
+
+-- 古い送信済みの通知を消す
+-- name: DeleteSentNotificationsBefore :execrows
+DELETE FROM notification_outbox
+WHERE status = 'SENT'
+  AND sent_at < $1;
+
+-- バックグラウンド処理の排他用 advisory lock を取る（取れなければ false）
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock(@lock_key::bigint) AS locked;
+
+-- name: AdvisoryUnlock :one
+SELECT pg_advisory_unlock(@lock_key::bigint) AS unlocked;
+
+-- Webhook イベントの処理を始める（0件なら処理済み、または他のリクエストが処理中）
+-- 処理中のまま locked_until を過ぎたもの（途中で落ちた場合）は再送されたときに処理し直す
+-- name: ClaimWebhookEvent :execrows
+INSERT INTO webhook_events (webhook_event_id, event_type, is_redelivery, locked_until)
+VALUES (@webhook_event_id, @event_type, @is_redelivery, @locked_until)
+ON CONFLICT (webhook_event_id) DO UPDATE
+SET attempts = webhook_events.attempts + 1,
+    is_redelivery = EXCLUDED.is_redelivery,
+    locked_until = EXCLUDED.locked_until
+WHERE webhook_events.processed_at IS NULL
+  AND webhook_events.locked_until < NOW();
+
+-- Webhook イベントを処理済みにする
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET processed_at = NOW()
+WHERE webhook_event_id = $1;
+
+-- 処理に失敗した Webhook イベントを、再送されたときに処理し直せるようにする
+-- name: ReleaseWebhookEvent :exec
+UPDATE webhook_events
+SET locked_until = NOW()
+WHERE webhook_event_id = $1
+  AND processed_at IS NULL;
+
+-- 古い Webhook イベントの記録を消す
+-- name: DeleteWebhookEventsBefore :execrows
+DELETE FROM webhook_events
+WHERE received_at < @before::timestamptz;
+
+-- bot の友だち追加・ブロック（follow / unfollow）や送信失敗に合わせて通知の可否を記録する
+-- name: SetUserBotReachableByLineID :execrows
+UPDATE users
+SET bot_reachable = @bot_reachable,
+    updated_at = NOW()
+WHERE line_user_id = @line_user_id
+  AND deleted_at IS NULL
+  AND bot_reachable != @bot_reachable;
+
+-- LINE のトークとグループを連携し、通知をトークに投稿するようにする
+-- name: LinkJobGroupChat :one
+UPDATE job_groups
+SET line_chat_id = @line_chat_id,
+    notify_mode = 'CHAT',
+    updated_at = NOW()
+WHERE id = @id
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- LINE のトークとの連携を外し、通知をメンバー1人ずつに戻す（bot の退出・/unlink・別グループとの連携時）
+-- name: UnlinkJobGroupChat :execrows
+UPDATE job_groups
+SET line_chat_id = NULL,
+    notify_mode = 'INDIVIDUAL',
+    updated_at = NOW()
+WHERE line_chat_id = @line_chat_id;
+
+-- 連携しているトークからグループを検索
+-- name: GetJobGroupByChatID :one
+SELECT * FROM job_groups
+WHERE line_chat_id = $1
+  AND deleted_at IS NULL
+LIMIT 1;
+
+-- 引き受ける人の行をロックし、同じ人の引き受け（重なりのチェックから成立まで）を直列にする
+-- name: LockUserForAcceptance :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
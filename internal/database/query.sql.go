@@ -8,9 +8,11 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const acceptShiftTrade = `-- name: AcceptShiftTrade :one
@@ -22,13 +24,14 @@ SET
 WHERE
     id = $2
     AND status = 'OPEN'
+    AND shift_start_at > NOW()
     AND requester_id != $1
     AND EXISTS (
       SELECT 1
       FROM group_members gm
-      WHERE gm.user_id = $1 AND gm.group_id = $3
+      WHERE gm.user_id = $1 AND gm.group_id = $3 AND gm.status = 'ACTIVE'
     )
-RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
 `
 
 type AcceptShiftTradeParams struct {
@@ -37,7 +40,7 @@ type AcceptShiftTradeParams struct {
 	GroupID    uuid.UUID     `json:"group_id"`
 }
 
-// シフト交代リクエストの応募
+// シフト交代リクエストの応募（シフトが始まっていたら引き受けない）
 func (q *Queries) AcceptShiftTrade(ctx context.Context, arg AcceptShiftTradeParams) (ShiftTrade, error) {
 	row := q.db.QueryRowContext(ctx, acceptShiftTrade, arg.AcceptorID, arg.ID, arg.GroupID)
 	var i ShiftTrade
@@ -54,123 +57,322 @@ func (q *Queries) AcceptShiftTrade(ctx context.Context, arg AcceptShiftTradePara
 		&i.UpdatedAt,
 		&i.IsPaid,
 		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
 	)
 	return i, err
 }
 
-const closeOpenShiftTradesByGroup = `-- name: CloseOpenShiftTradesByGroup :execrows
-UPDATE shift_trades
-SET status = 'CLOSED',
-    updated_at = NOW()
-WHERE group_id = $1
-  AND status = 'OPEN'
+const acceptShiftTradeSegments = `-- name: AcceptShiftTradeSegments :many
+UPDATE shift_trade_segments
+SET acceptor_id = $1,
+    accepted_at = NOW()
+WHERE trade_id = $2
+  AND id = ANY($3::uuid[])
+  AND acceptor_id IS NULL
+  AND EXISTS (
+      SELECT 1
+      FROM shift_trades t
+      WHERE t.id = shift_trade_segments.trade_id
+        AND t.shift_start_at > NOW()
+  )
+RETURNING id, trade_id, segment_start_at, segment_end_at, acceptor_id, accepted_at, created_at
 `
 
-// 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする
-func (q *Queries) CloseOpenShiftTradesByGroup(ctx context.Context, groupID uuid.UUID) (int64, error) {
-	result, err := q.db.ExecContext(ctx, closeOpenShiftTradesByGroup, groupID)
+type AcceptShiftTradeSegmentsParams struct {
+	AcceptorID uuid.NullUUID `json:"acceptor_id"`
+	TradeID    uuid.UUID     `json:"trade_id"`
+	SegmentIds []uuid.UUID   `json:"segment_ids"`
+}
+
+// 分割募集の枠を引き受ける（まだ誰も引き受けていない枠だけ。シフトが始まっていたら引き受けない）
+func (q *Queries) AcceptShiftTradeSegments(ctx context.Context, arg AcceptShiftTradeSegmentsParams) ([]ShiftTradeSegment, error) {
+	rows, err := q.db.QueryContext(ctx, acceptShiftTradeSegments, arg.AcceptorID, arg.TradeID, pq.Array(arg.SegmentIds))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected()
+	defer rows.Close()
+	var items []ShiftTradeSegment
+	for rows.Next() {
+		var i ShiftTradeSegment
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.SegmentStartAt,
+			&i.SegmentEndAt,
+			&i.AcceptorID,
+			&i.AcceptedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const closeOpenShiftTradesByRequester = `-- name: CloseOpenShiftTradesByRequester :execrows
-UPDATE shift_trades
-SET status = 'CLOSED',
-    updated_at = NOW()
-WHERE requester_id = $1
-  AND status = 'OPEN'
+const advisoryUnlock = `-- name: AdvisoryUnlock :one
+SELECT pg_advisory_unlock($1::bigint) AS unlocked
 `
 
-// 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする
-func (q *Queries) CloseOpenShiftTradesByRequester(ctx context.Context, requesterID uuid.UUID) (int64, error) {
-	result, err := q.db.ExecContext(ctx, closeOpenShiftTradesByRequester, requesterID)
-	if err != nil {
-		return 0, err
-	}
-	return result.RowsAffected()
+func (q *Queries) AdvisoryUnlock(ctx context.Context, lockKey int64) (bool, error) {
+	row := q.db.QueryRowContext(ctx, advisoryUnlock, lockKey)
+	var unlocked bool
+	err := row.Scan(&unlocked)
+	return unlocked, err
 }
 
-const createGroupMember = `-- name: CreateGroupMember :one
-INSERT INTO group_members (user_id, group_id, role)
-VALUES ($1, $2, $3)
-    RETURNING user_id, group_id, role, joined_at
+const approveGroupMember = `-- name: ApproveGroupMember :one
+UPDATE group_members
+SET status = 'ACTIVE',
+    approved_by = $1,
+    joined_at = NOW()
+WHERE group_id = $2
+  AND user_id = $3
+  AND status = 'PENDING'
+RETURNING user_id, group_id, role, joined_at, status, approved_by
 `
 
-type CreateGroupMemberParams struct {
-	UserID  uuid.UUID `json:"user_id"`
-	GroupID uuid.UUID `json:"group_id"`
-	Role    string    `json:"role"`
+type ApproveGroupMemberParams struct {
+	ApprovedBy uuid.NullUUID `json:"approved_by"`
+	GroupID    uuid.UUID     `json:"group_id"`
+	UserID     uuid.UUID     `json:"user_id"`
 }
 
-// グループ参加
-func (q *Queries) CreateGroupMember(ctx context.Context, arg CreateGroupMemberParams) (GroupMember, error) {
-	row := q.db.QueryRowContext(ctx, createGroupMember, arg.UserID, arg.GroupID, arg.Role)
+// 参加申請を承認する（参加日時は承認した時刻にする）
+func (q *Queries) ApproveGroupMember(ctx context.Context, arg ApproveGroupMemberParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, approveGroupMember, arg.ApprovedBy, arg.GroupID, arg.UserID)
 	var i GroupMember
 	err := row.Scan(
 		&i.UserID,
 		&i.GroupID,
 		&i.Role,
 		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
 	)
 	return i, err
 }
 
-const createJobGroup = `-- name: CreateJobGroup :one
-INSERT INTO job_groups (name, invitation_code, owner_id)
-VALUES ($1, $2, $3)
-    RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at
+const approveTradeApplication = `-- name: ApproveTradeApplication :one
+UPDATE trade_applications
+SET status = 'APPROVED',
+    decided_by = $3,
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND status = 'PENDING'
+RETURNING id, trade_id, applicant_id, status, decided_by, decided_at, created_at
 `
 
-type CreateJobGroupParams struct {
-	Name           string    `json:"name"`
-	InvitationCode string    `json:"invitation_code"`
-	OwnerID        uuid.UUID `json:"owner_id"`
+type ApproveTradeApplicationParams struct {
+	ID        uuid.UUID     `json:"id"`
+	TradeID   uuid.UUID     `json:"trade_id"`
+	DecidedBy uuid.NullUUID `json:"decided_by"`
 }
 
-// グループ作成
-func (q *Queries) CreateJobGroup(ctx context.Context, arg CreateJobGroupParams) (JobGroup, error) {
-	row := q.db.QueryRowContext(ctx, createJobGroup, arg.Name, arg.InvitationCode, arg.OwnerID)
-	var i JobGroup
+// 応募を承認する
+func (q *Queries) ApproveTradeApplication(ctx context.Context, arg ApproveTradeApplicationParams) (TradeApplication, error) {
+	row := q.db.QueryRowContext(ctx, approveTradeApplication, arg.ID, arg.TradeID, arg.DecidedBy)
+	var i TradeApplication
 	err := row.Scan(
 		&i.ID,
-		&i.Name,
-		&i.InvitationCode,
-		&i.OwnerID,
+		&i.TradeID,
+		&i.ApplicantID,
+		&i.Status,
+		&i.DecidedBy,
+		&i.DecidedAt,
 		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const createShiftTrade = `-- name: CreateShiftTrade :one
-INSERT INTO shift_trades (
-    group_id, requester_id, shift_start_at, shift_end_at, bounty_description
-) VALUES (
-             $1, $2, $3, $4, $5
-         )
-    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details
+const cancelApprovedTradeApplication = `-- name: CancelApprovedTradeApplication :exec
+UPDATE trade_applications
+SET status = 'CANCELLED',
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'APPROVED'
 `
 
-type CreateShiftTradeParams struct {
-	GroupID           uuid.UUID `json:"group_id"`
-	RequesterID       uuid.UUID `json:"requester_id"`
-	ShiftStartAt      time.Time `json:"shift_start_at"`
-	ShiftEndAt        time.Time `json:"shift_end_at"`
-	BountyDescription string    `json:"bounty_description"`
+// 引き受けが取り消されたときに承認済みの応募を取り消し扱いにする
+func (q *Queries) CancelApprovedTradeApplication(ctx context.Context, tradeID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelApprovedTradeApplication, tradeID)
+	return err
 }
 
-// シフト交代リクエスト作成
-func (q *Queries) CreateShiftTrade(ctx context.Context, arg CreateShiftTradeParams) (ShiftTrade, error) {
-	row := q.db.QueryRowContext(ctx, createShiftTrade,
-		arg.GroupID,
-		arg.RequesterID,
-		arg.ShiftStartAt,
-		arg.ShiftEndAt,
-		arg.BountyDescription,
+const cancelPendingTradeApplications = `-- name: CancelPendingTradeApplications :many
+UPDATE trade_applications
+SET status = 'CANCELLED',
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'PENDING'
+RETURNING applicant_id
+`
+
+// 取り消した募集への応募中の応募を取り消し扱いにする（取り消した応募者を返す）
+func (q *Queries) CancelPendingTradeApplications(ctx context.Context, tradeID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, cancelPendingTradeApplications, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var applicant_id uuid.UUID
+		if err := rows.Scan(&applicant_id); err != nil {
+			return nil, err
+		}
+		items = append(items, applicant_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimDueNotifications = `-- name: ClaimDueNotifications :many
+UPDATE notification_outbox
+SET attempts = attempts + 1,
+    locked_until = $1,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT o.id
+    FROM notification_outbox o
+    WHERE o.status = 'PENDING'
+      AND o.next_attempt_at <= NOW()
+      AND (o.locked_until IS NULL OR o.locked_until < NOW())
+    ORDER BY o.next_attempt_at ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, recipients, messages, status, attempts, max_attempts, next_attempt_at, locked_until, last_error, created_at, updated_at, sent_at, retry_key
+`
+
+type ClaimDueNotificationsParams struct {
+	LockedUntil sql.NullTime `json:"locked_until"`
+	BatchSize   int32        `json:"batch_size"`
+}
+
+// 送信時期が来た通知を取り出す（他のワーカーと取り合わないよう locked_until まで確保する）
+func (q *Queries) ClaimDueNotifications(ctx context.Context, arg ClaimDueNotificationsParams) ([]NotificationOutbox, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueNotifications, arg.LockedUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationOutbox
+	for rows.Next() {
+		var i NotificationOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			pq.Array(&i.Recipients),
+			&i.Messages,
+			&i.Status,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.NextAttemptAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SentAt,
+			&i.RetryKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimTradeReminder = `-- name: ClaimTradeReminder :execrows
+INSERT INTO shift_trade_reminders (trade_id, offset_minutes)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type ClaimTradeReminderParams struct {
+	TradeID       uuid.UUID `json:"trade_id"`
+	OffsetMinutes int32     `json:"offset_minutes"`
+}
+
+// リマインドの段階を送信済みにする（0件なら他の実行がすでに送っている）
+func (q *Queries) ClaimTradeReminder(ctx context.Context, arg ClaimTradeReminderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimTradeReminder, arg.TradeID, arg.OffsetMinutes)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :execrows
+INSERT INTO webhook_events (webhook_event_id, event_type, is_redelivery, locked_until)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (webhook_event_id) DO UPDATE
+SET attempts = webhook_events.attempts + 1,
+    is_redelivery = EXCLUDED.is_redelivery,
+    locked_until = EXCLUDED.locked_until
+WHERE webhook_events.processed_at IS NULL
+  AND webhook_events.locked_until < NOW()
+`
+
+type ClaimWebhookEventParams struct {
+	WebhookEventID string    `json:"webhook_event_id"`
+	EventType      string    `json:"event_type"`
+	IsRedelivery   bool      `json:"is_redelivery"`
+	LockedUntil    time.Time `json:"locked_until"`
+}
+
+// Webhook イベントの処理を始める（0件なら処理済み、または他のリクエストが処理中）
+// 処理中のまま locked_until を過ぎたもの（途中で落ちた場合）は再送されたときに処理し直す
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimWebhookEvent,
+		arg.WebhookEventID,
+		arg.EventType,
+		arg.IsRedelivery,
+		arg.LockedUntil,
 	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const closeOpenShiftTradeInGroup = `-- name: CloseOpenShiftTradeInGroup :one
+UPDATE shift_trades
+SET status = 'CLOSED',
+    updated_at = NOW()
+WHERE id = $1 AND group_id = $2 AND status = 'OPEN'
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+type CloseOpenShiftTradeInGroupParams struct {
+	ID      uuid.UUID `json:"id"`
+	GroupID uuid.UUID `json:"group_id"`
+}
+
+// グループ管理者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+func (q *Queries) CloseOpenShiftTradeInGroup(ctx context.Context, arg CloseOpenShiftTradeInGroupParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, closeOpenShiftTradeInGroup, arg.ID, arg.GroupID)
 	var i ShiftTrade
 	err := row.Scan(
 		&i.ID,
@@ -185,109 +387,107 @@ func (q *Queries) CreateShiftTrade(ctx context.Context, arg CreateShiftTradePara
 		&i.UpdatedAt,
 		&i.IsPaid,
 		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
 	)
 	return i, err
 }
 
-const createUser = `-- name: CreateUser :one
-
-INSERT INTO users (line_user_id, display_name, profile_image_url)
-VALUES ($1, $2, $3)
-    RETURNING id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at
+const closeOpenShiftTradesByGroup = `-- name: CloseOpenShiftTradesByGroup :execrows
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE group_id = $1
+      AND status = 'OPEN'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', $2, 'GROUP_DISSOLVED'
+FROM closed
 `
 
-type CreateUserParams struct {
-	LineUserID      string         `json:"line_user_id"`
-	DisplayName     string         `json:"display_name"`
-	ProfileImageUrl sql.NullString `json:"profile_image_url"`
+type CloseOpenShiftTradesByGroupParams struct {
+	GroupID uuid.UUID     `json:"group_id"`
+	ActorID uuid.NullUUID `json:"actor_id"`
 }
 
-// internal/database/query.sql
-// ユーザー作成
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.LineUserID, arg.DisplayName, arg.ProfileImageUrl)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.LineUserID,
-		&i.DisplayName,
-		&i.ProfileImageUrl,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.DeletedAt,
-	)
-	return i, err
+// 解散したグループの「募集中(OPEN)」募集を全てCLOSEDにする（履歴も記録）
+func (q *Queries) CloseOpenShiftTradesByGroup(ctx context.Context, arg CloseOpenShiftTradesByGroupParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, closeOpenShiftTradesByGroup, arg.GroupID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const deleteShiftTrade = `-- name: DeleteShiftTrade :execrows
-DELETE FROM shift_trades
-WHERE id = $1 AND requester_id = $2 AND status = 'OPEN'
+const closeOpenShiftTradesByRequester = `-- name: CloseOpenShiftTradesByRequester :execrows
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE requester_id = $1
+      AND status = 'OPEN'
+    RETURNING id, requester_id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', requester_id, 'USER_WITHDRAWN'
+FROM closed
 `
 
-type DeleteShiftTradeParams struct {
-	ID          uuid.UUID `json:"id"`
-	RequesterID uuid.UUID `json:"requester_id"`
-}
-
-// シフト交代リクエストの削除
-func (q *Queries) DeleteShiftTrade(ctx context.Context, arg DeleteShiftTradeParams) (int64, error) {
-	result, err := q.db.ExecContext(ctx, deleteShiftTrade, arg.ID, arg.RequesterID)
+// 退会ユーザーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録）
+func (q *Queries) CloseOpenShiftTradesByRequester(ctx context.Context, requesterID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, closeOpenShiftTradesByRequester, requesterID)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
 
-const getGroupMember = `-- name: GetGroupMember :one
-SELECT gm.user_id, gm.group_id, gm.role, gm.joined_at
-FROM group_members gm
-         JOIN job_groups g ON g.id = gm.group_id
-WHERE gm.group_id = $1
-  AND gm.user_id = $2
-  AND g.deleted_at IS NULL
+const closeOpenShiftTradesByRequesterInGroup = `-- name: CloseOpenShiftTradesByRequesterInGroup :many
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE requester_id = $3
+      AND group_id = $4
+      AND status = 'OPEN'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'OPEN', 'CLOSED', $1, $2
+FROM closed
+RETURNING trade_id
 `
 
-type GetGroupMemberParams struct {
-	GroupID uuid.UUID `json:"group_id"`
-	UserID  uuid.UUID `json:"user_id"`
+type CloseOpenShiftTradesByRequesterInGroupParams struct {
+	ActorID     uuid.NullUUID `json:"actor_id"`
+	Reason      string        `json:"reason"`
+	RequesterID uuid.UUID     `json:"requester_id"`
+	GroupID     uuid.UUID     `json:"group_id"`
 }
 
-// グループ所属チェック
-func (q *Queries) GetGroupMember(ctx context.Context, arg GetGroupMemberParams) (GroupMember, error) {
-	row := q.db.QueryRowContext(ctx, getGroupMember, arg.GroupID, arg.UserID)
-	var i GroupMember
-	err := row.Scan(
-		&i.UserID,
-		&i.GroupID,
-		&i.Role,
-		&i.JoinedAt,
+// グループを抜けるメンバーが作成した「募集中(OPEN)」の募集を全てCLOSEDにする（履歴も記録し、閉じた募集の id を返す）
+func (q *Queries) CloseOpenShiftTradesByRequesterInGroup(ctx context.Context, arg CloseOpenShiftTradesByRequesterInGroupParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, closeOpenShiftTradesByRequesterInGroup,
+		arg.ActorID,
+		arg.Reason,
+		arg.RequesterID,
+		arg.GroupID,
 	)
-	return i, err
-}
-
-const getGroupMemberLineIDs = `-- name: GetGroupMemberLineIDs :many
-SELECT u.line_user_id
-FROM group_members gm
-         JOIN users u ON gm.user_id = u.id
-WHERE gm.group_id = $1
-  AND u.line_user_id IS NOT NULL
-  AND u.line_user_id != ''
-`
-
-// グループメンバー全員のLINE IDを取得 (通知用)
-func (q *Queries) GetGroupMemberLineIDs(ctx context.Context, groupID uuid.UUID) ([]string, error) {
-	rows, err := q.db.QueryContext(ctx, getGroupMemberLineIDs, groupID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []string
+	var items []uuid.UUID
 	for rows.Next() {
-		var line_user_id string
-		if err := rows.Scan(&line_user_id); err != nil {
+		var trade_id uuid.UUID
+		if err := rows.Scan(&trade_id); err != nil {
 			return nil, err
 		}
-		items = append(items, line_user_id)
+		items = append(items, trade_id)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -298,30 +498,206 @@ func (q *Queries) GetGroupMemberLineIDs(ctx context.Context, groupID uuid.UUID)
 	return items, nil
 }
 
-const getGroupName = `-- name: GetGroupName :one
-SELECT name FROM job_groups
-WHERE id = $1
-  AND deleted_at IS NULL
+const closeOwnOpenShiftTrade = `-- name: CloseOwnOpenShiftTrade :one
+UPDATE shift_trades
+SET status = 'CLOSED',
+    updated_at = NOW()
+WHERE id = $1 AND requester_id = $2 AND status = 'OPEN'
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
 `
 
-// グループ名取得
-func (q *Queries) GetGroupName(ctx context.Context, id uuid.UUID) (string, error) {
-	row := q.db.QueryRowContext(ctx, getGroupName, id)
-	var name string
-	err := row.Scan(&name)
-	return name, err
+type CloseOwnOpenShiftTradeParams struct {
+	ID          uuid.UUID `json:"id"`
+	RequesterID uuid.UUID `json:"requester_id"`
 }
 
-const getJobGroupByCode = `-- name: GetJobGroupByCode :one
-SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at FROM job_groups
-WHERE invitation_code = $1
-  AND deleted_at IS NULL
-LIMIT 1
+// 作成者による募集中(OPEN)の募集の取り消し（OPEN → CLOSED。履歴を残すため行は消さない）
+func (q *Queries) CloseOwnOpenShiftTrade(ctx context.Context, arg CloseOwnOpenShiftTradeParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, closeOwnOpenShiftTrade, arg.ID, arg.RequesterID)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const closeSwapReciprocalTrades = `-- name: CloseSwapReciprocalTrades :execrows
+WITH closed AS (
+    UPDATE shift_trades
+    SET status = 'CLOSED',
+        updated_at = NOW()
+    WHERE swap_parent_id = $1
+      AND status = 'FILLED'
+    RETURNING id
+)
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+SELECT id, 'FILLED', 'CLOSED', $2, 'SWAP_CANCELLED'
+FROM closed
 `
 
-// 招待コードでグループ検索
-func (q *Queries) GetJobGroupByCode(ctx context.Context, invitationCode string) (JobGroup, error) {
-	row := q.db.QueryRowContext(ctx, getJobGroupByCode, invitationCode)
+type CloseSwapReciprocalTradesParams struct {
+	SwapParentID uuid.NullUUID `json:"swap_parent_id"`
+	ActorID      uuid.NullUUID `json:"actor_id"`
+}
+
+// 交換の引き受けが取り消されたとき「お返し」の募集をCLOSEDにする（履歴も記録）
+func (q *Queries) CloseSwapReciprocalTrades(ctx context.Context, arg CloseSwapReciprocalTradesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, closeSwapReciprocalTrades, arg.SwapParentID, arg.ActorID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countNotificationsByStatus = `-- name: CountNotificationsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM notification_outbox
+GROUP BY status
+ORDER BY status
+`
+
+type CountNotificationsByStatusRow struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// 状態ごとの件数（確認用）
+func (q *Queries) CountNotificationsByStatus(ctx context.Context) ([]CountNotificationsByStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countNotificationsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountNotificationsByStatusRow
+	for rows.Next() {
+		var i CountNotificationsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countOtherActiveMembers = `-- name: CountOtherActiveMembers :one
+SELECT COUNT(*)
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.user_id != $2
+  AND gm.status = 'ACTIVE'
+  AND u.deleted_at IS NULL
+`
+
+type CountOtherActiveMembersParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// 指定ユーザー以外のメンバー数（退会済みユーザーは除く）
+func (q *Queries) CountOtherActiveMembers(ctx context.Context, arg CountOtherActiveMembersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOtherActiveMembers, arg.GroupID, arg.UserID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createCounterShift = `-- name: CreateCounterShift :one
+INSERT INTO shift_trade_counter_shifts (trade_id, shift_start_at, shift_end_at)
+VALUES ($1, $2, $3)
+RETURNING id, trade_id, shift_start_at, shift_end_at, created_at
+`
+
+type CreateCounterShiftParams struct {
+	TradeID      uuid.UUID `json:"trade_id"`
+	ShiftStartAt time.Time `json:"shift_start_at"`
+	ShiftEndAt   time.Time `json:"shift_end_at"`
+}
+
+// 交換募集の差し出しシフトを登録
+func (q *Queries) CreateCounterShift(ctx context.Context, arg CreateCounterShiftParams) (ShiftTradeCounterShift, error) {
+	row := q.db.QueryRowContext(ctx, createCounterShift, arg.TradeID, arg.ShiftStartAt, arg.ShiftEndAt)
+	var i ShiftTradeCounterShift
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createGroupMember = `-- name: CreateGroupMember :one
+INSERT INTO group_members (user_id, group_id, role, status)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, group_id) DO NOTHING
+    RETURNING user_id, group_id, role, joined_at, status, approved_by
+`
+
+type CreateGroupMemberParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	GroupID uuid.UUID `json:"group_id"`
+	Role    string    `json:"role"`
+	Status  string    `json:"status"`
+}
+
+// グループ参加（すでにメンバー・申請中なら何もせず sql.ErrNoRows）
+func (q *Queries) CreateGroupMember(ctx context.Context, arg CreateGroupMemberParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, createGroupMember,
+		arg.UserID,
+		arg.GroupID,
+		arg.Role,
+		arg.Status,
+	)
+	var i GroupMember
+	err := row.Scan(
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
+	)
+	return i, err
+}
+
+const createJobGroup = `-- name: CreateJobGroup :one
+INSERT INTO job_groups (name, invitation_code, owner_id)
+VALUES ($1, $2, $3)
+    RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type CreateJobGroupParams struct {
+	Name           string    `json:"name"`
+	InvitationCode string    `json:"invitation_code"`
+	OwnerID        uuid.UUID `json:"owner_id"`
+}
+
+// グループ作成
+func (q *Queries) CreateJobGroup(ctx context.Context, arg CreateJobGroupParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, createJobGroup, arg.Name, arg.InvitationCode, arg.OwnerID)
 	var i JobGroup
 	err := row.Scan(
 		&i.ID,
@@ -331,39 +707,163 @@ func (q *Queries) GetJobGroupByCode(ctx context.Context, invitationCode string)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
 	)
 	return i, err
 }
 
-const getJobGroupByID = `-- name: GetJobGroupByID :one
-SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at FROM job_groups
-WHERE id = $1
-  AND deleted_at IS NULL
+const createShiftTrade = `-- name: CreateShiftTrade :one
+INSERT INTO shift_trades (
+    group_id, requester_id, shift_start_at, shift_end_at, bounty_description, trade_type, segment_minutes
+) VALUES (
+             $1, $2, $3, $4, $5, $6, $7
+         )
+    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
 `
 
-// IDでグループ情報を取得 (画面表示用)
-func (q *Queries) GetJobGroupByID(ctx context.Context, id uuid.UUID) (JobGroup, error) {
-	row := q.db.QueryRowContext(ctx, getJobGroupByID, id)
-	var i JobGroup
+type CreateShiftTradeParams struct {
+	GroupID           uuid.UUID `json:"group_id"`
+	RequesterID       uuid.UUID `json:"requester_id"`
+	ShiftStartAt      time.Time `json:"shift_start_at"`
+	ShiftEndAt        time.Time `json:"shift_end_at"`
+	BountyDescription string    `json:"bounty_description"`
+	TradeType         string    `json:"trade_type"`
+	SegmentMinutes    int32     `json:"segment_minutes"`
+}
+
+// シフト交代リクエスト作成
+func (q *Queries) CreateShiftTrade(ctx context.Context, arg CreateShiftTradeParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, createShiftTrade,
+		arg.GroupID,
+		arg.RequesterID,
+		arg.ShiftStartAt,
+		arg.ShiftEndAt,
+		arg.BountyDescription,
+		arg.TradeType,
+		arg.SegmentMinutes,
+	)
+	var i ShiftTrade
 	err := row.Scan(
 		&i.ID,
-		&i.Name,
-		&i.InvitationCode,
-		&i.OwnerID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.DeletedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
 	)
 	return i, err
 }
 
-const getTradeByID = `-- name: GetTradeByID :one
-SELECT id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details FROM shift_trades WHERE id = $1
+const createShiftTradeEvent = `-- name: CreateShiftTradeEvent :one
+INSERT INTO shift_trade_events (trade_id, from_status, to_status, actor_id, reason)
+VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, trade_id, from_status, to_status, actor_id, reason, created_at
 `
 
-// シフト交代リクエストを id で取得
-func (q *Queries) GetTradeByID(ctx context.Context, id uuid.UUID) (ShiftTrade, error) {
-	row := q.db.QueryRowContext(ctx, getTradeByID, id)
+type CreateShiftTradeEventParams struct {
+	TradeID    uuid.UUID      `json:"trade_id"`
+	FromStatus sql.NullString `json:"from_status"`
+	ToStatus   string         `json:"to_status"`
+	ActorID    uuid.NullUUID  `json:"actor_id"`
+	Reason     string         `json:"reason"`
+}
+
+// シフト交代リクエストの状態遷移を記録
+func (q *Queries) CreateShiftTradeEvent(ctx context.Context, arg CreateShiftTradeEventParams) (ShiftTradeEvent, error) {
+	row := q.db.QueryRowContext(ctx, createShiftTradeEvent,
+		arg.TradeID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.ActorID,
+		arg.Reason,
+	)
+	var i ShiftTradeEvent
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.ActorID,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createShiftTradeSegment = `-- name: CreateShiftTradeSegment :one
+INSERT INTO shift_trade_segments (trade_id, segment_start_at, segment_end_at)
+VALUES ($1, $2, $3)
+RETURNING id, trade_id, segment_start_at, segment_end_at, acceptor_id, accepted_at, created_at
+`
+
+type CreateShiftTradeSegmentParams struct {
+	TradeID        uuid.UUID `json:"trade_id"`
+	SegmentStartAt time.Time `json:"segment_start_at"`
+	SegmentEndAt   time.Time `json:"segment_end_at"`
+}
+
+// 分割募集の枠を登録
+func (q *Queries) CreateShiftTradeSegment(ctx context.Context, arg CreateShiftTradeSegmentParams) (ShiftTradeSegment, error) {
+	row := q.db.QueryRowContext(ctx, createShiftTradeSegment, arg.TradeID, arg.SegmentStartAt, arg.SegmentEndAt)
+	var i ShiftTradeSegment
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.SegmentStartAt,
+		&i.SegmentEndAt,
+		&i.AcceptorID,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSwapReciprocalTrade = `-- name: CreateSwapReciprocalTrade :one
+INSERT INTO shift_trades (
+    group_id, requester_id, acceptor_id, shift_start_at, shift_end_at,
+    bounty_description, trade_type, status, swap_parent_id
+) VALUES (
+             $1, $2, $3, $4, $5, '交換', 'SWAP', 'FILLED', $6
+         )
+    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+type CreateSwapReciprocalTradeParams struct {
+	GroupID      uuid.UUID     `json:"group_id"`
+	RequesterID  uuid.UUID     `json:"requester_id"`
+	AcceptorID   uuid.NullUUID `json:"acceptor_id"`
+	ShiftStartAt time.Time     `json:"shift_start_at"`
+	ShiftEndAt   time.Time     `json:"shift_end_at"`
+	SwapParentID uuid.NullUUID `json:"swap_parent_id"`
+}
+
+// 交換成立時に「お返し」の成立済み募集を作る（引き受け者のシフトを作成者が引き受ける）
+func (q *Queries) CreateSwapReciprocalTrade(ctx context.Context, arg CreateSwapReciprocalTradeParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, createSwapReciprocalTrade,
+		arg.GroupID,
+		arg.RequesterID,
+		arg.AcceptorID,
+		arg.ShiftStartAt,
+		arg.ShiftEndAt,
+		arg.SwapParentID,
+	)
 	var i ShiftTrade
 	err := row.Scan(
 		&i.ID,
@@ -378,93 +878,1278 @@ func (q *Queries) GetTradeByID(ctx context.Context, id uuid.UUID) (ShiftTrade, e
 		&i.UpdatedAt,
 		&i.IsPaid,
 		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const createTradeApplication = `-- name: CreateTradeApplication :one
+INSERT INTO trade_applications (trade_id, applicant_id)
+VALUES ($1, $2)
+ON CONFLICT (trade_id, applicant_id) DO UPDATE
+SET status = 'PENDING',
+    decided_by = NULL,
+    decided_at = NULL,
+    created_at = NOW()
+WHERE trade_applications.status NOT IN ('PENDING', 'APPROVED')
+RETURNING id, trade_id, applicant_id, status, decided_by, decided_at, created_at
+`
+
+type CreateTradeApplicationParams struct {
+	TradeID     uuid.UUID `json:"trade_id"`
+	ApplicantID uuid.UUID `json:"applicant_id"`
+}
+
+// シフト交代リクエストへ応募する
+// 取り下げ・却下・取り消し済みの応募は PENDING に戻す（応募中・承認済みなら何も返さない）
+func (q *Queries) CreateTradeApplication(ctx context.Context, arg CreateTradeApplicationParams) (TradeApplication, error) {
+	row := q.db.QueryRowContext(ctx, createTradeApplication, arg.TradeID, arg.ApplicantID)
+	var i TradeApplication
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.ApplicantID,
+		&i.Status,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+
+INSERT INTO users (line_user_id, display_name, profile_image_url)
+VALUES ($1, $2, $3)
+    RETURNING id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at, bot_reachable
+`
+
+type CreateUserParams struct {
+	LineUserID      string         `json:"line_user_id"`
+	DisplayName     string         `json:"display_name"`
+	ProfileImageUrl sql.NullString `json:"profile_image_url"`
+}
+
+// internal/database/query.sql
+// ユーザー作成
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.LineUserID, arg.DisplayName, arg.ProfileImageUrl)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.LineUserID,
+		&i.DisplayName,
+		&i.ProfileImageUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.BotReachable,
 	)
 	return i, err
 }
 
-const getUserByID = `-- name: GetUserByID :one
-SELECT id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at FROM users WHERE id = $1
+const declineCancelAcceptance = `-- name: DeclineCancelAcceptance :one
+UPDATE shift_trades
+SET cancel_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND acceptor_id = $2
+  AND status = 'FILLED'
+  AND cancel_requested_at IS NOT NULL
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+type DeclineCancelAcceptanceParams struct {
+	ID         uuid.UUID     `json:"id"`
+	AcceptorID uuid.NullUUID `json:"acceptor_id"`
+}
+
+// 引き受け者が取り消し依頼を断る
+func (q *Queries) DeclineCancelAcceptance(ctx context.Context, arg DeclineCancelAcceptanceParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, declineCancelAcceptance, arg.ID, arg.AcceptorID)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const deleteGroupMember = `-- name: DeleteGroupMember :execrows
+DELETE FROM group_members
+WHERE group_id = $1
+  AND user_id = $2
+`
+
+type DeleteGroupMemberParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// グループからメンバーを外す
+func (q *Queries) DeleteGroupMember(ctx context.Context, arg DeleteGroupMemberParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGroupMember, arg.GroupID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSentNotificationsBefore = `-- name: DeleteSentNotificationsBefore :execrows
+DELETE FROM notification_outbox
+WHERE status = 'SENT'
+  AND sent_at < $1
+`
+
+// 古い送信済みの通知を消す
+func (q *Queries) DeleteSentNotificationsBefore(ctx context.Context, sentAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSentNotificationsBefore, sentAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhookEventsBefore = `-- name: DeleteWebhookEventsBefore :execrows
+DELETE FROM webhook_events
+WHERE received_at < $1::timestamptz
+`
+
+// 古い Webhook イベントの記録を消す
+func (q *Queries) DeleteWebhookEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookEventsBefore, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueNotification = `-- name: EnqueueNotification :one
+INSERT INTO notification_outbox (kind, recipients, messages)
+VALUES ($1, $2, $3)
+RETURNING id
+`
+
+type EnqueueNotificationParams struct {
+	Kind       string          `json:"kind"`
+	Recipients []string        `json:"recipients"`
+	Messages   json.RawMessage `json:"messages"`
+}
+
+// 送信待ちの通知を積む
+func (q *Queries) EnqueueNotification(ctx context.Context, arg EnqueueNotificationParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, enqueueNotification, arg.Kind, pq.Array(arg.Recipients), arg.Messages)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const fillSplitShiftTrade = `-- name: FillSplitShiftTrade :one
+UPDATE shift_trades
+SET status = 'FILLED',
+    acceptor_id = (
+        SELECT s.acceptor_id
+        FROM shift_trade_segments s
+        WHERE s.trade_id = shift_trades.id
+        GROUP BY s.acceptor_id
+        HAVING COUNT(*) = (SELECT COUNT(*) FROM shift_trade_segments s2 WHERE s2.trade_id = shift_trades.id)
+    ),
+    updated_at = NOW()
+WHERE shift_trades.id = $1
+  AND shift_trades.status = 'OPEN'
+  AND NOT EXISTS (
+      SELECT 1
+      FROM shift_trade_segments s
+      WHERE s.trade_id = shift_trades.id
+        AND s.acceptor_id IS NULL
+  )
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+// 分割募集の全ての枠が埋まったら成立にする（OPEN → FILLED）
+// 1人で全枠を引き受けた場合だけ acceptor_id を入れる
+func (q *Queries) FillSplitShiftTrade(ctx context.Context, id uuid.UUID) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, fillSplitShiftTrade, id)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const getGroupAdminLineIDs = `-- name: GetGroupAdminLineIDs :many
+SELECT u.line_user_id
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND gm.role = 'ADMIN'
+  AND u.deleted_at IS NULL
+  AND u.bot_reachable
+  AND u.line_user_id IS NOT NULL
+  AND u.line_user_id != ''
+`
+
+// グループの ADMIN の LINE ID（参加申請の通知用）
+func (q *Queries) GetGroupAdminLineIDs(ctx context.Context, groupID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getGroupAdminLineIDs, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var line_user_id string
+		if err := rows.Scan(&line_user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, line_user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGroupMember = `-- name: GetGroupMember :one
+SELECT gm.user_id, gm.group_id, gm.role, gm.joined_at, gm.status, gm.approved_by
+FROM group_members gm
+         JOIN job_groups g ON g.id = gm.group_id
+WHERE gm.group_id = $1
+  AND gm.user_id = $2
+  AND gm.status = 'ACTIVE'
+  AND g.deleted_at IS NULL
+`
+
+type GetGroupMemberParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// グループ所属チェック（承認待ちは含まない）
+func (q *Queries) GetGroupMember(ctx context.Context, arg GetGroupMemberParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, getGroupMember, arg.GroupID, arg.UserID)
+	var i GroupMember
+	err := row.Scan(
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
+	)
+	return i, err
+}
+
+const getGroupMemberLineIDs = `-- name: GetGroupMemberLineIDs :many
+SELECT u.line_user_id
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND u.bot_reachable
+  AND u.line_user_id IS NOT NULL
+  AND u.line_user_id != ''
+`
+
+// グループメンバー全員のLINE IDを取得 (通知用、bot をブロックしている人は除く)
+func (q *Queries) GetGroupMemberLineIDs(ctx context.Context, groupID uuid.UUID) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getGroupMemberLineIDs, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var line_user_id string
+		if err := rows.Scan(&line_user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, line_user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getGroupMembership = `-- name: GetGroupMembership :one
+SELECT gm.user_id, gm.group_id, gm.role, gm.joined_at, gm.status, gm.approved_by
+FROM group_members gm
+         JOIN job_groups g ON g.id = gm.group_id
+WHERE gm.group_id = $1
+  AND gm.user_id = $2
+  AND g.deleted_at IS NULL
+`
+
+type GetGroupMembershipParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// 所属の取得（承認待ちを含む）
+func (q *Queries) GetGroupMembership(ctx context.Context, arg GetGroupMembershipParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, getGroupMembership, arg.GroupID, arg.UserID)
+	var i GroupMember
+	err := row.Scan(
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
+	)
+	return i, err
+}
+
+const getGroupName = `-- name: GetGroupName :one
+SELECT name FROM job_groups
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+// グループ名取得
+func (q *Queries) GetGroupName(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getGroupName, id)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+const getJobGroupByChatID = `-- name: GetJobGroupByChatID :one
+SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode FROM job_groups
+WHERE line_chat_id = $1
+  AND deleted_at IS NULL
+LIMIT 1
+`
+
+// 連携しているトークからグループを検索
+func (q *Queries) GetJobGroupByChatID(ctx context.Context, lineChatID sql.NullString) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, getJobGroupByChatID, lineChatID)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const getJobGroupByCode = `-- name: GetJobGroupByCode :one
+SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode FROM job_groups
+WHERE invitation_code = $1
+  AND deleted_at IS NULL
+LIMIT 1
+`
+
+// 招待コードでグループ検索
+func (q *Queries) GetJobGroupByCode(ctx context.Context, invitationCode string) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, getJobGroupByCode, invitationCode)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const getJobGroupByID = `-- name: GetJobGroupByID :one
+SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode FROM job_groups
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+// IDでグループ情報を取得 (画面表示用)
+func (q *Queries) GetJobGroupByID(ctx context.Context, id uuid.UUID) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, getJobGroupByID, id)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const getLongestStandingAdmin = `-- name: GetLongestStandingAdmin :one
+SELECT gm.user_id, gm.group_id, gm.role, gm.joined_at, gm.status, gm.approved_by
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.user_id != $2
+  AND gm.status = 'ACTIVE'
+  AND gm.role = 'ADMIN'
+  AND u.deleted_at IS NULL
+ORDER BY gm.joined_at ASC
+LIMIT 1
+`
+
+type GetLongestStandingAdminParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// owner 以外で最も古くからいる ADMIN（退会時の自動引き継ぎ先）
+func (q *Queries) GetLongestStandingAdmin(ctx context.Context, arg GetLongestStandingAdminParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, getLongestStandingAdmin, arg.GroupID, arg.UserID)
+	var i GroupMember
+	err := row.Scan(
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
+	)
+	return i, err
+}
+
+const getTradeApplication = `-- name: GetTradeApplication :one
+SELECT id, trade_id, applicant_id, status, decided_by, decided_at, created_at FROM trade_applications WHERE id = $1
+`
+
+// 応募を id で取得
+func (q *Queries) GetTradeApplication(ctx context.Context, id uuid.UUID) (TradeApplication, error) {
+	row := q.db.QueryRowContext(ctx, getTradeApplication, id)
+	var i TradeApplication
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.ApplicantID,
+		&i.Status,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTradeByID = `-- name: GetTradeByID :one
+SELECT id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes FROM shift_trades WHERE id = $1
+`
+
+// シフト交代リクエストを id で取得
+func (q *Queries) GetTradeByID(ctx context.Context, id uuid.UUID) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, getTradeByID, id)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const getTradeByIDForUpdate = `-- name: GetTradeByIDForUpdate :one
+SELECT id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes FROM shift_trades WHERE id = $1 FOR UPDATE
+`
+
+// シフト交代リクエストを id で取得して行ロックする（トランザクション内で使う）
+func (q *Queries) GetTradeByIDForUpdate(ctx context.Context, id uuid.UUID) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, getTradeByIDForUpdate, id)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at, bot_reachable FROM users WHERE id = $1
+`
+
+// IDでユーザー情報を取得 (画面表示用)
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.LineUserID,
+		&i.DisplayName,
+		&i.ProfileImageUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.BotReachable,
+	)
+	return i, err
+}
+
+const getUserByLineID = `-- name: GetUserByLineID :one
+SELECT id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at, bot_reachable FROM users
+WHERE line_user_id = $1
+  AND deleted_at IS NULL
+    LIMIT 1
+`
+
+// LINE IDでユーザー取得
+func (q *Queries) GetUserByLineID(ctx context.Context, lineUserID string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByLineID, lineUserID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.LineUserID,
+		&i.DisplayName,
+		&i.ProfileImageUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.BotReachable,
+	)
+	return i, err
+}
+
+const linkJobGroupChat = `-- name: LinkJobGroupChat :one
+UPDATE job_groups
+SET line_chat_id = $1,
+    notify_mode = 'CHAT',
+    updated_at = NOW()
+WHERE id = $2
+  AND deleted_at IS NULL
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type LinkJobGroupChatParams struct {
+	LineChatID sql.NullString `json:"line_chat_id"`
+	ID         uuid.UUID      `json:"id"`
+}
+
+// LINE のトークとグループを連携し、通知をトークに投稿するようにする
+func (q *Queries) LinkJobGroupChat(ctx context.Context, arg LinkJobGroupChatParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, linkJobGroupChat, arg.LineChatID, arg.ID)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const listAcceptorConflicts = `-- name: ListAcceptorConflicts :many
+SELECT t.id AS trade_id, t.group_id, g.name AS group_name,
+       t.shift_start_at AS start_at, t.shift_end_at AS end_at
+FROM shift_trades t
+         JOIN job_groups g ON t.group_id = g.id
+WHERE t.acceptor_id = $1
+  AND t.status IN ('FILLED', 'COMPLETED')
+  AND t.segment_minutes = 0
+  AND t.id <> $2
+  AND t.shift_start_at < $3
+  AND t.shift_end_at > $4
+UNION ALL
+SELECT t.id AS trade_id, t.group_id, g.name AS group_name,
+       s.segment_start_at AS start_at, s.segment_end_at AS end_at
+FROM shift_trade_segments s
+         JOIN shift_trades t ON s.trade_id = t.id
+         JOIN job_groups g ON t.group_id = g.id
+WHERE s.acceptor_id = $1
+  AND t.status IN ('OPEN', 'FILLED', 'COMPLETED')
+  AND t.id <> $2
+  AND s.segment_start_at < $3
+  AND s.segment_end_at > $4
+ORDER BY start_at ASC
+`
+
+type ListAcceptorConflictsParams struct {
+	AcceptorID     uuid.NullUUID `json:"acceptor_id"`
+	ExcludeTradeID uuid.UUID     `json:"exclude_trade_id"`
+	EndAt          time.Time     `json:"end_at"`
+	StartAt        time.Time     `json:"start_at"`
+}
+
+type ListAcceptorConflictsRow struct {
+	TradeID   uuid.UUID `json:"trade_id"`
+	GroupID   uuid.UUID `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+}
+
+// 引き受け者がすでに引き受けている時間帯と重なるものを取得（グループをまたいで確認）
+// 成立済み(FILLED)・支払い済み(COMPLETED)の募集と、分割募集で引き受けた枠が対象
+func (q *Queries) ListAcceptorConflicts(ctx context.Context, arg ListAcceptorConflictsParams) ([]ListAcceptorConflictsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAcceptorConflicts,
+		arg.AcceptorID,
+		arg.ExcludeTradeID,
+		arg.EndAt,
+		arg.StartAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAcceptorConflictsRow
+	for rows.Next() {
+		var i ListAcceptorConflictsRow
+		if err := rows.Scan(
+			&i.TradeID,
+			&i.GroupID,
+			&i.GroupName,
+			&i.StartAt,
+			&i.EndAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCounterShifts = `-- name: ListCounterShifts :many
+SELECT id, trade_id, shift_start_at, shift_end_at, created_at FROM shift_trade_counter_shifts
+WHERE trade_id = $1
+ORDER BY shift_start_at ASC
+`
+
+// 交換募集の差し出しシフト一覧
+func (q *Queries) ListCounterShifts(ctx context.Context, tradeID uuid.UUID) ([]ShiftTradeCounterShift, error) {
+	rows, err := q.db.QueryContext(ctx, listCounterShifts, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShiftTradeCounterShift
+	for rows.Next() {
+		var i ShiftTradeCounterShift
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.ShiftStartAt,
+			&i.ShiftEndAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCounterShiftsForOpenTrades = `-- name: ListCounterShiftsForOpenTrades :many
+SELECT cs.id, cs.trade_id, cs.shift_start_at, cs.shift_end_at, cs.created_at
+FROM shift_trade_counter_shifts cs
+         JOIN shift_trades t ON cs.trade_id = t.id
+WHERE t.group_id = $1
+  AND t.status = 'OPEN'
+ORDER BY cs.shift_start_at ASC
+`
+
+// グループ内の募集中(OPEN)の交換募集の差し出しシフト一覧（ボード表示用）
+func (q *Queries) ListCounterShiftsForOpenTrades(ctx context.Context, groupID uuid.UUID) ([]ShiftTradeCounterShift, error) {
+	rows, err := q.db.QueryContext(ctx, listCounterShiftsForOpenTrades, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShiftTradeCounterShift
+	for rows.Next() {
+		var i ShiftTradeCounterShift
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.ShiftStartAt,
+			&i.ShiftEndAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeadNotifications = `-- name: ListDeadNotifications :many
+SELECT id, kind, attempts, created_at, updated_at
+FROM notification_outbox
+WHERE status = 'DEAD'
+ORDER BY updated_at DESC
+LIMIT $1
+`
+
+type ListDeadNotificationsRow struct {
+	ID        uuid.UUID `json:"id"`
+	Kind      string    `json:"kind"`
+	Attempts  int32     `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DEAD になった通知（新しい順、確認用。宛先やエラー内容は返さない）
+func (q *Queries) ListDeadNotifications(ctx context.Context, limit int32) ([]ListDeadNotificationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDeadNotifications, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDeadNotificationsRow
+	for rows.Next() {
+		var i ListDeadNotificationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Attempts,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueTradeReminders = `-- name: ListDueTradeReminders :many
+SELECT t.id, t.group_id, t.requester_id, t.shift_start_at, t.shift_end_at, t.segment_minutes,
+       g.name AS group_name, u.line_user_id, u.bot_reachable,
+       MIN(o.offset_minutes)::int AS offset_minutes
+FROM shift_trades t
+         JOIN job_groups g ON t.group_id = g.id
+         JOIN users u ON t.requester_id = u.id
+         CROSS JOIN LATERAL unnest(g.reminder_offsets_minutes) AS o(offset_minutes)
+WHERE t.status = 'OPEN'
+  AND t.shift_start_at > $1::timestamptz
+  AND t.shift_start_at - make_interval(mins => o.offset_minutes) <= $1::timestamptz
+  AND g.deleted_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1
+      FROM shift_trade_reminders r
+      WHERE r.trade_id = t.id
+        AND r.offset_minutes <= o.offset_minutes
+  )
+GROUP BY t.id, g.name, u.line_user_id, u.bot_reachable
+ORDER BY t.shift_start_at ASC
+`
+
+type ListDueTradeRemindersRow struct {
+	ID             uuid.UUID `json:"id"`
+	GroupID        uuid.UUID `json:"group_id"`
+	RequesterID    uuid.UUID `json:"requester_id"`
+	ShiftStartAt   time.Time `json:"shift_start_at"`
+	ShiftEndAt     time.Time `json:"shift_end_at"`
+	SegmentMinutes int32     `json:"segment_minutes"`
+	GroupName      string    `json:"group_name"`
+	LineUserID     string    `json:"line_user_id"`
+	BotReachable   bool      `json:"bot_reachable"`
+	OffsetMinutes  int32     `json:"offset_minutes"`
+}
+
+// リマインドを送る時期を過ぎた未成立シフトを取得 (リマインド通知用)
+// 募集ごとに、時期を過ぎた段階のうち最も開始に近いもの（offset_minutes が最小）を1つ返す
+// それ以下の段階を送信済みの募集は対象外（停止中に過ぎた段階をまとめて送らない）
+func (q *Queries) ListDueTradeReminders(ctx context.Context, now time.Time) ([]ListDueTradeRemindersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDueTradeReminders, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDueTradeRemindersRow
+	for rows.Next() {
+		var i ListDueTradeRemindersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupID,
+			&i.RequesterID,
+			&i.ShiftStartAt,
+			&i.ShiftEndAt,
+			&i.SegmentMinutes,
+			&i.GroupName,
+			&i.LineUserID,
+			&i.BotReachable,
+			&i.OffsetMinutes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupMembers = `-- name: ListGroupMembers :many
+SELECT gm.user_id, u.display_name, u.profile_image_url, gm.role, gm.joined_at,
+       (g.owner_id = gm.user_id) AS is_owner, u.bot_reachable
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+         JOIN job_groups g ON gm.group_id = g.id
+WHERE gm.group_id = $1
+  AND gm.status = 'ACTIVE'
+  AND u.deleted_at IS NULL
+  AND g.deleted_at IS NULL
+ORDER BY gm.joined_at ASC
+`
+
+type ListGroupMembersRow struct {
+	UserID          uuid.UUID      `json:"user_id"`
+	DisplayName     string         `json:"display_name"`
+	ProfileImageUrl sql.NullString `json:"profile_image_url"`
+	Role            string         `json:"role"`
+	JoinedAt        time.Time      `json:"joined_at"`
+	IsOwner         bool           `json:"is_owner"`
+	BotReachable    bool           `json:"bot_reachable"`
+}
+
+// グループのメンバー一覧（参加順）
+func (q *Queries) ListGroupMembers(ctx context.Context, groupID uuid.UUID) ([]ListGroupMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupMembers, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGroupMembersRow
+	for rows.Next() {
+		var i ListGroupMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.DisplayName,
+			&i.ProfileImageUrl,
+			&i.Role,
+			&i.JoinedAt,
+			&i.IsOwner,
+			&i.BotReachable,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobGroupsOwnedBy = `-- name: ListJobGroupsOwnedBy :many
+SELECT id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode FROM job_groups
+WHERE owner_id = $1
+  AND deleted_at IS NULL
+ORDER BY created_at ASC
+`
+
+// ユーザーが owner のグループ一覧（退会時のオーナー引き継ぎ用）
+func (q *Queries) ListJobGroupsOwnedBy(ctx context.Context, ownerID uuid.UUID) ([]JobGroup, error) {
+	rows, err := q.db.QueryContext(ctx, listJobGroupsOwnedBy, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []JobGroup
+	for rows.Next() {
+		var i JobGroup
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.InvitationCode,
+			&i.OwnerID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.AcceptMode,
+			&i.InvitationExpiresAt,
+			&i.InvitationMaxUses,
+			&i.InvitationUseCount,
+			&i.RequireJoinApproval,
+			pq.Array(&i.ReminderOffsetsMinutes),
+			&i.LineChatID,
+			&i.NotifyMode,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenShiftTradeSegments = `-- name: ListOpenShiftTradeSegments :many
+SELECT id, trade_id, segment_start_at, segment_end_at, acceptor_id, accepted_at, created_at FROM shift_trade_segments
+WHERE trade_id = $1
+  AND acceptor_id IS NULL
+ORDER BY segment_start_at ASC
+`
+
+// 分割募集のまだ埋まっていない枠（リマインド・成立判定用）
+func (q *Queries) ListOpenShiftTradeSegments(ctx context.Context, tradeID uuid.UUID) ([]ShiftTradeSegment, error) {
+	rows, err := q.db.QueryContext(ctx, listOpenShiftTradeSegments, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShiftTradeSegment
+	for rows.Next() {
+		var i ShiftTradeSegment
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.SegmentStartAt,
+			&i.SegmentEndAt,
+			&i.AcceptorID,
+			&i.AcceptedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenShiftTrades = `-- name: ListOpenShiftTrades :many
+SELECT
+    t.id, t.shift_start_at, t.shift_end_at, t.bounty_description, t.created_at, t.trade_type, t.segment_minutes,
+    u.display_name as requester_name,
+    u.profile_image_url as requester_image,
+    u.bot_reachable as requester_bot_reachable
+FROM shift_trades t
+         JOIN users u ON t.requester_id = u.id
+WHERE t.group_id = $1 AND t.status = 'OPEN'
+  AND t.shift_start_at > NOW()
+ORDER BY t.shift_start_at ASC
+`
+
+type ListOpenShiftTradesRow struct {
+	ID                    uuid.UUID      `json:"id"`
+	ShiftStartAt          time.Time      `json:"shift_start_at"`
+	ShiftEndAt            time.Time      `json:"shift_end_at"`
+	BountyDescription     string         `json:"bounty_description"`
+	CreatedAt             time.Time      `json:"created_at"`
+	TradeType             string         `json:"trade_type"`
+	SegmentMinutes        int32          `json:"segment_minutes"`
+	RequesterName         string         `json:"requester_name"`
+	RequesterImage        sql.NullString `json:"requester_image"`
+	RequesterBotReachable bool           `json:"requester_bot_reachable"`
+}
+
+// そのグループの「募集中(OPEN)」のシフト一覧を取得
+func (q *Queries) ListOpenShiftTrades(ctx context.Context, groupID uuid.UUID) ([]ListOpenShiftTradesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listOpenShiftTrades, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOpenShiftTradesRow
+	for rows.Next() {
+		var i ListOpenShiftTradesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShiftStartAt,
+			&i.ShiftEndAt,
+			&i.BountyDescription,
+			&i.CreatedAt,
+			&i.TradeType,
+			&i.SegmentMinutes,
+			&i.RequesterName,
+			&i.RequesterImage,
+			&i.RequesterBotReachable,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingGroupMembers = `-- name: ListPendingGroupMembers :many
+SELECT gm.user_id, u.display_name, u.profile_image_url, gm.joined_at AS requested_at
+FROM group_members gm
+         JOIN users u ON gm.user_id = u.id
+WHERE gm.group_id = $1
+  AND gm.status = 'PENDING'
+  AND u.deleted_at IS NULL
+ORDER BY gm.joined_at ASC
+`
+
+type ListPendingGroupMembersRow struct {
+	UserID          uuid.UUID      `json:"user_id"`
+	DisplayName     string         `json:"display_name"`
+	ProfileImageUrl sql.NullString `json:"profile_image_url"`
+	RequestedAt     time.Time      `json:"requested_at"`
+}
+
+// 承認待ちの参加申請一覧（申請順）
+func (q *Queries) ListPendingGroupMembers(ctx context.Context, groupID uuid.UUID) ([]ListPendingGroupMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPendingGroupMembers, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPendingGroupMembersRow
+	for rows.Next() {
+		var i ListPendingGroupMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.DisplayName,
+			&i.ProfileImageUrl,
+			&i.RequestedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSegmentsForOpenTrades = `-- name: ListSegmentsForOpenTrades :many
+SELECT s.id, s.trade_id, s.segment_start_at, s.segment_end_at, s.acceptor_id, s.accepted_at,
+       u.display_name AS acceptor_name
+FROM shift_trade_segments s
+         JOIN shift_trades t ON s.trade_id = t.id
+         LEFT JOIN users u ON s.acceptor_id = u.id
+WHERE t.group_id = $1
+  AND t.status = 'OPEN'
+ORDER BY s.segment_start_at ASC
 `
 
-// IDでユーザー情報を取得 (画面表示用)
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByID, id)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.LineUserID,
-		&i.DisplayName,
-		&i.ProfileImageUrl,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.DeletedAt,
-	)
-	return i, err
+type ListSegmentsForOpenTradesRow struct {
+	ID             uuid.UUID      `json:"id"`
+	TradeID        uuid.UUID      `json:"trade_id"`
+	SegmentStartAt time.Time      `json:"segment_start_at"`
+	SegmentEndAt   time.Time      `json:"segment_end_at"`
+	AcceptorID     uuid.NullUUID  `json:"acceptor_id"`
+	AcceptedAt     sql.NullTime   `json:"accepted_at"`
+	AcceptorName   sql.NullString `json:"acceptor_name"`
 }
 
-const getUserByLineID = `-- name: GetUserByLineID :one
-SELECT id, line_user_id, display_name, profile_image_url, created_at, updated_at, deleted_at FROM users
-WHERE line_user_id = $1
-  AND deleted_at IS NULL
-    LIMIT 1
-`
-
-// LINE IDでユーザー取得
-func (q *Queries) GetUserByLineID(ctx context.Context, lineUserID string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByLineID, lineUserID)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.LineUserID,
-		&i.DisplayName,
-		&i.ProfileImageUrl,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.DeletedAt,
-	)
-	return i, err
+// グループ内の募集中(OPEN)の分割募集の枠一覧（ボード表示用）
+func (q *Queries) ListSegmentsForOpenTrades(ctx context.Context, groupID uuid.UUID) ([]ListSegmentsForOpenTradesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSegmentsForOpenTrades, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSegmentsForOpenTradesRow
+	for rows.Next() {
+		var i ListSegmentsForOpenTradesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.SegmentStartAt,
+			&i.SegmentEndAt,
+			&i.AcceptorID,
+			&i.AcceptedAt,
+			&i.AcceptorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const listOpenShiftTrades = `-- name: ListOpenShiftTrades :many
-SELECT
-    t.id, t.shift_start_at, t.shift_end_at, t.bounty_description, t.created_at,
-    u.display_name as requester_name,
-    u.profile_image_url as requester_image
-FROM shift_trades t
-         JOIN users u ON t.requester_id = u.id
-WHERE t.group_id = $1 AND t.status = 'OPEN'
-  AND t.shift_start_at > NOW()
-ORDER BY t.shift_start_at ASC
+const listShiftTradeEvents = `-- name: ListShiftTradeEvents :many
+SELECT e.id, e.trade_id, e.from_status, e.to_status, e.actor_id, e.reason, e.created_at,
+       u.display_name AS actor_name
+FROM shift_trade_events e
+         LEFT JOIN users u ON e.actor_id = u.id
+WHERE e.trade_id = $1
+ORDER BY e.created_at ASC, e.id ASC
 `
 
-type ListOpenShiftTradesRow struct {
-	ID                uuid.UUID      `json:"id"`
-	ShiftStartAt      time.Time      `json:"shift_start_at"`
-	ShiftEndAt        time.Time      `json:"shift_end_at"`
-	BountyDescription string         `json:"bounty_description"`
-	CreatedAt         time.Time      `json:"created_at"`
-	RequesterName     string         `json:"requester_name"`
-	RequesterImage    sql.NullString `json:"requester_image"`
+type ListShiftTradeEventsRow struct {
+	ID         uuid.UUID      `json:"id"`
+	TradeID    uuid.UUID      `json:"trade_id"`
+	FromStatus sql.NullString `json:"from_status"`
+	ToStatus   string         `json:"to_status"`
+	ActorID    uuid.NullUUID  `json:"actor_id"`
+	Reason     string         `json:"reason"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ActorName  sql.NullString `json:"actor_name"`
 }
 
-// そのグループの「募集中(OPEN)」のシフト一覧を取得
-func (q *Queries) ListOpenShiftTrades(ctx context.Context, groupID uuid.UUID) ([]ListOpenShiftTradesRow, error) {
-	rows, err := q.db.QueryContext(ctx, listOpenShiftTrades, groupID)
+// シフト交代リクエストの状態遷移履歴を取得（古い順）
+func (q *Queries) ListShiftTradeEvents(ctx context.Context, tradeID uuid.UUID) ([]ListShiftTradeEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listShiftTradeEvents, tradeID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListOpenShiftTradesRow
+	var items []ListShiftTradeEventsRow
 	for rows.Next() {
-		var i ListOpenShiftTradesRow
+		var i ListShiftTradeEventsRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.ShiftStartAt,
-			&i.ShiftEndAt,
-			&i.BountyDescription,
+			&i.TradeID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.ActorID,
+			&i.Reason,
 			&i.CreatedAt,
-			&i.RequesterName,
-			&i.RequesterImage,
+			&i.ActorName,
 		); err != nil {
 			return nil, err
 		}
@@ -479,60 +2164,100 @@ func (q *Queries) ListOpenShiftTrades(ctx context.Context, groupID uuid.UUID) ([
 	return items, nil
 }
 
-const listUnfilledShiftsInWindow = `-- name: ListUnfilledShiftsInWindow :many
-SELECT t.id, t.group_id, t.requester_id, t.acceptor_id, t.shift_start_at, t.shift_end_at, t.bounty_description, t.status, t.created_at, t.updated_at, t.is_paid, t.details, u.line_user_id
-FROM shift_trades t
-         JOIN users u ON t.requester_id = u.id
-WHERE t.status = 'OPEN'
-  AND t.shift_start_at >= $1
-  AND t.shift_start_at < $2
+const listShiftTradeSegments = `-- name: ListShiftTradeSegments :many
+SELECT s.id, s.trade_id, s.segment_start_at, s.segment_end_at, s.acceptor_id, s.accepted_at,
+       u.display_name AS acceptor_name
+FROM shift_trade_segments s
+         LEFT JOIN users u ON s.acceptor_id = u.id
+WHERE s.trade_id = $1
+ORDER BY s.segment_start_at ASC
 `
 
-type ListUnfilledShiftsInWindowParams struct {
-	ShiftStartAt   time.Time `json:"shift_start_at"`
-	ShiftStartAt_2 time.Time `json:"shift_start_at_2"`
+type ListShiftTradeSegmentsRow struct {
+	ID             uuid.UUID      `json:"id"`
+	TradeID        uuid.UUID      `json:"trade_id"`
+	SegmentStartAt time.Time      `json:"segment_start_at"`
+	SegmentEndAt   time.Time      `json:"segment_end_at"`
+	AcceptorID     uuid.NullUUID  `json:"acceptor_id"`
+	AcceptedAt     sql.NullTime   `json:"accepted_at"`
+	AcceptorName   sql.NullString `json:"acceptor_name"`
+}
+
+// 分割募集の枠一覧（引き受け者名つき）
+func (q *Queries) ListShiftTradeSegments(ctx context.Context, tradeID uuid.UUID) ([]ListShiftTradeSegmentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listShiftTradeSegments, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListShiftTradeSegmentsRow
+	for rows.Next() {
+		var i ListShiftTradeSegmentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TradeID,
+			&i.SegmentStartAt,
+			&i.SegmentEndAt,
+			&i.AcceptorID,
+			&i.AcceptedAt,
+			&i.AcceptorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-type ListUnfilledShiftsInWindowRow struct {
-	ID                uuid.UUID     `json:"id"`
-	GroupID           uuid.UUID     `json:"group_id"`
-	RequesterID       uuid.UUID     `json:"requester_id"`
-	AcceptorID        uuid.NullUUID `json:"acceptor_id"`
-	ShiftStartAt      time.Time     `json:"shift_start_at"`
-	ShiftEndAt        time.Time     `json:"shift_end_at"`
-	BountyDescription string        `json:"bounty_description"`
-	Status            string        `json:"status"`
-	CreatedAt         time.Time     `json:"created_at"`
-	UpdatedAt         time.Time     `json:"updated_at"`
-	IsPaid            bool          `json:"is_paid"`
-	Details           string        `json:"details"`
-	LineUserID        string        `json:"line_user_id"`
+const listTradeApplications = `-- name: ListTradeApplications :many
+SELECT a.id, a.trade_id, a.applicant_id, a.status, a.decided_by, a.decided_at, a.created_at,
+       u.display_name AS applicant_name,
+       u.profile_image_url AS applicant_image
+FROM trade_applications a
+         JOIN users u ON a.applicant_id = u.id
+WHERE a.trade_id = $1
+  AND u.deleted_at IS NULL
+ORDER BY a.created_at ASC
+`
+
+type ListTradeApplicationsRow struct {
+	ID             uuid.UUID      `json:"id"`
+	TradeID        uuid.UUID      `json:"trade_id"`
+	ApplicantID    uuid.UUID      `json:"applicant_id"`
+	Status         string         `json:"status"`
+	DecidedBy      uuid.NullUUID  `json:"decided_by"`
+	DecidedAt      sql.NullTime   `json:"decided_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ApplicantName  string         `json:"applicant_name"`
+	ApplicantImage sql.NullString `json:"applicant_image"`
 }
 
-// 指定された時間範囲にある未成立シフトを取得 (リマインド通知用)
-func (q *Queries) ListUnfilledShiftsInWindow(ctx context.Context, arg ListUnfilledShiftsInWindowParams) ([]ListUnfilledShiftsInWindowRow, error) {
-	rows, err := q.db.QueryContext(ctx, listUnfilledShiftsInWindow, arg.ShiftStartAt, arg.ShiftStartAt_2)
+// シフト交代リクエストへの応募一覧（応募順）
+func (q *Queries) ListTradeApplications(ctx context.Context, tradeID uuid.UUID) ([]ListTradeApplicationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTradeApplications, tradeID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListUnfilledShiftsInWindowRow
+	var items []ListTradeApplicationsRow
 	for rows.Next() {
-		var i ListUnfilledShiftsInWindowRow
+		var i ListTradeApplicationsRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.GroupID,
-			&i.RequesterID,
-			&i.AcceptorID,
-			&i.ShiftStartAt,
-			&i.ShiftEndAt,
-			&i.BountyDescription,
+			&i.TradeID,
+			&i.ApplicantID,
 			&i.Status,
+			&i.DecidedBy,
+			&i.DecidedAt,
 			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.IsPaid,
-			&i.Details,
-			&i.LineUserID,
+			&i.ApplicantName,
+			&i.ApplicantImage,
 		); err != nil {
 			return nil, err
 		}
@@ -552,6 +2277,7 @@ SELECT g.id, g.name, g.invitation_code, gm.role
 FROM job_groups g
          JOIN group_members gm ON g.id = gm.group_id
 WHERE gm.user_id = $1
+  AND gm.status = 'ACTIVE'
   AND g.deleted_at IS NULL
 ORDER BY g.created_at DESC
 `
@@ -593,34 +2319,288 @@ func (q *Queries) ListUserGroups(ctx context.Context, userID uuid.UUID) ([]ListU
 }
 
 const listUserTrades = `-- name: ListUserTrades :many
-SELECT id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details FROM shift_trades
-WHERE (requester_id = $1 OR acceptor_id = $1)
+SELECT id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes FROM shift_trades
+WHERE (requester_id = $1 OR acceptor_id = $1
+    OR EXISTS (
+        SELECT 1
+        FROM shift_trade_segments s
+        WHERE s.trade_id = shift_trades.id
+          AND s.acceptor_id = $1
+    ))
 ORDER BY shift_start_at DESC
 `
 
-// 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの)
-func (q *Queries) ListUserTrades(ctx context.Context, requesterID uuid.UUID) ([]ShiftTrade, error) {
-	rows, err := q.db.QueryContext(ctx, listUserTrades, requesterID)
+// 自分の関わったトレード履歴を取得 (作成したもの OR 引き受けたもの（分割募集の一部を含む）)
+func (q *Queries) ListUserTrades(ctx context.Context, requesterID uuid.UUID) ([]ShiftTrade, error) {
+	rows, err := q.db.QueryContext(ctx, listUserTrades, requesterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShiftTrade
+	for rows.Next() {
+		var i ShiftTrade
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupID,
+			&i.RequesterID,
+			&i.AcceptorID,
+			&i.ShiftStartAt,
+			&i.ShiftEndAt,
+			&i.BountyDescription,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.IsPaid,
+			&i.Details,
+			&i.CancelRequestedAt,
+			&i.TradeType,
+			&i.SwapParentID,
+			&i.SegmentMinutes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUserForAcceptance = `-- name: LockUserForAcceptance :exec
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+// 引き受ける人の行をロックし、同じ人の引き受け（重なりのチェックから成立まで）を直列にする
+func (q *Queries) LockUserForAcceptance(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUserForAcceptance, id)
+	return err
+}
+
+const markNotificationDead = `-- name: MarkNotificationDead :exec
+UPDATE notification_outbox
+SET status = 'DEAD',
+    last_error = $2,
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type MarkNotificationDeadParams struct {
+	ID        uuid.UUID      `json:"id"`
+	LastError sql.NullString `json:"last_error"`
+}
+
+// 再送しても送れない通知を DEAD にする
+func (q *Queries) MarkNotificationDead(ctx context.Context, arg MarkNotificationDeadParams) error {
+	_, err := q.db.ExecContext(ctx, markNotificationDead, arg.ID, arg.LastError)
+	return err
+}
+
+const markNotificationSent = `-- name: MarkNotificationSent :exec
+UPDATE notification_outbox
+SET status = 'SENT',
+    sent_at = NOW(),
+    locked_until = NULL,
+    last_error = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+// 送信済みにする
+func (q *Queries) MarkNotificationSent(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markNotificationSent, id)
+	return err
+}
+
+const markTradeAsPaid = `-- name: MarkTradeAsPaid :one
+UPDATE shift_trades
+SET is_paid = true,
+    status = 'COMPLETED',
+    updated_at = NOW()
+WHERE id = $1 AND requester_id = $2 AND status = 'FILLED'
+  AND swap_parent_id IS NULL
+    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+type MarkTradeAsPaidParams struct {
+	ID          uuid.UUID `json:"id"`
+	RequesterID uuid.UUID `json:"requester_id"`
+}
+
+// 謝礼を支払い済みにする（FILLED → COMPLETED）
+// 交換の「お返し」の募集は謝礼がないので対象外
+func (q *Queries) MarkTradeAsPaid(ctx context.Context, arg MarkTradeAsPaidParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, markTradeAsPaid, arg.ID, arg.RequesterID)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const markWebhookEventProcessed = `-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET processed_at = NOW()
+WHERE webhook_event_id = $1
+`
+
+// Webhook イベントを処理済みにする
+func (q *Queries) MarkWebhookEventProcessed(ctx context.Context, webhookEventID string) error {
+	_, err := q.db.ExecContext(ctx, markWebhookEventProcessed, webhookEventID)
+	return err
+}
+
+const rejectGroupMember = `-- name: RejectGroupMember :execrows
+DELETE FROM group_members
+WHERE group_id = $1
+  AND user_id = $2
+  AND status = 'PENDING'
+`
+
+type RejectGroupMemberParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// 参加申請を見送る
+func (q *Queries) RejectGroupMember(ctx context.Context, arg RejectGroupMemberParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rejectGroupMember, arg.GroupID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rejectPendingTradeApplications = `-- name: RejectPendingTradeApplications :many
+UPDATE trade_applications
+SET status = 'REJECTED',
+    decided_by = $2,
+    decided_at = NOW()
+WHERE trade_id = $1
+  AND status = 'PENDING'
+RETURNING applicant_id
+`
+
+type RejectPendingTradeApplicationsParams struct {
+	TradeID   uuid.UUID     `json:"trade_id"`
+	DecidedBy uuid.NullUUID `json:"decided_by"`
+}
+
+// 承認時に残りの応募をまとめて却下する
+func (q *Queries) RejectPendingTradeApplications(ctx context.Context, arg RejectPendingTradeApplicationsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, rejectPendingTradeApplications, arg.TradeID, arg.DecidedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var applicant_id uuid.UUID
+		if err := rows.Scan(&applicant_id); err != nil {
+			return nil, err
+		}
+		items = append(items, applicant_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rejectTradeApplication = `-- name: RejectTradeApplication :one
+UPDATE trade_applications
+SET status = 'REJECTED',
+    decided_by = $3,
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND status = 'PENDING'
+RETURNING id, trade_id, applicant_id, status, decided_by, decided_at, created_at
+`
+
+type RejectTradeApplicationParams struct {
+	ID        uuid.UUID     `json:"id"`
+	TradeID   uuid.UUID     `json:"trade_id"`
+	DecidedBy uuid.NullUUID `json:"decided_by"`
+}
+
+// 応募を却下する
+func (q *Queries) RejectTradeApplication(ctx context.Context, arg RejectTradeApplicationParams) (TradeApplication, error) {
+	row := q.db.QueryRowContext(ctx, rejectTradeApplication, arg.ID, arg.TradeID, arg.DecidedBy)
+	var i TradeApplication
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.ApplicantID,
+		&i.Status,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const releaseAcceptorSegmentsInGroup = `-- name: ReleaseAcceptorSegmentsInGroup :many
+UPDATE shift_trade_segments s
+SET acceptor_id = NULL,
+    accepted_at = NULL
+FROM shift_trades t
+WHERE s.trade_id = t.id
+  AND s.acceptor_id = $1
+  AND t.group_id = $2
+  AND t.status IN ('OPEN', 'FILLED')
+  AND s.segment_start_at > NOW()
+RETURNING s.id, s.trade_id, s.segment_start_at, s.segment_end_at, s.acceptor_id, s.accepted_at, s.created_at
+`
+
+type ReleaseAcceptorSegmentsInGroupParams struct {
+	AcceptorID uuid.NullUUID `json:"acceptor_id"`
+	GroupID    uuid.UUID     `json:"group_id"`
+}
+
+// グループを抜けるメンバーが引き受けた分割募集の枠を空きに戻す（まだ始まっていない枠だけ）
+func (q *Queries) ReleaseAcceptorSegmentsInGroup(ctx context.Context, arg ReleaseAcceptorSegmentsInGroupParams) ([]ShiftTradeSegment, error) {
+	rows, err := q.db.QueryContext(ctx, releaseAcceptorSegmentsInGroup, arg.AcceptorID, arg.GroupID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ShiftTrade
+	var items []ShiftTradeSegment
 	for rows.Next() {
-		var i ShiftTrade
+		var i ShiftTradeSegment
 		if err := rows.Scan(
 			&i.ID,
-			&i.GroupID,
-			&i.RequesterID,
+			&i.TradeID,
+			&i.SegmentStartAt,
+			&i.SegmentEndAt,
 			&i.AcceptorID,
-			&i.ShiftStartAt,
-			&i.ShiftEndAt,
-			&i.BountyDescription,
-			&i.Status,
+			&i.AcceptedAt,
 			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.IsPaid,
-			&i.Details,
 		); err != nil {
 			return nil, err
 		}
@@ -635,21 +2615,133 @@ func (q *Queries) ListUserTrades(ctx context.Context, requesterID uuid.UUID) ([]
 	return items, nil
 }
 
-const markTradeAsPaid = `-- name: MarkTradeAsPaid :one
+const releaseNotificationClaims = `-- name: ReleaseNotificationClaims :exec
+UPDATE notification_outbox
+SET attempts = GREATEST(attempts - 1, 0),
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = ANY($1::uuid[])
+  AND status = 'PENDING'
+`
+
+// 取り出したが送らなかった通知を戻す（停止時。試行回数も戻す）
+func (q *Queries) ReleaseNotificationClaims(ctx context.Context, ids []uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseNotificationClaims, pq.Array(ids))
+	return err
+}
+
+const releaseWebhookEvent = `-- name: ReleaseWebhookEvent :exec
+UPDATE webhook_events
+SET locked_until = NOW()
+WHERE webhook_event_id = $1
+  AND processed_at IS NULL
+`
+
+// 処理に失敗した Webhook イベントを、再送されたときに処理し直せるようにする
+func (q *Queries) ReleaseWebhookEvent(ctx context.Context, webhookEventID string) error {
+	_, err := q.db.ExecContext(ctx, releaseWebhookEvent, webhookEventID)
+	return err
+}
+
+const reopenShiftTrade = `-- name: ReopenShiftTrade :one
 UPDATE shift_trades
-SET is_paid = true, updated_at = NOW()
-WHERE id = $1 AND requester_id = $2
-    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details
+SET acceptor_id = NULL,
+    status = 'OPEN',
+    cancel_requested_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+  AND acceptor_id = $2
+  AND status = 'FILLED'
+  AND shift_start_at > $3
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
 `
 
-type MarkTradeAsPaidParams struct {
+type ReopenShiftTradeParams struct {
+	ID           uuid.UUID     `json:"id"`
+	AcceptorID   uuid.NullUUID `json:"acceptor_id"`
+	ShiftStartAt time.Time     `json:"shift_start_at"`
+}
+
+// 引き受けを取り消して募集を再開する（FILLED → OPEN）
+// 締め切り（シフト開始の一定時間前）を過ぎたものは対象外
+func (q *Queries) ReopenShiftTrade(ctx context.Context, arg ReopenShiftTradeParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, reopenShiftTrade, arg.ID, arg.AcceptorID, arg.ShiftStartAt)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const reopenSplitShiftTrade = `-- name: ReopenSplitShiftTrade :one
+UPDATE shift_trades
+SET acceptor_id = NULL,
+    status = 'OPEN',
+    updated_at = NOW()
+WHERE id = $1
+  AND status = 'FILLED'
+  AND segment_minutes > 0
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+// 枠が空いた分割募集を募集中に戻す（FILLED → OPEN）
+func (q *Queries) ReopenSplitShiftTrade(ctx context.Context, id uuid.UUID) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, reopenSplitShiftTrade, id)
+	var i ShiftTrade
+	err := row.Scan(
+		&i.ID,
+		&i.GroupID,
+		&i.RequesterID,
+		&i.AcceptorID,
+		&i.ShiftStartAt,
+		&i.ShiftEndAt,
+		&i.BountyDescription,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsPaid,
+		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const requestCancelAcceptance = `-- name: RequestCancelAcceptance :one
+UPDATE shift_trades
+SET cancel_requested_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND requester_id = $2
+  AND status = 'FILLED'
+RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
+`
+
+type RequestCancelAcceptanceParams struct {
 	ID          uuid.UUID `json:"id"`
 	RequesterID uuid.UUID `json:"requester_id"`
 }
 
-// 謝礼を支払い済みにする
-func (q *Queries) MarkTradeAsPaid(ctx context.Context, arg MarkTradeAsPaidParams) (ShiftTrade, error) {
-	row := q.db.QueryRowContext(ctx, markTradeAsPaid, arg.ID, arg.RequesterID)
+// 作成者が引き受けの取り消しを依頼する（引き受け者の同意待ちにする）
+func (q *Queries) RequestCancelAcceptance(ctx context.Context, arg RequestCancelAcceptanceParams) (ShiftTrade, error) {
+	row := q.db.QueryRowContext(ctx, requestCancelAcceptance, arg.ID, arg.RequesterID)
 	var i ShiftTrade
 	err := row.Scan(
 		&i.ID,
@@ -664,10 +2756,106 @@ func (q *Queries) MarkTradeAsPaid(ctx context.Context, arg MarkTradeAsPaidParams
 		&i.UpdatedAt,
 		&i.IsPaid,
 		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const rotateInvitationCode = `-- name: RotateInvitationCode :one
+UPDATE job_groups
+SET invitation_code = $2,
+    invitation_expires_at = $3,
+    invitation_max_uses = $4,
+    invitation_use_count = 0,
+    updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type RotateInvitationCodeParams struct {
+	ID                  uuid.UUID     `json:"id"`
+	InvitationCode      string        `json:"invitation_code"`
+	InvitationExpiresAt sql.NullTime  `json:"invitation_expires_at"`
+	InvitationMaxUses   sql.NullInt32 `json:"invitation_max_uses"`
+}
+
+// 招待コードを再発行する（有効期限・利用上限を設定し、利用回数をリセット）
+func (q *Queries) RotateInvitationCode(ctx context.Context, arg RotateInvitationCodeParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, rotateInvitationCode,
+		arg.ID,
+		arg.InvitationCode,
+		arg.InvitationExpiresAt,
+		arg.InvitationMaxUses,
+	)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
 	)
 	return i, err
 }
 
+const scheduleNotificationRetry = `-- name: ScheduleNotificationRetry :exec
+UPDATE notification_outbox
+SET next_attempt_at = $2,
+    last_error = $3,
+    locked_until = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type ScheduleNotificationRetryParams struct {
+	ID            uuid.UUID      `json:"id"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     sql.NullString `json:"last_error"`
+}
+
+// 送信に失敗した通知を next_attempt_at に再送する
+func (q *Queries) ScheduleNotificationRetry(ctx context.Context, arg ScheduleNotificationRetryParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleNotificationRetry, arg.ID, arg.NextAttemptAt, arg.LastError)
+	return err
+}
+
+const setUserBotReachableByLineID = `-- name: SetUserBotReachableByLineID :execrows
+UPDATE users
+SET bot_reachable = $1,
+    updated_at = NOW()
+WHERE line_user_id = $2
+  AND deleted_at IS NULL
+  AND bot_reachable != $1
+`
+
+type SetUserBotReachableByLineIDParams struct {
+	BotReachable bool   `json:"bot_reachable"`
+	LineUserID   string `json:"line_user_id"`
+}
+
+// bot の友だち追加・ブロック（follow / unfollow）や送信失敗に合わせて通知の可否を記録する
+func (q *Queries) SetUserBotReachableByLineID(ctx context.Context, arg SetUserBotReachableByLineIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserBotReachableByLineID, arg.BotReachable, arg.LineUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const softDeleteJobGroup = `-- name: SoftDeleteJobGroup :execrows
 UPDATE job_groups
 SET deleted_at = NOW(),
@@ -691,25 +2879,172 @@ func (q *Queries) SoftDeleteJobGroup(ctx context.Context, arg SoftDeleteJobGroup
 	return result.RowsAffected()
 }
 
+const transferJobGroupOwnership = `-- name: TransferJobGroupOwnership :one
+UPDATE job_groups
+SET owner_id = $1,
+    updated_at = NOW()
+WHERE id = $2
+  AND owner_id = $3
+  AND deleted_at IS NULL
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type TransferJobGroupOwnershipParams struct {
+	NewOwnerID     uuid.UUID `json:"new_owner_id"`
+	ID             uuid.UUID `json:"id"`
+	CurrentOwnerID uuid.UUID `json:"current_owner_id"`
+}
+
+// オーナーを譲る（現在の owner が変わっていないときだけ）
+func (q *Queries) TransferJobGroupOwnership(ctx context.Context, arg TransferJobGroupOwnershipParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, transferJobGroupOwnership, arg.NewOwnerID, arg.ID, arg.CurrentOwnerID)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const tryAdvisoryLock = `-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock($1::bigint) AS locked
+`
+
+// バックグラウンド処理の排他用 advisory lock を取る（取れなければ false）
+func (q *Queries) TryAdvisoryLock(ctx context.Context, lockKey int64) (bool, error) {
+	row := q.db.QueryRowContext(ctx, tryAdvisoryLock, lockKey)
+	var locked bool
+	err := row.Scan(&locked)
+	return locked, err
+}
+
+const unlinkJobGroupChat = `-- name: UnlinkJobGroupChat :execrows
+UPDATE job_groups
+SET line_chat_id = NULL,
+    notify_mode = 'INDIVIDUAL',
+    updated_at = NOW()
+WHERE line_chat_id = $1
+`
+
+// LINE のトークとの連携を外し、通知をメンバー1人ずつに戻す（bot の退出・/unlink・別グループとの連携時）
+func (q *Queries) UnlinkJobGroupChat(ctx context.Context, lineChatID sql.NullString) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlinkJobGroupChat, lineChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateGroupMemberRole = `-- name: UpdateGroupMemberRole :one
+UPDATE group_members
+SET role = $3
+WHERE group_id = $1
+  AND user_id = $2
+  AND status = 'ACTIVE'
+RETURNING user_id, group_id, role, joined_at, status, approved_by
+`
+
+type UpdateGroupMemberRoleParams struct {
+	GroupID uuid.UUID `json:"group_id"`
+	UserID  uuid.UUID `json:"user_id"`
+	Role    string    `json:"role"`
+}
+
+// メンバーの役割を変更（参加承認待ちの申請は対象外）
+func (q *Queries) UpdateGroupMemberRole(ctx context.Context, arg UpdateGroupMemberRoleParams) (GroupMember, error) {
+	row := q.db.QueryRowContext(ctx, updateGroupMemberRole, arg.GroupID, arg.UserID, arg.Role)
+	var i GroupMember
+	err := row.Scan(
+		&i.UserID,
+		&i.GroupID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.Status,
+		&i.ApprovedBy,
+	)
+	return i, err
+}
+
 const updateJobGroupName = `-- name: UpdateJobGroupName :one
 UPDATE job_groups
 SET name = $2,
     updated_at = NOW()
 WHERE id = $1
-  AND owner_id = $3
   AND deleted_at IS NULL
-RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
 `
 
 type UpdateJobGroupNameParams struct {
-	ID      uuid.UUID `json:"id"`
-	Name    string    `json:"name"`
-	OwnerID uuid.UUID `json:"owner_id"`
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
 }
 
-// グループ名を変更（ownerのみ）
+// グループ名を変更（権限チェックはハンドラ側: owner / ADMIN）
 func (q *Queries) UpdateJobGroupName(ctx context.Context, arg UpdateJobGroupNameParams) (JobGroup, error) {
-	row := q.db.QueryRowContext(ctx, updateJobGroupName, arg.ID, arg.Name, arg.OwnerID)
+	row := q.db.QueryRowContext(ctx, updateJobGroupName, arg.ID, arg.Name)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const updateJobGroupSettings = `-- name: UpdateJobGroupSettings :one
+UPDATE job_groups
+SET accept_mode = $2,
+    require_join_approval = $3,
+    reminder_offsets_minutes = $4,
+    notify_mode = $5,
+    updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type UpdateJobGroupSettingsParams struct {
+	ID                     uuid.UUID `json:"id"`
+	AcceptMode             string    `json:"accept_mode"`
+	RequireJoinApproval    bool      `json:"require_join_approval"`
+	ReminderOffsetsMinutes []int32   `json:"reminder_offsets_minutes"`
+	NotifyMode             string    `json:"notify_mode"`
+}
+
+// グループ設定を変更（引き受け方式・参加承認・リマインドの段階・通知の送り方）
+func (q *Queries) UpdateJobGroupSettings(ctx context.Context, arg UpdateJobGroupSettingsParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, updateJobGroupSettings,
+		arg.ID,
+		arg.AcceptMode,
+		arg.RequireJoinApproval,
+		pq.Array(arg.ReminderOffsetsMinutes),
+		arg.NotifyMode,
+	)
 	var i JobGroup
 	err := row.Scan(
 		&i.ID,
@@ -719,6 +3054,14 @@ func (q *Queries) UpdateJobGroupName(ctx context.Context, arg UpdateJobGroupName
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
 	)
 	return i, err
 }
@@ -728,7 +3071,7 @@ UPDATE shift_trades
 SET details = $3,
     updated_at = NOW()
 WHERE id = $1 AND requester_id = $2
-    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details
+    RETURNING id, group_id, requester_id, acceptor_id, shift_start_at, shift_end_at, bounty_description, status, created_at, updated_at, is_paid, details, cancel_requested_at, trade_type, swap_parent_id, segment_minutes
 `
 
 type UpdateTradeDetailsParams struct {
@@ -754,6 +3097,108 @@ func (q *Queries) UpdateTradeDetails(ctx context.Context, arg UpdateTradeDetails
 		&i.UpdatedAt,
 		&i.IsPaid,
 		&i.Details,
+		&i.CancelRequestedAt,
+		&i.TradeType,
+		&i.SwapParentID,
+		&i.SegmentMinutes,
+	)
+	return i, err
+}
+
+const useInvitationCode = `-- name: UseInvitationCode :one
+UPDATE job_groups
+SET invitation_use_count = invitation_use_count + 1
+WHERE id = $1
+  AND invitation_code = $2
+  AND deleted_at IS NULL
+  AND (invitation_expires_at IS NULL OR invitation_expires_at > NOW())
+  AND (invitation_max_uses IS NULL OR invitation_use_count < invitation_max_uses)
+RETURNING id, name, invitation_code, owner_id, created_at, updated_at, deleted_at, accept_mode, invitation_expires_at, invitation_max_uses, invitation_use_count, require_join_approval, reminder_offsets_minutes, line_chat_id, notify_mode
+`
+
+type UseInvitationCodeParams struct {
+	ID             uuid.UUID `json:"id"`
+	InvitationCode string    `json:"invitation_code"`
+}
+
+// 招待コードの利用回数を1つ増やす（期限切れ・上限到達なら何も返さない）
+func (q *Queries) UseInvitationCode(ctx context.Context, arg UseInvitationCodeParams) (JobGroup, error) {
+	row := q.db.QueryRowContext(ctx, useInvitationCode, arg.ID, arg.InvitationCode)
+	var i JobGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InvitationCode,
+		&i.OwnerID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.AcceptMode,
+		&i.InvitationExpiresAt,
+		&i.InvitationMaxUses,
+		&i.InvitationUseCount,
+		&i.RequireJoinApproval,
+		pq.Array(&i.ReminderOffsetsMinutes),
+		&i.LineChatID,
+		&i.NotifyMode,
+	)
+	return i, err
+}
+
+const withdrawPendingApplicationsInGroup = `-- name: WithdrawPendingApplicationsInGroup :execrows
+UPDATE trade_applications a
+SET status = 'WITHDRAWN',
+    decided_at = NOW()
+FROM shift_trades t
+WHERE a.trade_id = t.id
+  AND a.applicant_id = $1
+  AND t.group_id = $2
+  AND a.status = 'PENDING'
+`
+
+type WithdrawPendingApplicationsInGroupParams struct {
+	ApplicantID uuid.UUID `json:"applicant_id"`
+	GroupID     uuid.UUID `json:"group_id"`
+}
+
+// グループを抜けるメンバーの応募中の応募を取り下げ扱いにする
+func (q *Queries) WithdrawPendingApplicationsInGroup(ctx context.Context, arg WithdrawPendingApplicationsInGroupParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, withdrawPendingApplicationsInGroup, arg.ApplicantID, arg.GroupID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const withdrawTradeApplication = `-- name: WithdrawTradeApplication :one
+UPDATE trade_applications
+SET status = 'WITHDRAWN',
+    decided_at = NOW()
+WHERE id = $1
+  AND trade_id = $2
+  AND applicant_id = $3
+  AND status = 'PENDING'
+RETURNING id, trade_id, applicant_id, status, decided_by, decided_at, created_at
+`
+
+type WithdrawTradeApplicationParams struct {
+	ID          uuid.UUID `json:"id"`
+	TradeID     uuid.UUID `json:"trade_id"`
+	ApplicantID uuid.UUID `json:"applicant_id"`
+}
+
+// 応募を取り下げる（応募者本人）
+func (q *Queries) WithdrawTradeApplication(ctx context.Context, arg WithdrawTradeApplicationParams) (TradeApplication, error) {
+	row := q.db.QueryRowContext(ctx, withdrawTradeApplication, arg.ID, arg.TradeID, arg.ApplicantID)
+	var i TradeApplication
+	err := row.Scan(
+		&i.ID,
+		&i.TradeID,
+		&i.ApplicantID,
+		&i.Status,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,240 @@
+// Package flex は bot の通知に使う LINE Flex Message のテンプレートをまとめる
+// どのテンプレートも altText に同じ内容のテキストを入れるので、Flex を表示できない端末や通知欄ではテキストで読める
+package flex
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// altText の上限（LINE の仕様で 400 文字）
+const maxAltTextRunes = 400
+
+// 色（LINE のブランドカラーと画面の文字色に合わせる）
+const (
+	colorPrimary = "#06C755"
+	colorLabel   = "#8C8C8C"
+	colorText    = "#111111"
+)
+
+// Card は募集1件分の通知カード
+type Card struct {
+	Title     string   // 見出し（例: 📢 新しいシフト募集があります！）
+	Requester *Person  // 作成者（nil なら表示しない）
+	Rows      []Row    // 日時・グループ・謝礼などの行
+	Note      string   // 本文の最後に入れる補足（空なら入れない）
+	Actions   []Action // フッターのボタン（先頭を強調表示する。空ならボタンなし）
+	AltText   string   // テキスト版の本文（Flex を表示できない端末で使う）
+}
+
+// Person はカードに表示する人
+type Person struct {
+	Name     string
+	ImageURL string // プロフィール画像（空なら名前だけ）
+}
+
+// Row は「ラベル: 値」の1行
+type Row struct {
+	Label string
+	Value string
+}
+
+// Action はフッターのボタン。URI が空でなければ URI を開き、そうでなければ Data を postback で送る
+type Action struct {
+	Label string
+	URI   string
+	Data  string
+}
+
+// Message は Card を Flex Message にする
+// NOTIFY_FORMAT=text のときは AltText だけのテキストメッセージを返す
+func Message(card Card) linebot.SendingMessage {
+	if textOnly() {
+		return linebot.NewTextMessage(card.AltText)
+	}
+	return linebot.NewFlexMessage(altText(card.AltText), Bubble(card))
+}
+
+// Bubble は Card を Flex の bubble にする
+func Bubble(card Card) *linebot.BubbleContainer {
+	body := []linebot.FlexComponent{
+		&linebot.TextComponent{
+			Type:   linebot.FlexComponentTypeText,
+			Text:   nonEmpty(card.Title),
+			Weight: linebot.FlexTextWeightTypeBold,
+			Size:   linebot.FlexTextSizeTypeMd,
+			Color:  colorText,
+			Wrap:   true,
+		},
+	}
+	if card.Requester != nil {
+		body = append(body, personBox(*card.Requester))
+	}
+	if len(card.Rows) > 0 {
+		rows := make([]linebot.FlexComponent, 0, len(card.Rows))
+		for _, r := range card.Rows {
+			rows = append(rows, rowBox(r))
+		}
+		body = append(body, &linebot.BoxComponent{
+			Type:     linebot.FlexComponentTypeBox,
+			Layout:   linebot.FlexBoxLayoutTypeVertical,
+			Contents: rows,
+			Spacing:  linebot.FlexComponentSpacingTypeSm,
+			Margin:   linebot.FlexComponentMarginTypeLg,
+		})
+	}
+	if card.Note != "" {
+		body = append(body, &linebot.TextComponent{
+			Type:   linebot.FlexComponentTypeText,
+			Text:   card.Note,
+			Size:   linebot.FlexTextSizeTypeSm,
+			Color:  colorLabel,
+			Margin: linebot.FlexComponentMarginTypeLg,
+			Wrap:   true,
+		})
+	}
+
+	bubble := &linebot.BubbleContainer{
+		Type: linebot.FlexContainerTypeBubble,
+		Body: &linebot.BoxComponent{
+			Type:     linebot.FlexComponentTypeBox,
+			Layout:   linebot.FlexBoxLayoutTypeVertical,
+			Contents: body,
+		},
+	}
+	buttons := make([]linebot.FlexComponent, 0, len(card.Actions))
+	for _, a := range card.Actions {
+		action := templateAction(a)
+		if action == nil {
+			continue
+		}
+		button := &linebot.ButtonComponent{
+			Type:   linebot.FlexComponentTypeButton,
+			Action: action,
+			Style:  linebot.FlexButtonStyleTypeLink,
+		}
+		if len(buttons) == 0 {
+			button.Style = linebot.FlexButtonStyleTypePrimary
+			button.Color = colorPrimary
+		}
+		buttons = append(buttons, button)
+	}
+	if len(buttons) > 0 {
+		bubble.Footer = &linebot.BoxComponent{
+			Type:     linebot.FlexComponentTypeBox,
+			Layout:   linebot.FlexBoxLayoutTypeVertical,
+			Contents: buttons,
+			Spacing:  linebot.FlexComponentSpacingTypeSm,
+		}
+	}
+	return bubble
+}
+
+// TradeURL は募集の詳細画面を LIFF で開く URL（LIFF_ID が未設定なら空）
+func TradeURL(groupID, tradeID string) string {
+	liffID := os.Getenv("LIFF_ID")
+	if liffID == "" {
+		return ""
+	}
+	return "https://liff.line.me/" + url.PathEscape(liffID) +
+		"/groups/" + url.PathEscape(groupID) + "/trades/" + url.PathEscape(tradeID)
+}
+
+func personBox(p Person) *linebot.BoxComponent {
+	contents := []linebot.FlexComponent{}
+	// Flex の画像は https のみ
+	if strings.HasPrefix(p.ImageURL, "https://") {
+		contents = append(contents, &linebot.BoxComponent{
+			Type:         linebot.FlexComponentTypeBox,
+			Layout:       linebot.FlexBoxLayoutTypeVertical,
+			Width:        "32px",
+			Height:       "32px",
+			CornerRadius: linebot.FlexComponentCornerRadiusTypeXxl,
+			Contents: []linebot.FlexComponent{
+				&linebot.ImageComponent{
+					Type:        linebot.FlexComponentTypeImage,
+					URL:         p.ImageURL,
+					Size:        linebot.FlexImageSizeTypeFull,
+					AspectMode:  linebot.FlexImageAspectModeTypeCover,
+					AspectRatio: linebot.FlexImageAspectRatioType1to1,
+				},
+			},
+		})
+	}
+	contents = append(contents, &linebot.TextComponent{
+		Type:    linebot.FlexComponentTypeText,
+		Text:    nonEmpty(p.Name) + " さん",
+		Size:    linebot.FlexTextSizeTypeSm,
+		Color:   colorText,
+		Gravity: linebot.FlexComponentGravityTypeCenter,
+		Wrap:    true,
+	})
+	return &linebot.BoxComponent{
+		Type:     linebot.FlexComponentTypeBox,
+		Layout:   linebot.FlexBoxLayoutTypeHorizontal,
+		Contents: contents,
+		Spacing:  linebot.FlexComponentSpacingTypeMd,
+		Margin:   linebot.FlexComponentMarginTypeLg,
+	}
+}
+
+func rowBox(r Row) *linebot.BoxComponent {
+	labelFlex, valueFlex := 2, 5
+	return &linebot.BoxComponent{
+		Type:    linebot.FlexComponentTypeBox,
+		Layout:  linebot.FlexBoxLayoutTypeBaseline,
+		Spacing: linebot.FlexComponentSpacingTypeSm,
+		Contents: []linebot.FlexComponent{
+			&linebot.TextComponent{
+				Type:  linebot.FlexComponentTypeText,
+				Text:  nonEmpty(r.Label),
+				Size:  linebot.FlexTextSizeTypeSm,
+				Color: colorLabel,
+				Flex:  &labelFlex,
+			},
+			&linebot.TextComponent{
+				Type:  linebot.FlexComponentTypeText,
+				Text:  nonEmpty(r.Value),
+				Size:  linebot.FlexTextSizeTypeSm,
+				Color: colorText,
+				Flex:  &valueFlex,
+				Wrap:  true,
+			},
+		},
+	}
+}
+
+func templateAction(a Action) linebot.TemplateAction {
+	switch {
+	case a.URI != "":
+		return linebot.NewURIAction(a.Label, a.URI)
+	case a.Data != "":
+		return linebot.NewPostbackAction(a.Label, a.Data, "", a.Label, "", "")
+	default:
+		return nil
+	}
+}
+
+// Flex のテキストは空文字を許さない
+func nonEmpty(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+func altText(s string) string {
+	r := []rune(s)
+	if len(r) <= maxAltTextRunes {
+		return s
+	}
+	return string(r[:maxAltTextRunes-1]) + "…"
+}
+
+// Flex を使わずテキストで送るか（NOTIFY_FORMAT=text）
+func textOnly() bool {
+	return os.Getenv("NOTIFY_FORMAT") == "text"
+}
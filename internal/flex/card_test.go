@@ -0,0 +1,148 @@
+package flex
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// go test ./internal/flex -update で testdata/*.golden.json を書き直す
+var update = flag.Bool("update", false, "update golden files")
+
+func TestBubbleGolden(t *testing.T) {
+	t.Setenv("NOTIFY_FORMAT", "")
+
+	tests := []struct {
+		name string
+		card Card
+	}{
+		{
+			name: "requester_with_image",
+			card: Card{
+				Title:     "📢 新しいシフト募集があります！",
+				Requester: &Person{Name: "山田", ImageURL: "https://example.com/yamada.png"},
+				Rows: []Row{
+					{Label: "グループ", Value: "駅前店"},
+					{Label: "日時", Value: "05/01(水) 10:00〜15:00"},
+					{Label: "謝礼", Value: "ジュース"},
+				},
+				Actions: []Action{
+					{Label: "このシフトを代わる", Data: "action=accept&trade=t1"},
+					{Label: "詳細を見る", URI: "https://liff.line.me/liff-id/groups/g1/trades/t1"},
+				},
+				AltText: "📢 新しいシフト募集があります！",
+			},
+		},
+		{
+			name: "requester_without_image",
+			card: Card{
+				Title:     "📢 新しいシフト募集があります！",
+				Requester: &Person{Name: "佐藤", ImageURL: "http://example.com/not-https.png"},
+				Rows: []Row{
+					{Label: "日時", Value: "05/01(水) 10:00〜15:00"},
+					{Label: "謝礼", Value: ""},
+				},
+				Actions: []Action{{Label: "このシフトを代わる", Data: "action=accept&trade=t2"}},
+				AltText: "📢 新しいシフト募集があります！",
+			},
+		},
+		{
+			name: "swap",
+			card: Card{
+				Title: "🔄 新しいシフト交換の募集があります！",
+				Rows: []Row{
+					{Label: "グループ", Value: "駅前店"},
+					{Label: "代わってほしいシフト", Value: "05/01(水) 10:00〜15:00"},
+					{Label: "お返し", Value: "・05/03(金) 10:00〜15:00\n・05/04(土) 17:00〜22:00"},
+				},
+				Note:    "お返しに作成者が上のシフトのどれかに代わります。",
+				Actions: []Action{{Label: "このシフトを代わる", Data: "action=accept&trade=t3"}},
+				AltText: "🔄 新しいシフト交換の募集があります！",
+			},
+		},
+		{
+			name: "no_actions",
+			card: Card{
+				Title:   "🎉 シフトが成立しました！",
+				Rows:    []Row{{Label: "日時", Value: "05/01(水) 10:00〜15:00"}},
+				Note:    "当日よろしくおねがいします！",
+				Actions: []Action{{Label: "空のボタン"}},
+				AltText: "🎉 シフトが成立しました！",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertGolden(t, tt.name, Bubble(tt.card))
+		})
+	}
+}
+
+func TestMessageLongAltText(t *testing.T) {
+	t.Setenv("NOTIFY_FORMAT", "")
+
+	card := Card{
+		Title:   "⚠️ 【重要】シフト成立期限が迫っています",
+		AltText: strings.Repeat("あ", maxAltTextRunes+50),
+	}
+	msg, ok := Message(card).(*linebot.FlexMessage)
+	if !ok {
+		t.Fatalf("Message() = %T, want *linebot.FlexMessage", Message(card))
+	}
+	if n := utf8.RuneCountInString(msg.AltText); n != maxAltTextRunes {
+		t.Errorf("altText has %d runes, want %d", n, maxAltTextRunes)
+	}
+	if !strings.HasSuffix(msg.AltText, "…") {
+		t.Errorf("altText %q should end with …", msg.AltText)
+	}
+	assertGolden(t, "long_alt_text", msg)
+}
+
+func TestMessageTextOnly(t *testing.T) {
+	t.Setenv("NOTIFY_FORMAT", "text")
+
+	msg, ok := Message(Card{Title: "📢", AltText: "テキスト版"}).(*linebot.TextMessage)
+	if !ok {
+		t.Fatal("NOTIFY_FORMAT=text should send a text message")
+	}
+	if msg.Text != "テキスト版" {
+		t.Errorf("text = %q, want %q", msg.Text, "テキスト版")
+	}
+}
+
+func assertGolden(t *testing.T, name string, v json.Marshaler) {
+	t.Helper()
+
+	raw, err := v.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, raw, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	got.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("%s does not match\n--- got\n%s\n--- want\n%s", path, got.Bytes(), want)
+	}
+}
@@ -24,9 +24,9 @@ func LineSub(c echo.Context) (string, bool) {
 }
 
 // 認証
-// ヘッダーに付属した Authorization: Bearer <id_token> を LINE verify API で検証する
+// ヘッダーに付属した Authorization: Bearer <id_token> を verifier で検証する
 // 開発環境では Authorization: Bearer <DEV_AUTH_TOKEN> となっていた時に限り X-Dev-Sub を Sub として検証を通過する
-func AuthMiddleware() echo.MiddlewareFunc {
+func AuthMiddleware(verifier TokenVerifier) echo.MiddlewareFunc {
 	appEnv := strings.ToLower(os.Getenv("APP_ENV"))
 	devAuthToken := strings.TrimSpace(os.Getenv("DEV_AUTH_TOKEN"))
 
@@ -96,26 +96,40 @@ func AuthMiddleware() echo.MiddlewareFunc {
 				}
 			}
 
-			// LINE verify API を用いた検証
-			sub, err := verifyLineIDToken(bearer)
+			// ID Token の検証
+			claims, err := verifier.Verify(c.Request().Context(), bearer)
 			if strings.TrimSpace(os.Getenv("AUTH_DEBUG")) == "1" {
 				if err == nil {
-					c.Logger().Infof("[AUTH_DEBUG] line verify success (subLen=%d)", len(sub))
+					c.Logger().Infof("[AUTH_DEBUG] token verify success (subLen=%d)", len(claims.Sub))
 				} else {
-					// verify API 失敗の原因をログに出す（トークン全文は出さない）
+					// 検証失敗の原因をログに出す（トークン全文は出さない）
 					head := bearer
 					if len(head) > 16 {
 						head = head[:16]
 					}
-					c.Logger().Errorf("[AUTH_DEBUG] line verify FAILED: %v (bearerLen=%d bearerHead=%q)", err, len(bearer), head)
+					c.Logger().Errorf("[AUTH_DEBUG] token verify FAILED: %v (bearerLen=%d bearerHead=%q)", err, len(bearer), head)
 				}
 			}
 			if err != nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid id_token"})
 			}
-			c.Set(string(ctxLineSub), sub)
+			c.Set(string(ctxLineSub), claims.Sub)
 			c.Set(string(ctxDevBypass), false)
 			return next(c)
 		}
 	}
 }
+
+// 運用向けの確認用エンドポイントの認証
+// Authorization: Bearer <token> が一致したときだけ通す（LINE のユーザー認証とは別）
+func DebugTokenMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			bearer, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(bearer)), []byte(token)) != 1 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid debug token"})
+			}
+			return next(c)
+		}
+	}
+}
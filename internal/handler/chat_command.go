@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"shift-change-app/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 1つの返信に並べる件数の上限（LINE のテキストは 5000 文字まで）
+const maxCommandItems = 10
+
+// bot へのメッセージで使えるコマンド
+const (
+	commandOpenTrades = "一覧"
+	commandMyShifts   = "私のシフト"
+	commandUnpaid     = "未払い"
+	commandHelp       = "ヘルプ"
+)
+
+// 表記ゆれをコマンドにそろえる
+var commandAliases = map[string]string{
+	"一覧":      commandOpenTrades,
+	"募集":      commandOpenTrades,
+	"募集一覧":    commandOpenTrades,
+	"私のシフト":   commandMyShifts,
+	"わたしのシフト": commandMyShifts,
+	"マイシフト":   commandMyShifts,
+	"未払い":     commandUnpaid,
+	"ヘルプ":     commandHelp,
+	"help":    commandHelp,
+	"使い方":     commandHelp,
+}
+
+const commandHelpText = "🤖 使えるコマンド\n\n" +
+	"・一覧 … 所属グループの募集中のシフト\n" +
+	"・私のシフト … これからの自分の募集・引き受けたシフト\n" +
+	"・未払い … 謝礼の支払いがまだのシフト\n" +
+	"・ヘルプ … この説明"
+
+// 登録済みユーザーからのテキストメッセージをコマンドとして処理し、返信する文を返す
+// コマンドでなければ使い方の案内を返す
+func (h *Handler) handleChatCommand(ctx context.Context, user database.User, text string) (string, error) {
+	command, ok := commandAliases[strings.ToLower(strings.TrimSpace(text))]
+	if !ok {
+		return "「ヘルプ」と送ると使えるコマンドを表示します", nil
+	}
+
+	switch command {
+	case commandOpenTrades:
+		return h.replyOpenTrades(ctx, user.ID)
+	case commandMyShifts:
+		return h.replyMyShifts(ctx, user.ID, time.Now())
+	case commandUnpaid:
+		return h.replyUnpaid(ctx, user.ID)
+	default:
+		return commandHelpText, nil
+	}
+}
+
+// 一覧: 所属グループごとの募集中のシフト
+func (h *Handler) replyOpenTrades(ctx context.Context, userID uuid.UUID) (string, error) {
+	groups, err := h.queries.ListUserGroups(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if len(groups) == 0 {
+		return "まだどのグループにも参加していません", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("📋 募集中のシフト")
+	total := 0
+	for _, g := range groups {
+		trades, err := h.queries.ListOpenShiftTrades(ctx, g.ID)
+		if err != nil {
+			return "", err
+		}
+		if len(trades) == 0 {
+			continue
+		}
+
+		b.WriteString("\n\n【" + g.Name + "】")
+		for i, t := range trades {
+			if i == maxCommandItems {
+				b.WriteString("\nほか " + strconv.Itoa(len(trades)-i) + " 件")
+				break
+			}
+			b.WriteString("\n・" + formatShiftRangeJST(t.ShiftStartAt, t.ShiftEndAt) + " " + t.RequesterName + " さん")
+			if t.BountyDescription != "" {
+				b.WriteString("（謝礼: " + t.BountyDescription + "）")
+			}
+			if TradeType(t.TradeType) == TradeTypeSwap {
+				b.WriteString("［交換］")
+			}
+			if t.SegmentMinutes > 0 {
+				b.WriteString("［時間帯ごと］")
+			}
+		}
+		total += len(trades)
+	}
+	if total == 0 {
+		return "募集中のシフトはありません", nil
+	}
+	b.WriteString("\n\nアプリから引き受けられます")
+	return b.String(), nil
+}
+
+// 私のシフト: これからの自分の募集と、引き受けたシフト（開始が近い順）
+func (h *Handler) replyMyShifts(ctx context.Context, userID uuid.UUID, now time.Time) (string, error) {
+	trades, err := h.queries.ListUserTrades(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	groupNames, err := h.userGroupNames(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// ListUserTrades は開始が遅い順なので、後ろから見て近い順に並べる
+	lines := []string{}
+	for i := len(trades) - 1; i >= 0; i-- {
+		t := trades[i]
+		if !t.ShiftStartAt.After(now) {
+			continue
+		}
+		// 謝礼の支払いが済んだ（COMPLETED）シフトも、引き受けた側はこれから勤務するので載せる
+		status := TradeStatus(t.Status)
+		switch {
+		case status == TradeStatusOpen || status == TradeStatusFilled:
+		case status == TradeStatusCompleted && t.RequesterID != userID:
+		default:
+			continue
+		}
+
+		label := "代わる"
+		switch {
+		case t.RequesterID == userID && status == TradeStatusOpen:
+			label = "募集中"
+		case t.RequesterID == userID:
+			label = "代わってもらう"
+		case !t.AcceptorID.Valid || t.AcceptorID.UUID != userID:
+			label = "一部を代わる"
+		}
+		if status == TradeStatusCompleted {
+			label += "・支払い済み"
+		}
+
+		line := "・" + formatShiftRangeJST(t.ShiftStartAt, t.ShiftEndAt) + "［" + label + "］"
+		if name, ok := groupNames[t.GroupID]; ok {
+			line += " " + name
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "これからのシフトはありません", nil
+	}
+	return "🗓 これからのシフト\n\n" + joinLimited(lines), nil
+}
+
+// 未払い: 成立したが謝礼の支払いが記録されていないシフト（交換とその「お返し」は謝礼がないので除く）
+func (h *Handler) replyUnpaid(ctx context.Context, userID uuid.UUID) (string, error) {
+	trades, err := h.queries.ListUserTrades(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	toPay, toReceive := []string{}, []string{}
+	for _, t := range trades {
+		if TradeStatus(t.Status) != TradeStatusFilled || t.IsPaid || t.BountyDescription == "" {
+			continue
+		}
+		if t.SwapParentID.Valid || TradeType(t.TradeType) == TradeTypeSwap {
+			continue
+		}
+		line := "・" + formatShiftRangeJST(t.ShiftStartAt, t.ShiftEndAt) + "（謝礼: " + t.BountyDescription + "）"
+		switch {
+		case t.RequesterID == userID:
+			toPay = append(toPay, line)
+		case t.AcceptorID.Valid && t.AcceptorID.UUID == userID:
+			toReceive = append(toReceive, line)
+		}
+	}
+	if len(toPay) == 0 && len(toReceive) == 0 {
+		return "未払いの謝礼はありません", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("💰 未払いの謝礼")
+	if len(toPay) > 0 {
+		b.WriteString("\n\n【支払う】\n" + joinLimited(toPay))
+	}
+	if len(toReceive) > 0 {
+		b.WriteString("\n\n【受け取る】\n" + joinLimited(toReceive))
+	}
+	if len(toPay) > 0 {
+		b.WriteString("\n\n支払ったらアプリの詳細ページから「支払い済み」にしてください")
+	}
+	return b.String(), nil
+}
+
+// 所属グループの id → 名前
+func (h *Handler) userGroupNames(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]string, error) {
+	groups, err := h.queries.ListUserGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uuid.UUID]string, len(groups))
+	for _, g := range groups {
+		names[g.ID] = g.Name
+	}
+	return names, nil
+}
+
+// 行を上限まで改行でつなぐ（超えた分は件数だけ書く）
+func joinLimited(lines []string) string {
+	if len(lines) <= maxCommandItems {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:maxCommandItems], "\n") + "\nほか " + strconv.Itoa(len(lines)-maxCommandItems) + " 件"
+}
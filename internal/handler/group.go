@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"math/rand"
 	"net/http"
 	"shift-change-app/internal/database"
 	"time"
@@ -36,42 +36,57 @@ func (h *Handler) CreateGroup(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	// 招待コードの作成
-	invitationCode := generateRandomString(6)
+	// 招待コードが既存のものと衝突したら作り直してやり直す
+	for attempt := 0; attempt < invitationCodeAttempts; attempt++ {
+		invitationCode, err := generateInvitationCode()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate invitation code"})
+		}
 
-	tx, err := h.db.Begin()
+		group, err := h.createGroupWithOwner(ctx, req.GroupName, invitationCode, userUUID)
+		if err == nil {
+			return c.JSON(http.StatusOK, group)
+		}
+		if !isInvitationCodeConflict(err) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create group: " + err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate a unique invitation code"})
+}
+
+// グループを作成し、作成者を ADMIN としてメンバーに追加する（同一トランザクション）
+func (h *Handler) createGroupWithOwner(ctx context.Context, name, invitationCode string, ownerID uuid.UUID) (database.JobGroup, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+		return database.JobGroup{}, err
 	}
 	defer tx.Rollback()
 
 	qtx := h.queries.WithTx(tx)
 
-	// グループの作成
 	group, err := qtx.CreateJobGroup(ctx, database.CreateJobGroupParams{
-		Name:           req.GroupName,
+		Name:           name,
 		InvitationCode: invitationCode,
-		OwnerID:        userUUID,
+		OwnerID:        ownerID,
 	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create group: " + err.Error()})
+		return database.JobGroup{}, err
 	}
 
-	// 作成したグループに対して、作成者をADMINとしてメンバーに追加する
-	_, err = qtx.CreateGroupMember(ctx, database.CreateGroupMemberParams{
-		UserID:  userUUID,
+	if _, err := qtx.CreateGroupMember(ctx, database.CreateGroupMemberParams{
+		UserID:  ownerID,
 		GroupID: group.ID,
-		Role:    "ADMIN",
-	})
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add member: " + err.Error()})
+		Role:    string(GroupRoleAdmin),
+		Status:  string(MemberStatusActive),
+	}); err != nil {
+		return database.JobGroup{}, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+		return database.JobGroup{}, err
 	}
-
-	return c.JSON(http.StatusOK, group)
+	return group, nil
 }
 
 // 招待コードを使ってグループに参加
@@ -102,30 +117,98 @@ func (h *Handler) JoinGroup(c echo.Context) error {
 	group, err := h.queries.GetJobGroupByCode(ctx, req.Code)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Invalid invitation code"})
+			return respondInvitationError(c, errInvitationInvalid)
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	if err := invitationCodeError(group, time.Now()); err != nil {
+		return respondInvitationError(c, err)
+	}
 
-	// すでにメンバーか確認
-	_, err = h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+	// すでにメンバー・申請中か確認
+	existing, err := h.queries.GetGroupMembership(ctx, database.GetGroupMembershipParams{
 		GroupID: group.ID,
 		UserID:  userUUID,
 	})
 	if err == nil {
+		if MemberStatus(existing.Status) == MemberStatusPending {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Your join request is waiting for approval"})
+		}
 		return c.JSON(http.StatusConflict, map[string]string{"error": "You are already a member of this group"})
 	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 参加承認制のグループでは承認待ちとして追加する
+	status := MemberStatusActive
+	if group.RequireJoinApproval {
+		status = MemberStatusPending
+	}
+
+	// 招待コードの利用回数の更新とメンバー追加を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
 
 	// メンバーに追加 (Role: MEMBER)
-	member, err := h.queries.CreateGroupMember(ctx, database.CreateGroupMemberParams{
+	// 同時に参加したときは主キーの衝突で 500 にせず、後から来た方を 409 にする
+	member, err := qtx.CreateGroupMember(ctx, database.CreateGroupMemberParams{
 		UserID:  userUUID,
 		GroupID: group.ID,
-		Role:    "MEMBER",
+		Role:    string(GroupRoleMember),
+		Status:  string(status),
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// 確認のあとに同じユーザーの参加が先に通った
+			return c.JSON(http.StatusConflict, map[string]string{"error": "You are already a member of this group or your join request is pending"})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to join group: " + err.Error()})
 	}
 
+	// 実際にメンバーを追加できたときだけ招待コードの利用回数を増やす
+	if _, err := qtx.UseInvitationCode(ctx, database.UseInvitationCodeParams{
+		ID:             group.ID,
+		InvitationCode: req.Code,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// 確認後に期限切れ・上限到達・再発行された
+			latest, err := h.queries.GetJobGroupByID(ctx, group.ID)
+			if err != nil || latest.InvitationCode != req.Code {
+				return respondInvitationError(c, errInvitationInvalid)
+			}
+			if reason := invitationCodeError(latest, time.Now()); reason != nil {
+				return respondInvitationError(c, reason)
+			}
+			return respondInvitationError(c, errInvitationInvalid)
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// ADMIN に承認を依頼する（devバイパス時は送らない）
+	if status == MemberStatusPending && shouldNotify(c) {
+		if err := notifyJoinRequest(ctx, qtx, group, userUUID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	if status == MemberStatusPending {
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"message": "Join request sent. Waiting for approval",
+			"group":   group,
+			"member":  member,
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "Joined successfully",
 		"group":   group,
@@ -133,18 +216,7 @@ func (h *Handler) JoinGroup(c echo.Context) error {
 	})
 }
 
-// ヘルパー関数: ランダムな文字列を生成
-func generateRandomString(n int) string {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	rand.Seed(time.Now().UnixNano())
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
-
-// グループ名変更（ownerのみ）
+// グループ名変更（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
 func (h *Handler) UpdateGroupName(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -164,35 +236,14 @@ func (h *Handler) UpdateGroupName(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
 	}
 
-	userUUID, err := h.userUUIDFromAuth(c)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
-		}
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-	}
-
-	// グループ存在確認（deleted_at IS NULL を含む）
-	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	updated, err := h.queries.UpdateJobGroupName(ctx, database.UpdateJobGroupNameParams{
+		ID:   groupID,
+		Name: req.Name,
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
-	}
-
-	// owner 以外は変更不可
-	if group.OwnerID != userUUID {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only owner can update group"})
-	}
-
-	updated, err := h.queries.UpdateJobGroupName(ctx, database.UpdateJobGroupNameParams{
-		ID:      groupID,
-		Name:    req.Name,
-		OwnerID: userUUID,
-	})
-	if err != nil {
-		// owner_id と deleted_at 条件で更新失敗する可能性があるが、ここでは 500 扱い
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update group"})
 	}
 
@@ -240,7 +291,7 @@ func (h *Handler) DissolveGroup(c echo.Context) error {
 	qtx := h.queries.WithTx(tx)
 
 	// OPEN の募集を CLOSED にする
-	if _, err := qtx.CloseOpenShiftTradesByGroup(ctx, groupID); err != nil {
+	if _, err := closeOpenTradesByGroup(ctx, qtx, groupID, userUUID); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to close open trades"})
 	}
 
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+	"net/http"
+	"shift-change-app/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const (
+	// 読み間違えやすい文字（0/O, 1/l/I）を除いた英数字
+	invitationCodeLetters = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	invitationCodeLength  = 8
+
+	// 招待コードが既存のものと衝突したときに作り直す回数
+	invitationCodeAttempts = 5
+)
+
+// 招待コードの状態による参加エラー
+var (
+	errInvitationInvalid   = errors.New("invalid invitation code")
+	errInvitationExpired   = errors.New("invitation code has expired")
+	errInvitationExhausted = errors.New("invitation code has reached its usage limit")
+)
+
+// crypto/rand で招待コードを作る
+func generateInvitationCode() (string, error) {
+	max := big.NewInt(int64(len(invitationCodeLetters)))
+	b := make([]byte, invitationCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = invitationCodeLetters[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// job_groups.invitation_code の UNIQUE 制約違反か
+func isInvitationCodeConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "job_groups_invitation_code_key"
+}
+
+// 招待コードが使えない理由を返す（使えるなら nil）
+func invitationCodeError(group database.JobGroup, now time.Time) error {
+	if group.InvitationExpiresAt.Valid && !group.InvitationExpiresAt.Time.After(now) {
+		return errInvitationExpired
+	}
+	if group.InvitationMaxUses.Valid && group.InvitationUseCount >= group.InvitationMaxUses.Int32 {
+		return errInvitationExhausted
+	}
+	return nil
+}
+
+// 参加エラーをレスポンスにする
+func respondInvitationError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errInvitationInvalid):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Invalid invitation code", "code": "INVITATION_INVALID"})
+	case errors.Is(err, errInvitationExpired):
+		return c.JSON(http.StatusGone, map[string]string{"error": "Invitation code has expired", "code": "INVITATION_EXPIRED"})
+	case errors.Is(err, errInvitationExhausted):
+		return c.JSON(http.StatusGone, map[string]string{"error": "Invitation code has reached its usage limit", "code": "INVITATION_EXHAUSTED"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// 招待コードの再発行（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
+// 古いコードはその時点で使えなくなる
+func (h *Handler) RotateInvitationCode(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	type Request struct {
+		ExpiresAt *time.Time `json:"expires_at"` // 省略時は無期限
+		MaxUses   *int32     `json:"max_uses"`   // 省略時は無制限
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	var expiresAt sql.NullTime
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+		}
+		expiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+	var maxUses sql.NullInt32
+	if req.MaxUses != nil {
+		if *req.MaxUses <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_uses must be positive"})
+		}
+		maxUses = sql.NullInt32{Int32: *req.MaxUses, Valid: true}
+	}
+
+	for attempt := 0; attempt < invitationCodeAttempts; attempt++ {
+		code, err := generateInvitationCode()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate invitation code"})
+		}
+
+		group, err := h.queries.RotateInvitationCode(ctx, database.RotateInvitationCodeParams{
+			ID:                  groupID,
+			InvitationCode:      code,
+			InvitationExpiresAt: expiresAt,
+			InvitationMaxUses:   maxUses,
+		})
+		if err == nil {
+			return c.JSON(http.StatusOK, group)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		if !isInvitationCodeConflict(err) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate a unique invitation code"})
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/outbox"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// MemberStatus は所属の状態（group_members.status）
+type MemberStatus string
+
+const (
+	MemberStatusPending MemberStatus = "PENDING" // 参加承認待ち
+	MemberStatusActive  MemberStatus = "ACTIVE"  // メンバー
+)
+
+// 参加申請の承認・見送りボタンの postback（data は URL クエリ形式）
+const (
+	postbackActionApproveJoin = "approve_join"
+	postbackActionRejectJoin  = "reject_join"
+)
+
+// 承認待ちの申請がない（承認・見送り済み、取り下げ済み）
+var errJoinRequestNotFound = errors.New("join request not found or already decided")
+
+// 参加申請の一覧（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
+func (h *Handler) ListJoinRequests(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	requests, err := h.queries.ListPendingGroupMembers(ctx, groupID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch join requests"})
+	}
+
+	return c.JSON(http.StatusOK, requests)
+}
+
+// 参加申請を承認する（ADMINのみ）
+func (h *Handler) ApproveJoinRequest(c echo.Context) error {
+	return h.decideJoinRequestHandler(c, true)
+}
+
+// 参加申請を見送る（ADMINのみ）
+func (h *Handler) RejectJoinRequest(c echo.Context) error {
+	return h.decideJoinRequestHandler(c, false)
+}
+
+func (h *Handler) decideJoinRequestHandler(c echo.Context, approve bool) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user_id"})
+	}
+
+	actor, ok := groupMemberFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You do not have permission for this operation"})
+	}
+
+	// 申請者に結果を知らせる（devバイパス時は送らない）
+	if _, err := h.decideJoinRequest(ctx, groupID, targetID, actor.UserID, approve, shouldNotify(c)); err != nil {
+		if errors.Is(err, errJoinRequestNotFound) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Join request not found or already decided"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if approve {
+		return c.JSON(http.StatusOK, map[string]string{"message": "Join request approved"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Join request rejected"})
+}
+
+// 参加申請を承認（ACTIVE にする）または見送る（申請を消す）
+// notify が true なら申請者への結果の通知も同じトランザクションで積む
+// 画面の API と LINE の postback の両方から呼ばれる。actorID が ADMIN かは呼び出し側で確認する
+func (h *Handler) decideJoinRequest(ctx context.Context, groupID, userID, actorID uuid.UUID, approve, notify bool) (database.JobGroup, error) {
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.JobGroup{}, errJoinRequestNotFound
+		}
+		return database.JobGroup{}, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.JobGroup{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	if approve {
+		_, err = qtx.ApproveGroupMember(ctx, database.ApproveGroupMemberParams{
+			ApprovedBy: actorUUID(actorID),
+			GroupID:    groupID,
+			UserID:     userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.JobGroup{}, errJoinRequestNotFound
+		}
+		if err != nil {
+			return database.JobGroup{}, err
+		}
+	} else {
+		n, err := qtx.RejectGroupMember(ctx, database.RejectGroupMemberParams{
+			GroupID: groupID,
+			UserID:  userID,
+		})
+		if err != nil {
+			return database.JobGroup{}, err
+		}
+		if n == 0 {
+			return database.JobGroup{}, errJoinRequestNotFound
+		}
+	}
+
+	if notify {
+		if err := notifyJoinDecision(ctx, qtx, group, userID, approve); err != nil {
+			return database.JobGroup{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.JobGroup{}, err
+	}
+	return group, nil
+}
+
+// グループの ADMIN に参加申請を知らせる（承認・見送りボタン付き）
+// 参加申請と同じトランザクションの q を渡す
+func notifyJoinRequest(ctx context.Context, q *database.Queries, group database.JobGroup, applicantID uuid.UUID) error {
+	lineIDs, err := q.GetGroupAdminLineIDs(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	to, _ := filterValidLineUserIDs(lineIDs)
+	if len(to) == 0 {
+		return nil
+	}
+
+	applicantName := "ユーザー"
+	if applicant, err := q.GetUserByID(ctx, applicantID); err == nil {
+		applicantName = applicant.DisplayName
+	}
+
+	text := applicantName + " さんが「" + group.Name + "」への参加を申請しています"
+	buttons := linebot.NewButtonsTemplate("", "参加申請", truncateRunes(text, 60),
+		linebot.NewPostbackAction("承認する", joinPostbackData(postbackActionApproveJoin, group.ID, applicantID), "", "承認する", "", ""),
+		linebot.NewPostbackAction("見送る", joinPostbackData(postbackActionRejectJoin, group.ID, applicantID), "", "見送る", "", ""),
+	)
+	msg := linebot.NewTemplateMessage("🙋 "+text, buttons)
+
+	return outbox.Multicast(ctx, q, to, msg)
+}
+
+// 申請者に承認・見送りの結果を知らせる
+func notifyJoinDecision(ctx context.Context, q *database.Queries, group database.JobGroup, applicantID uuid.UUID, approved bool) error {
+	msg := "🙇 グループ「" + group.Name + "」への参加は見送られました"
+	if approved {
+		msg = "🎉 グループ「" + group.Name + "」への参加が承認されました！\n\n" +
+			"アプリからシフトの募集を確認できます。"
+	}
+	return enqueueUserText(ctx, q, applicantID, msg)
+}
+
+func joinPostbackData(action string, groupID, userID uuid.UUID) string {
+	v := url.Values{}
+	v.Set("action", action)
+	v.Set("group_id", groupID.String())
+	v.Set("user_id", userID.String())
+	return v.Encode()
+}
+
+// 承認・見送りボタンの postback を処理し、押した人への返信文を返す
+// 押した人がそのグループの ADMIN でなければ何もしない
+func (h *Handler) handleJoinPostback(ctx context.Context, actor database.User, data url.Values) (string, error) {
+	approve := data.Get("action") == postbackActionApproveJoin
+
+	groupID, err := uuid.Parse(data.Get("group_id"))
+	if err != nil {
+		return "", err
+	}
+	userID, err := uuid.Parse(data.Get("user_id"))
+	if err != nil {
+		return "", err
+	}
+
+	isAdmin, err := h.isGroupAdmin(ctx, groupID, actor.ID)
+	if err != nil {
+		return "", err
+	}
+	if !isAdmin {
+		return "この参加申請を処理する権限がありません", nil
+	}
+
+	group, err := h.decideJoinRequest(ctx, groupID, userID, actor.ID, approve, true)
+	if err != nil {
+		if errors.Is(err, errJoinRequestNotFound) {
+			return "この参加申請はすでに処理されています", nil
+		}
+		return "", err
+	}
+
+	if approve {
+		return "✅ 「" + group.Name + "」への参加を承認しました", nil
+	}
+	return "「" + group.Name + "」への参加申請を見送りました", nil
+}
+
+// ボタンテンプレートの本文は文字数制限があるので切り詰める
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
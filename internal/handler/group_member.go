@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// グループを抜けるときの募集クローズ理由（shift_trade_events.reason）
+const (
+	tradeReasonMemberLeft    = "MEMBER_LEFT"    // 本人がグループを抜けた
+	tradeReasonMemberRemoved = "MEMBER_REMOVED" // ADMIN がメンバーを外した
+)
+
+// メンバー一覧（メンバーなら誰でも）
+func (h *Handler) ListGroupMembers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	isMember, err := h.isGroupMember(ctx, groupID, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !isMember {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this group"})
+	}
+
+	members, err := h.queries.ListGroupMembers(ctx, groupID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch members"})
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// メンバーを外す（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
+// owner は外せない。ADMIN を外せるのは owner だけ
+func (h *Handler) RemoveGroupMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user_id"})
+	}
+
+	actor, ok := groupMemberFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You do not have permission for this operation"})
+	}
+	if actor.UserID == targetID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Use POST /api/groups/:group_id/leave to leave the group"})
+	}
+
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
+	}
+	if group.OwnerID == targetID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "The owner cannot be removed"})
+	}
+
+	target, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  targetID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if GroupRole(target.Role) == GroupRoleAdmin && group.OwnerID != actor.UserID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the owner can remove an admin"})
+	}
+
+	// 外されたメンバーに知らせる（devバイパス時は送らない）
+	notice := ""
+	if shouldNotify(c) {
+		notice = "👋 グループ「" + group.Name + "」から外れました\n\n" +
+			"グループ管理者によってメンバーから外されました。"
+	}
+
+	closed, err := h.removeMembership(ctx, groupID, targetID, actor.UserID, tradeReasonMemberRemoved, notice, shouldNotify(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Member removed", "closed_trades": closed})
+}
+
+// グループを抜ける（owner は抜けられない）
+func (h *Handler) LeaveGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
+	}
+
+	isMember, err := h.isGroupMember(ctx, groupID, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !isMember {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this group"})
+	}
+
+	if group.OwnerID == userUUID {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The owner cannot leave the group. Transfer ownership or dissolve the group instead."})
+	}
+
+	// 募集への応募者や、引き受けていた枠の募集の作成者に知らせる（devバイパス時は送らない）
+	closed, err := h.removeMembership(ctx, groupID, userUUID, userUUID, tradeReasonMemberLeft, "", shouldNotify(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Left the group", "closed_trades": closed})
+}
+
+// メンバーをグループから外す
+// 本人の募集中の募集を CLOSED にしてその応募を取り消し、本人の応募中の応募を取り下げ、
+// 本人が引き受けたまだ始まっていない分割募集の枠を空きに戻してから所属を消す（同一トランザクション）
+// notice が空でなければ本人への通知も、notify なら取り消された応募者と枠が空いた募集の作成者への通知も同じトランザクションで積む
+func (h *Handler) removeMembership(ctx context.Context, groupID, userID, actorID uuid.UUID, reason, notice string, notify bool) (int64, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	closed, err := closeOpenTradesByRequesterInGroup(ctx, qtx, groupID, userID, actorID, reason)
+	if err != nil {
+		return 0, err
+	}
+	for _, tradeID := range closed {
+		applicants, err := qtx.CancelPendingTradeApplications(ctx, tradeID)
+		if err != nil {
+			return 0, err
+		}
+		if notify && len(applicants) > 0 {
+			if err := notifyApplicationsCancelledByLeave(ctx, qtx, tradeID, applicants); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := releaseAcceptedSegments(ctx, qtx, groupID, userID, actorID, reason, notify); err != nil {
+		return 0, err
+	}
+
+	if _, err := qtx.WithdrawPendingApplicationsInGroup(ctx, database.WithdrawPendingApplicationsInGroupParams{
+		ApplicantID: userID,
+		GroupID:     groupID,
+	}); err != nil {
+		return 0, err
+	}
+
+	if _, err := qtx.DeleteGroupMember(ctx, database.DeleteGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userID,
+	}); err != nil {
+		return 0, err
+	}
+
+	if notice != "" {
+		if err := enqueueUserText(ctx, qtx, userID, notice); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(closed)), nil
+}
+
+// グループを抜けるメンバーが引き受けた分割募集の枠を空きに戻す
+// 全ての枠が埋まって成立していた募集は募集中に戻し（FILLED → OPEN）、notify なら募集の作成者に知らせる
+func releaseAcceptedSegments(ctx context.Context, q *database.Queries, groupID, userID, actorID uuid.UUID, reason string, notify bool) error {
+	released, err := q.ReleaseAcceptorSegmentsInGroup(ctx, database.ReleaseAcceptorSegmentsInGroupParams{
+		AcceptorID: actorUUID(userID),
+		GroupID:    groupID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 募集ごとにまとめる（通知も募集ごとに1通）
+	byTrade := map[uuid.UUID][]database.ShiftTradeSegment{}
+	tradeIDs := []uuid.UUID{}
+	for _, seg := range released {
+		if _, ok := byTrade[seg.TradeID]; !ok {
+			tradeIDs = append(tradeIDs, seg.TradeID)
+		}
+		byTrade[seg.TradeID] = append(byTrade[seg.TradeID], seg)
+	}
+
+	for _, tradeID := range tradeIDs {
+		trade, err := q.ReopenSplitShiftTrade(ctx, tradeID)
+		switch {
+		case err == nil:
+			if err := recordTradeTransition(ctx, q, trade.ID, TradeStatusFilled, TradeStatusOpen, actorUUID(actorID), reason); err != nil {
+				return err
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			// まだ空き枠があった（OPEN のまま）
+			trade, err = q.GetTradeByID(ctx, tradeID)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if notify {
+			msg := "🔁 引き受けてもらっていた時間帯が空きました\n\n" +
+				"シフト: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
+				formatSegmentsJST(byTrade[tradeID]) + "\n\n" +
+				"引き受けていたメンバーがグループを抜けたため、この時間帯をふたたび募集しています。"
+			if err := enqueueUserText(ctx, q, trade.RequesterID, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 作成者がグループを抜けて閉じた募集の応募者に、応募が取り消されたことを知らせる
+func notifyApplicationsCancelledByLeave(ctx context.Context, q *database.Queries, tradeID uuid.UUID, applicants []uuid.UUID) error {
+	trade, err := q.GetTradeByID(ctx, tradeID)
+	if err != nil {
+		return err
+	}
+	msg := "🙇 応募していたシフトの募集が終了しました\n\n" +
+		"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n\n" +
+		"募集した人がグループを抜けたため、募集は締め切られました。"
+	for _, applicantID := range applicants {
+		if err := enqueueUserText(ctx, q, applicantID, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// オーナーを譲る（ownerのみ）
+// 譲り先はグループのメンバーに限り、ADMIN でなければ ADMIN にする。元の owner は ADMIN のまま残る
+func (h *Handler) TransferOwnership(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	type Request struct {
+		UserID string `json:"user_id"`
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	newOwnerID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user_id"})
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
+	}
+	if group.OwnerID != userUUID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only owner can transfer ownership"})
+	}
+	if newOwnerID == userUUID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "You are already the owner"})
+	}
+
+	if _, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  newOwnerID,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	updated, err := transferOwnership(ctx, qtx, groupID, userUUID, newOwnerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "The owner has already changed"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 新しい owner に知らせる（devバイパス時は送らない）
+	if shouldNotify(c) {
+		if err := notifyNewOwner(ctx, qtx, updated); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+// owner を付け替え、新しい owner を ADMIN にする（呼び出し側のトランザクション内で使う）
+// owner がすでに変わっていれば sql.ErrNoRows を返す
+func transferOwnership(ctx context.Context, q *database.Queries, groupID, currentOwnerID, newOwnerID uuid.UUID) (database.JobGroup, error) {
+	group, err := q.TransferJobGroupOwnership(ctx, database.TransferJobGroupOwnershipParams{
+		NewOwnerID:     newOwnerID,
+		ID:             groupID,
+		CurrentOwnerID: currentOwnerID,
+	})
+	if err != nil {
+		return database.JobGroup{}, err
+	}
+
+	if _, err := q.UpdateGroupMemberRole(ctx, database.UpdateGroupMemberRoleParams{
+		GroupID: groupID,
+		UserID:  newOwnerID,
+		Role:    string(GroupRoleAdmin),
+	}); err != nil {
+		return database.JobGroup{}, err
+	}
+	return group, nil
+}
+
+// 退会する owner のグループを片付ける（WithdrawMe のトランザクション内で使う）
+// 他の ADMIN がいれば最も古くからいる ADMIN に譲り、自分しかいなければ解散する
+// 戻り値は新しい owner に引き継いだグループと、ADMIN ではないメンバーしか残らず先に譲る必要があるグループ
+func releaseOwnedGroups(ctx context.Context, q *database.Queries, ownerID uuid.UUID) (transferred, blocked []database.JobGroup, err error) {
+	owned, err := q.ListJobGroupsOwnedBy(ctx, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, g := range owned {
+		admin, err := q.GetLongestStandingAdmin(ctx, database.GetLongestStandingAdminParams{
+			GroupID: g.ID,
+			UserID:  ownerID,
+		})
+		if err == nil {
+			updated, err := transferOwnership(ctx, q, g.ID, ownerID, admin.UserID)
+			if err != nil {
+				return nil, nil, err
+			}
+			transferred = append(transferred, updated)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+
+		others, err := q.CountOtherActiveMembers(ctx, database.CountOtherActiveMembersParams{
+			GroupID: g.ID,
+			UserID:  ownerID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if others > 0 {
+			blocked = append(blocked, g)
+			continue
+		}
+
+		// 自分しかいないグループは解散する
+		if _, err := closeOpenTradesByGroup(ctx, q, g.ID, ownerID); err != nil {
+			return nil, nil, err
+		}
+		if _, err := q.SoftDeleteJobGroup(ctx, database.SoftDeleteJobGroupParams{
+			ID:      g.ID,
+			OwnerID: ownerID,
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return transferred, blocked, nil
+}
+
+// 新しい owner にオーナーになったことを知らせる（付け替えと同じトランザクションの q を渡す）
+func notifyNewOwner(ctx context.Context, q *database.Queries, group database.JobGroup) error {
+	msg := "👑 グループ「" + group.Name + "」のオーナーになりました\n\n" +
+		"グループの解散やオーナーの引き継ぎができるようになりました。"
+	return enqueueUserText(ctx, q, group.OwnerID, msg)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// GroupRole はグループ内の役割（group_members.role）
+type GroupRole string
+
+const (
+	GroupRoleAdmin  GroupRole = "ADMIN"  // 管理者（作成者は必ず ADMIN）
+	GroupRoleMember GroupRole = "MEMBER" // 一般メンバー
+)
+
+// RequireGroupRole の結果を echo.Context に入れるキー
+const ctxGroupMember = "group_member"
+
+// RequireGroupRole は :group_id のグループで指定の役割を持つメンバーだけを通すミドルウェア
+// AuthMiddleware の後ろで使う。通過したメンバー情報は groupMemberFromContext で取り出せる
+func (h *Handler) RequireGroupRole(roles ...GroupRole) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			groupID, err := uuid.Parse(c.Param("group_id"))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+			}
+
+			userUUID, err := h.userUUIDFromAuth(c)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+				}
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			}
+
+			gm, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+				GroupID: groupID,
+				UserID:  userUUID,
+			})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this group"})
+				}
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+
+			if !hasGroupRole(gm, roles...) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "You do not have permission for this operation"})
+			}
+
+			c.Set(ctxGroupMember, gm)
+			return next(c)
+		}
+	}
+}
+
+// RequireGroupRole を通過したメンバー情報
+func groupMemberFromContext(c echo.Context) (database.GroupMember, bool) {
+	gm, ok := c.Get(ctxGroupMember).(database.GroupMember)
+	return gm, ok
+}
+
+func hasGroupRole(gm database.GroupMember, roles ...GroupRole) bool {
+	for _, r := range roles {
+		if GroupRole(gm.Role) == r {
+			return true
+		}
+	}
+	return false
+}
+
+// グループの管理者か（メンバーでなければ false）
+func (h *Handler) isGroupAdmin(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	gm, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return GroupRole(gm.Role) == GroupRoleAdmin, nil
+}
+
+// メンバーの役割を変更する（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
+// オーナーは常に ADMIN のままにする。ADMIN を MEMBER に戻せるのはオーナーだけ（外すときと同じ）
+func (h *Handler) UpdateMemberRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user_id"})
+	}
+
+	type Request struct {
+		Role string `json:"role"`
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	role := GroupRole(req.Role)
+	if role != GroupRoleAdmin && role != GroupRoleMember {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be ADMIN or MEMBER"})
+	}
+
+	actor, ok := groupMemberFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "You do not have permission for this operation"})
+	}
+
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
+	}
+	if group.OwnerID == targetID && role != GroupRoleAdmin {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The owner must stay ADMIN"})
+	}
+
+	target, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  targetID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if GroupRole(target.Role) == GroupRoleAdmin && role == GroupRoleMember && group.OwnerID != actor.UserID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the owner can demote an admin"})
+	}
+
+	member, err := h.queries.UpdateGroupMemberRole(ctx, database.UpdateGroupMemberRoleParams{
+		GroupID: groupID,
+		UserID:  targetID,
+		Role:    string(role),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, member)
+}
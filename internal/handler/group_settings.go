@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// AcceptMode はグループの引き受け方式（job_groups.accept_mode）
+type AcceptMode string
+
+const (
+	AcceptModeFirstCome AcceptMode = "FIRST_COME" // 早い者勝ち（引き受けた時点で成立）
+	AcceptModeApproval  AcceptMode = "APPROVAL"   // 応募を集め、作成者・管理者が1人を選ぶ
+)
+
+// グループ設定の変更（ADMINのみ。RequireGroupRole(GroupRoleAdmin) の後ろで使う）
+func (h *Handler) UpdateGroupSettings(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+
+	// 省略した項目は変更しない
+	type Request struct {
+		AcceptMode             *string  `json:"accept_mode"`
+		RequireJoinApproval    *bool    `json:"require_join_approval"`
+		ReminderOffsetsMinutes *[]int32 `json:"reminder_offsets_minutes"` // 空配列ならリマインドしない
+		NotifyMode             *string  `json:"notify_mode"`
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	current, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch group"})
+	}
+
+	mode := AcceptMode(current.AcceptMode)
+	if req.AcceptMode != nil {
+		mode = AcceptMode(*req.AcceptMode)
+		if mode != AcceptModeFirstCome && mode != AcceptModeApproval {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "accept_mode must be FIRST_COME or APPROVAL"})
+		}
+	}
+	requireJoinApproval := current.RequireJoinApproval
+	if req.RequireJoinApproval != nil {
+		requireJoinApproval = *req.RequireJoinApproval
+	}
+	reminderOffsets := current.ReminderOffsetsMinutes
+	if req.ReminderOffsetsMinutes != nil {
+		reminderOffsets, err = normalizeReminderOffsets(*req.ReminderOffsetsMinutes)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	notifyMode := NotifyMode(current.NotifyMode)
+	if req.NotifyMode != nil {
+		notifyMode = NotifyMode(*req.NotifyMode)
+		if notifyMode != NotifyModeIndividual && notifyMode != NotifyModeChat {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "notify_mode must be INDIVIDUAL or CHAT"})
+		}
+		if notifyMode == NotifyModeChat && !current.LineChatID.Valid {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Link a LINE group chat with /link before using CHAT"})
+		}
+	}
+
+	group, err := h.queries.UpdateJobGroupSettings(ctx, database.UpdateJobGroupSettingsParams{
+		ID:                     groupID,
+		AcceptMode:             string(mode),
+		RequireJoinApproval:    requireJoinApproval,
+		ReminderOffsetsMinutes: reminderOffsets,
+		NotifyMode:             string(notifyMode),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, group)
+}
@@ -2,20 +2,34 @@ package handler
 
 import (
 	"database/sql"
-	"github.com/line/line-bot-sdk-go/v7/linebot"
 	"shift-change-app/internal/database"
+	"sync"
 )
 
+// 同時に処理する Webhook の配信（リクエスト）の上限
+const maxConcurrentWebhookBatches = 16
+
 type Handler struct {
-	db      *sql.DB
-	queries *database.Queries
-	bot     *linebot.Client
+	db            *sql.DB
+	queries       *database.Queries
+	notifier      Notifier
+	channelSecret string
+	sessions      *SessionSigner
+
+	// Webhook イベントのバックグラウンド処理
+	webhookWG  sync.WaitGroup
+	webhookSem chan struct{}
 }
 
-func NewHandler(db *sql.DB, queries *database.Queries, bot *linebot.Client) *Handler {
+// notifier には本番なら NewLineNotifier、テストなら NewRecordingNotifier を渡す
+// channelSecret は Webhook の署名検証に、sessions は HTML 画面のセッション Cookie に使う
+func NewHandler(db *sql.DB, queries *database.Queries, notifier Notifier, channelSecret string, sessions *SessionSigner) *Handler {
 	return &Handler{
-		db:      db,
-		queries: queries,
-		bot:     bot,
+		db:            db,
+		queries:       queries,
+		notifier:      notifier,
+		channelSecret: channelSecret,
+		sessions:      sessions,
+		webhookSem:    make(chan struct{}, maxConcurrentWebhookBatches),
 	}
 }
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"shift-change-app/internal/database"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// NotifyMode はグループ全体への通知の送り方（job_groups.notify_mode）
+type NotifyMode string
+
+const (
+	NotifyModeIndividual NotifyMode = "INDIVIDUAL" // メンバー1人ずつに送る
+	NotifyModeChat       NotifyMode = "CHAT"       // 連携した LINE のトークに投稿する
+)
+
+// LINE のトークで使えるコマンド
+const (
+	chatCommandLink   = "/link"
+	chatCommandUnlink = "/unlink"
+)
+
+const chatJoinText = "招待ありがとうございます！🙇\n\n" +
+	"グループの管理者がこのトークで\n" +
+	"/link 招待コード\n" +
+	"と送ると、シフトの募集・成立・リマインドをこのトークに投稿します。\n\n" +
+	"連携をやめるときは /unlink と送ってください。"
+
+// LINE のグループ ID（C...）・複数人トーク ID（R...）の簡易バリデーション
+func isValidLineChatID(id string) bool {
+	id = strings.TrimSpace(id)
+	if len(id) != 33 || !(strings.HasPrefix(id, "C") || strings.HasPrefix(id, "R")) {
+		return false
+	}
+	for _, ch := range id[1:] {
+		if !((ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')) {
+			return false
+		}
+	}
+	return true
+}
+
+// イベントが LINE のグループ・複数人トークから届いたものなら、そのトークの ID を返す
+func eventChatID(source *linebot.EventSource) (string, bool) {
+	if source == nil {
+		return "", false
+	}
+	switch source.Type {
+	case linebot.EventSourceTypeGroup:
+		return source.GroupID, source.GroupID != ""
+	case linebot.EventSourceTypeRoom:
+		return source.RoomID, source.RoomID != ""
+	default:
+		return "", false
+	}
+}
+
+// トークでのイベント（bot の参加・退出、/link・/unlink）を処理する
+// それ以外のメッセージには反応しない（トークの会話に割り込まない）
+func (h *Handler) handleChatEvent(ctx context.Context, event *linebot.Event, chatID string) error {
+	switch event.Type {
+	case linebot.EventTypeJoin:
+		h.replyEvent(ctx, event, linebot.NewTextMessage(chatJoinText))
+		return nil
+
+	case linebot.EventTypeLeave:
+		// 退出したトークには送れないので、メンバー1人ずつの通知に戻す
+		unlinked, err := h.queries.UnlinkJobGroupChat(ctx, sql.NullString{String: chatID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if unlinked > 0 {
+			log.Printf("[webhook] bot left chat %s, unlinked", chatID)
+		}
+		return nil
+
+	case linebot.EventTypeMessage:
+		text, ok := event.Message.(*linebot.TextMessage)
+		if !ok {
+			return nil
+		}
+		fields := strings.Fields(text.Text)
+		if len(fields) == 0 {
+			return nil
+		}
+
+		var reply string
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case chatCommandLink:
+			if len(fields) != 2 {
+				reply = "「/link 招待コード」の形で送ってください"
+				break
+			}
+			reply, err = h.linkChat(ctx, event.Source.UserID, chatID, fields[1])
+		case chatCommandUnlink:
+			reply, err = h.unlinkChat(ctx, event.Source.UserID, chatID)
+		default:
+			return nil
+		}
+		if err != nil {
+			log.Printf("[webhook] chat command %q error: %v", fields[0], err)
+			reply = "処理に失敗しました。時間をおいてもう一度お試しください"
+		}
+		h.replyEvent(ctx, event, linebot.NewTextMessage(reply))
+	}
+	return nil
+}
+
+// /link: 招待コードのグループとトークを連携する（送った人がそのグループの管理者のときだけ）
+// 別のグループと連携していたトークは、付け替える
+func (h *Handler) linkChat(ctx context.Context, lineUserID, chatID, code string) (string, error) {
+	user, ok, err := h.chatSender(ctx, lineUserID)
+	if err != nil || !ok {
+		return "先に bot と友だちになり、アプリで利用登録をしてください", err
+	}
+
+	group, err := h.queries.GetJobGroupByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "招待コードが見つかりません", nil
+		}
+		return "", err
+	}
+	isAdmin, err := h.isGroupAdmin(ctx, group.ID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if !isAdmin {
+		return "連携できるのはグループの管理者だけです", nil
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	qtx := h.queries.WithTx(tx)
+
+	if _, err := qtx.UnlinkJobGroupChat(ctx, sql.NullString{String: chatID, Valid: true}); err != nil {
+		return "", err
+	}
+	if group.LineChatID.Valid && group.LineChatID.String != chatID {
+		if _, err := qtx.UnlinkJobGroupChat(ctx, group.LineChatID); err != nil {
+			return "", err
+		}
+	}
+	if _, err := qtx.LinkJobGroupChat(ctx, database.LinkJobGroupChatParams{
+		ID:         group.ID,
+		LineChatID: sql.NullString{String: chatID, Valid: true},
+	}); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return "🔗 「" + group.Name + "」と連携しました！\n\n" +
+		"シフトの募集・成立・リマインドをこのトークに投稿します。\n" +
+		"メンバー1人ずつへの通知に戻すときは /unlink と送ってください。", nil
+}
+
+// /unlink: トークとの連携を外す（送った人が連携中のグループの管理者のときだけ）
+func (h *Handler) unlinkChat(ctx context.Context, lineUserID, chatID string) (string, error) {
+	user, ok, err := h.chatSender(ctx, lineUserID)
+	if err != nil || !ok {
+		return "先に bot と友だちになり、アプリで利用登録をしてください", err
+	}
+
+	group, err := h.queries.GetJobGroupByChatID(ctx, sql.NullString{String: chatID, Valid: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "このトークはどのグループとも連携していません", nil
+		}
+		return "", err
+	}
+	isAdmin, err := h.isGroupAdmin(ctx, group.ID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if !isAdmin {
+		return "連携を外せるのはグループの管理者だけです", nil
+	}
+
+	if _, err := h.queries.UnlinkJobGroupChat(ctx, sql.NullString{String: chatID, Valid: true}); err != nil {
+		return "", err
+	}
+	return "「" + group.Name + "」との連携を外しました。\n通知はメンバー1人ずつに送ります。", nil
+}
+
+// トークでコマンドを送った登録済みユーザー（userId が取れない・未登録なら ok=false）
+func (h *Handler) chatSender(ctx context.Context, lineUserID string) (database.User, bool, error) {
+	if lineUserID == "" {
+		return database.User{}, false, nil
+	}
+	user, err := h.queries.GetUserByLineID(ctx, lineUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, false, nil
+		}
+		return database.User{}, false, err
+	}
+	return user, true, nil
+}
+
+// 募集が埋まったことを連携したトークに投稿する（通知の送り方が CHAT のグループだけ）
+func enqueueChatFilled(ctx context.Context, q *database.Queries, trade database.ShiftTrade, acceptorName string) error {
+	msg := "✅ シフトが埋まりました\n\n" +
+		"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
+		"代わる人: " + acceptorName + " さん"
+	_, err := enqueueChatPost(ctx, q, trade.GroupID, linebot.NewTextMessage(msg))
+	return err
+}
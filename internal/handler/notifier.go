@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"shift-change-app/internal/outbox"
+	"sync"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// Notifier は LINE へのメッセージ送信を抽象化する
+// 本番では LineNotifier、テストでは RecordingNotifier を差し込む
+type Notifier interface {
+	// 1ユーザーへのプッシュ送信
+	Push(ctx context.Context, to string, messages ...linebot.SendingMessage) error
+	// 複数ユーザーへの一斉送信
+	Multicast(ctx context.Context, to []string, messages ...linebot.SendingMessage) error
+	// Webhook イベントへの返信
+	Reply(ctx context.Context, replyToken string, messages ...linebot.SendingMessage) error
+}
+
+// LineNotifier は Messaging API を叩く本番用の Notifier
+// bot は linebot.WithHTTPClient(outbox.NewHTTPClient()) で作る（outbox からの再送に X-Line-Retry-Key を付けるため）
+type LineNotifier struct {
+	bot *linebot.Client
+}
+
+func NewLineNotifier(bot *linebot.Client) *LineNotifier {
+	return &LineNotifier{bot: bot}
+}
+
+func (n *LineNotifier) Push(ctx context.Context, to string, messages ...linebot.SendingMessage) error {
+	_, err := n.bot.PushMessage(to, messages...).WithContext(ctx).Do()
+	return err
+}
+
+func (n *LineNotifier) Multicast(ctx context.Context, to []string, messages ...linebot.SendingMessage) error {
+	_, err := n.bot.Multicast(to, messages...).WithContext(ctx).Do()
+	return err
+}
+
+func (n *LineNotifier) Reply(ctx context.Context, replyToken string, messages ...linebot.SendingMessage) error {
+	_, err := n.bot.ReplyMessage(replyToken, messages...).WithContext(ctx).Do()
+	return err
+}
+
+// 送信種別
+type SentKind string
+
+const (
+	SentPush      SentKind = "push"
+	SentMulticast SentKind = "multicast"
+	SentReply     SentKind = "reply"
+)
+
+// RecordingNotifier に記録された1回分の送信
+type SentMessage struct {
+	Kind       SentKind
+	To         []string // Push は1件、Multicast は宛先全件
+	ReplyToken string
+	RetryKey   string // outbox から送ったときの X-Line-Retry-Key
+	Messages   []linebot.SendingMessage
+}
+
+// Texts はテキストメッセージの本文だけを取り出す（テストでの比較用）
+func (m SentMessage) Texts() []string {
+	var texts []string
+	for _, msg := range m.Messages {
+		if t, ok := msg.(*linebot.TextMessage); ok {
+			texts = append(texts, t.Text)
+		}
+	}
+	return texts
+}
+
+// RecordingNotifier はネットワークに出ずに送信内容をメモリに記録する Notifier
+// Err をセットすると全ての送信がそのエラーで失敗する
+type RecordingNotifier struct {
+	mu   sync.Mutex
+	sent []SentMessage
+	Err  error
+}
+
+func NewRecordingNotifier() *RecordingNotifier {
+	return &RecordingNotifier{}
+}
+
+func (n *RecordingNotifier) record(m SentMessage) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Err != nil {
+		return n.Err
+	}
+	n.sent = append(n.sent, m)
+	return nil
+}
+
+func (n *RecordingNotifier) Push(ctx context.Context, to string, messages ...linebot.SendingMessage) error {
+	key, _ := outbox.RetryKeyFromContext(ctx)
+	return n.record(SentMessage{Kind: SentPush, To: []string{to}, RetryKey: key, Messages: messages})
+}
+
+func (n *RecordingNotifier) Multicast(ctx context.Context, to []string, messages ...linebot.SendingMessage) error {
+	key, _ := outbox.RetryKeyFromContext(ctx)
+	return n.record(SentMessage{Kind: SentMulticast, To: append([]string(nil), to...), RetryKey: key, Messages: messages})
+}
+
+func (n *RecordingNotifier) Reply(ctx context.Context, replyToken string, messages ...linebot.SendingMessage) error {
+	return n.record(SentMessage{Kind: SentReply, ReplyToken: replyToken, Messages: messages})
+}
+
+// Sent は記録済みの送信をコピーして返す
+func (n *RecordingNotifier) Sent() []SentMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]SentMessage(nil), n.sent...)
+}
+
+// SentTo は指定した LINE userId 宛ての送信だけを返す（Multicast も含む）
+func (n *RecordingNotifier) SentTo(lineUserID string) []SentMessage {
+	var out []SentMessage
+	for _, m := range n.Sent() {
+		for _, to := range m.To {
+			if to == lineUserID {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Reset は記録をクリアする
+func (n *RecordingNotifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = nil
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/outbox"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// 通知を outbox に積むか（devバイパス時は送らない）
+func shouldNotify(c echo.Context) bool {
+	if isDevBypassRequest(c) {
+		c.Logger().Info("[notify] skip notification in dev-bypass request")
+		return false
+	}
+	return true
+}
+
+// 1ユーザーへの通知を outbox に積む（状態の変更と同じトランザクションの q を渡す）
+// LINE userId が不正（退会済みなど）なら何もしない
+func enqueuePush(ctx context.Context, q *database.Queries, to string, messages ...linebot.SendingMessage) error {
+	if !isValidLineUserID(to) {
+		return nil
+	}
+	return outbox.Push(ctx, q, to, messages...)
+}
+
+// ユーザーまたは LINE のトークへの通知を outbox に積む（Webhook の再送イベントへの返信用）
+func enqueueChatOrUserPush(ctx context.Context, q *database.Queries, to string, messages ...linebot.SendingMessage) error {
+	if isValidLineChatID(to) {
+		return outbox.Push(ctx, q, to, messages...)
+	}
+	return enqueuePush(ctx, q, to, messages...)
+}
+
+// ユーザーの LINE へのテキスト通知を outbox に積む（ユーザーがいなければ何もしない）
+func enqueueUserText(ctx context.Context, q *database.Queries, userID uuid.UUID, msg string) error {
+	return enqueueUserMessages(ctx, q, userID, linebot.NewTextMessage(msg))
+}
+
+// ユーザーの LINE への通知を outbox に積む（ユーザーがいない、または bot をブロックしていれば何もしない）
+func enqueueUserMessages(ctx context.Context, q *database.Queries, userID uuid.UUID, messages ...linebot.SendingMessage) error {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !user.BotReachable {
+		return nil
+	}
+	return enqueuePush(ctx, q, user.LineUserID, messages...)
+}
+
+// グループ全体への通知を outbox に積む
+// 通知の送り方が CHAT なら連携したトークに1回投稿し、そうでなければメンバー全員に送る（except の LINE userId は除く）
+func enqueueGroupNotice(ctx context.Context, q *database.Queries, groupID uuid.UUID, except string, messages ...linebot.SendingMessage) error {
+	posted, err := enqueueChatPost(ctx, q, groupID, messages...)
+	if err != nil || posted {
+		return err
+	}
+	return enqueueGroupMulticast(ctx, q, groupID, except, messages...)
+}
+
+// 通知の送り方が CHAT のグループなら、連携したトークへの投稿を outbox に積む（積んだら true）
+func enqueueChatPost(ctx context.Context, q *database.Queries, groupID uuid.UUID, messages ...linebot.SendingMessage) (bool, error) {
+	group, err := q.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		return false, err
+	}
+	if NotifyMode(group.NotifyMode) != NotifyModeChat || !isValidLineChatID(group.LineChatID.String) {
+		return false, nil
+	}
+	return true, outbox.Push(ctx, q, group.LineChatID.String, messages...)
+}
+
+// グループメンバー全員への通知を outbox に積む（except の LINE userId は除く）
+func enqueueGroupMulticast(ctx context.Context, q *database.Queries, groupID uuid.UUID, except string, messages ...linebot.SendingMessage) error {
+	lineIDs, err := q.GetGroupMemberLineIDs(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	valid, _ := filterValidLineUserIDs(lineIDs)
+	to := make([]string, 0, len(valid))
+	for _, id := range valid {
+		if id != except {
+			to = append(to, id)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	return outbox.Multicast(ctx, q, to, messages...)
+}
+
+// outbox の状態確認（METRICS_ENABLED=1 かつ DEBUG_TOKEN を設定したときだけ公開。宛先やエラー内容は返さない）
+func (h *Handler) OutboxStatus(c echo.Context) error {
+	report, err := outbox.Status(c.Request().Context(), h.queries, 50)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"shift-change-app/internal/database"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// リマインドの段階（開始の何分前に送るか）の制限
+const (
+	maxReminderStages        = 6
+	minReminderOffsetMinutes = 10
+	maxReminderOffsetMinutes = 7 * 24 * 60
+)
+
+// リマインドの段階を検証し、開始から遠い順に並べ替えて重複を除く
+func normalizeReminderOffsets(offsets []int32) ([]int32, error) {
+	seen := make(map[int32]bool, len(offsets))
+	normalized := make([]int32, 0, len(offsets))
+	for _, m := range offsets {
+		if m < minReminderOffsetMinutes || m > maxReminderOffsetMinutes {
+			return nil, errors.New("reminder_offsets_minutes must be between " +
+				strconv.Itoa(minReminderOffsetMinutes) + " and " + strconv.Itoa(maxReminderOffsetMinutes))
+		}
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		normalized = append(normalized, m)
+	}
+	if len(normalized) > maxReminderStages {
+		return nil, errors.New("too many reminder stages (max " + strconv.Itoa(maxReminderStages) + ")")
+	}
+
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] > normalized[j] })
+	return normalized, nil
+}
+
+// SendDueReminders は時期を過ぎたリマインドを outbox に積む（リマインドワーカーから定期的に呼ばれる）
+// 段階ごとの shift_trade_reminders への記録と通知の積み込みを同じトランザクションで行うので、
+// 再起動や複数台での実行でも二重送信しない。送信の再試行は outbox のワーカーに任せる
+// 作成者に加えて、グループの他のメンバーにも引き受けを呼びかける
+func SendDueReminders(ctx context.Context, db *sql.DB, queries *database.Queries, now time.Time) error {
+	due, err := queries.ListDueTradeReminders(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		if err := enqueueDueReminder(ctx, db, queries, r); err != nil {
+			log.Println("Failed to enqueue reminder:", err)
+		}
+	}
+	return nil
+}
+
+func enqueueDueReminder(ctx context.Context, db *sql.DB, queries *database.Queries, r database.ListDueTradeRemindersRow) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	claimed, err := qtx.ClaimTradeReminder(ctx, database.ClaimTradeReminderParams{
+		TradeID:       r.ID,
+		OffsetMinutes: r.OffsetMinutes,
+	})
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return nil
+	}
+
+	if err := enqueueTradeReminder(ctx, qtx, r); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Enqueued %s reminder for trade %s", reminderStageLabel(r.OffsetMinutes), r.ID)
+	return nil
+}
+
+// 作成者とグループの他のメンバーへのリマインドを積む
+func enqueueTradeReminder(ctx context.Context, q *database.Queries, r database.ListDueTradeRemindersRow) error {
+	// 分割募集はまだ埋まっていない時間帯を知らせる
+	openText := ""
+	if r.SegmentMinutes > 0 {
+		open, err := q.ListOpenShiftTradeSegments(ctx, r.ID)
+		if err != nil {
+			return err
+		}
+		openText = formatSegmentsJST(open)
+	}
+
+	toRequester, toMembers := reminderMessages(r, reminderStageLabel(r.OffsetMinutes), openText)
+	// bot をブロックした作成者には送らない（他のメンバーへのリマインドは送る）
+	if r.BotReachable {
+		if err := enqueuePush(ctx, q, r.LineUserID, toRequester); err != nil {
+			return err
+		}
+	}
+	return enqueueGroupNotice(ctx, q, r.GroupID, r.LineUserID, toMembers)
+}
+
+// 「48時間前」「90分前」のような段階の表示
+func reminderStageLabel(offsetMinutes int32) string {
+	if offsetMinutes%60 == 0 {
+		return strconv.Itoa(int(offsetMinutes/60)) + "時間前"
+	}
+	return strconv.Itoa(int(offsetMinutes)) + "分前"
+}
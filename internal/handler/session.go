@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	sessionCookieName = "shift_session"
+
+	ctxViewUserID ctxKey = "view_user_id"
+)
+
+var errInvalidSession = errors.New("invalid session")
+
+type sessionPayload struct {
+	UserID string `json:"uid"`
+	Exp    int64  `json:"exp"`
+}
+
+// SessionSigner は HTML 画面用のセッション Cookie を HMAC で署名・検証する
+// Cookie の値は base64url(payload) + "." + base64url(HMAC-SHA256)
+type SessionSigner struct {
+	key    []byte
+	ttl    time.Duration
+	secure bool
+}
+
+func NewSessionSigner(key []byte, ttl time.Duration, secure bool) *SessionSigner {
+	return &SessionSigner{key: key, ttl: ttl, secure: secure}
+}
+
+// 環境変数から SessionSigner を組み立てる
+// SESSION_SECRET は prod では必須。dev で未設定なら起動ごとにランダムな鍵を使う
+// SESSION_TTL（既定 24h）でセッションの有効期間を変えられる
+func NewSessionSignerFromEnv() (*SessionSigner, error) {
+	prod := strings.ToLower(os.Getenv("APP_ENV")) == "prod"
+
+	secret := strings.TrimSpace(os.Getenv("SESSION_SECRET"))
+	var key []byte
+	if secret != "" {
+		key = []byte(secret)
+	} else {
+		if prod {
+			return nil, fmt.Errorf("SESSION_SECRET is not set")
+		}
+		log.Println("[WARN] SESSION_SECRET is not set; using a random key (sessions reset on restart)")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+	}
+
+	ttl := 24 * time.Hour
+	if s := strings.TrimSpace(os.Getenv("SESSION_TTL")); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SESSION_TTL %q", s)
+		}
+		ttl = d
+	}
+
+	return NewSessionSigner(key, ttl, prod), nil
+}
+
+func (s *SessionSigner) sign(data string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Encode はユーザーIDから Cookie の値を作る
+func (s *SessionSigner) Encode(userID uuid.UUID, now time.Time) (string, error) {
+	payload, err := json.Marshal(sessionPayload{
+		UserID: userID.String(),
+		Exp:    now.Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	data := base64.RawURLEncoding.EncodeToString(payload)
+	return data + "." + s.sign(data), nil
+}
+
+// Decode は Cookie の値を検証してユーザーIDを返す
+func (s *SessionSigner) Decode(value string, now time.Time) (uuid.UUID, error) {
+	data, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return uuid.Nil, errInvalidSession
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(data))) {
+		return uuid.Nil, errInvalidSession
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return uuid.Nil, errInvalidSession
+	}
+	var p sessionPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return uuid.Nil, errInvalidSession
+	}
+	if now.Unix() >= p.Exp {
+		return uuid.Nil, errInvalidSession
+	}
+	userID, err := uuid.Parse(p.UserID)
+	if err != nil {
+		return uuid.Nil, errInvalidSession
+	}
+	return userID, nil
+}
+
+// SetCookie はセッション Cookie を発行する
+func (s *SessionSigner) SetCookie(c echo.Context, userID uuid.UUID) error {
+	value, err := s.Encode(userID, time.Now())
+	if err != nil {
+		return err
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// ClearCookie はセッション Cookie を削除する
+func (s *SessionSigner) ClearCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ViewUserID は ViewSessionMiddleware が確定したユーザーIDを返す
+func ViewUserID(c echo.Context) (uuid.UUID, bool) {
+	v, ok := c.Get(string(ctxViewUserID)).(uuid.UUID)
+	return v, ok
+}
+
+// HTML 画面用の認証
+// セッション Cookie から現在のユーザーを確定する。無い・不正な場合は入口画面（LIFF ログイン → /api/me）を表示し、
+// ログイン後に元の URL へ戻す
+func (h *Handler) ViewSessionMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			cookie, err := c.Cookie(sessionCookieName)
+			if err != nil || cookie.Value == "" {
+				return h.showHomeEntryWithNext(c, c.Request().URL.RequestURI())
+			}
+
+			userID, err := h.sessions.Decode(cookie.Value, time.Now())
+			if err != nil {
+				h.sessions.ClearCookie(c)
+				return h.showHomeEntryWithNext(c, c.Request().URL.RequestURI())
+			}
+
+			// 退会済みユーザーのセッションは無効
+			user, err := h.queries.GetUserByID(ctx, userID)
+			if err != nil || user.DeletedAt.Valid {
+				h.sessions.ClearCookie(c)
+				return h.showHomeEntryWithNext(c, c.Request().URL.RequestURI())
+			}
+
+			c.Set(string(ctxViewUserID), userID)
+			return next(c)
+		}
+	}
+}
+
+// ログイン後の遷移先はサイト内パスに限る（オープンリダイレクト防止）
+func safeNextPath(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/home"
+	}
+	if u, err := url.Parse(next); err != nil || u.Host != "" || u.Scheme != "" {
+		return "/home"
+	}
+	return next
+}
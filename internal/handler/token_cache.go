@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// /debug/vars で確認できる検証キャッシュのメトリクス
+var (
+	authCacheHits      = expvar.NewInt("auth_token_cache_hits")
+	authCacheMisses    = expvar.NewInt("auth_token_cache_misses")
+	authCacheEvictions = expvar.NewInt("auth_token_cache_evictions")
+)
+
+const defaultTokenCacheMaxEntries = 10000
+
+type tokenCacheEntry struct {
+	claims    IDTokenClaims
+	expiresAt time.Time
+}
+
+// CachingVerifier は検証済みトークンをプロセス内にキャッシュする TokenVerifier
+// キーはトークンの SHA-256 で、TTL とトークンの exp の早い方まで保持する
+// 1画面で複数 API を呼んでも verify は1回で済む
+type CachingVerifier struct {
+	inner      TokenVerifier
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]tokenCacheEntry
+}
+
+func NewCachingVerifier(inner TokenVerifier, ttl time.Duration) *CachingVerifier {
+	return &CachingVerifier{
+		inner:      inner,
+		ttl:        ttl,
+		maxEntries: defaultTokenCacheMaxEntries,
+		now:        time.Now,
+		entries:    make(map[[sha256.Size]byte]tokenCacheEntry),
+	}
+}
+
+func (v *CachingVerifier) Verify(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	key := sha256.Sum256([]byte(idToken))
+	now := v.now()
+
+	v.mu.Lock()
+	if e, ok := v.entries[key]; ok {
+		if now.Before(e.expiresAt) {
+			v.mu.Unlock()
+			authCacheHits.Add(1)
+			claims := e.claims
+			return &claims, nil
+		}
+		delete(v.entries, key)
+	}
+	v.mu.Unlock()
+
+	authCacheMisses.Add(1)
+	claims, err := v.inner.Verify(ctx, idToken)
+	if err != nil {
+		// 失敗はキャッシュしない
+		return nil, err
+	}
+
+	expiresAt := now.Add(v.ttl)
+	if claims.Exp > 0 {
+		if exp := time.Unix(claims.Exp, 0); exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+	if !now.Before(expiresAt) {
+		return claims, nil
+	}
+
+	v.mu.Lock()
+	if len(v.entries) >= v.maxEntries {
+		v.evictLocked(now)
+	}
+	v.entries[key] = tokenCacheEntry{claims: *claims, expiresAt: expiresAt}
+	v.mu.Unlock()
+
+	return claims, nil
+}
+
+// 期限切れを掃除し、それでも溢れていれば任意のエントリを捨てる
+func (v *CachingVerifier) evictLocked(now time.Time) {
+	for k, e := range v.entries {
+		if !now.Before(e.expiresAt) {
+			delete(v.entries, k)
+			authCacheEvictions.Add(1)
+		}
+	}
+	for k := range v.entries {
+		if len(v.entries) < v.maxEntries {
+			break
+		}
+		delete(v.entries, k)
+		authCacheEvictions.Add(1)
+	}
+}
@@ -0,0 +1,477 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LINE の ID Token 発行者
+const lineIssuer = "https://access.line.me"
+
+const defaultLineAPIBaseURL = "https://api.line.me"
+
+// JWKS の取得1回にかける時間の上限
+const jwksFetchTimeout = 10 * time.Second
+
+// IDTokenClaims は検証済み ID Token のクレーム
+type IDTokenClaims struct {
+	Sub  string `json:"sub"` // LINE userId
+	Aud  string `json:"aud"`
+	Iss  string `json:"iss"`
+	Exp  int64  `json:"exp"`
+	Iat  int64  `json:"iat"`
+	Name string `json:"name,omitempty"`
+}
+
+// TokenVerifier は ID Token を検証してクレームを返す
+type TokenVerifier interface {
+	Verify(ctx context.Context, idToken string) (*IDTokenClaims, error)
+}
+
+// 環境変数から TokenVerifier を組み立てる
+//
+//	AUTH_VERIFIER=jwks（既定）: LINE の公開鍵(JWKS)をキャッシュしてローカルで署名検証する
+//	AUTH_VERIFIER=api        : LINE の verify API を毎回呼ぶ
+//	AUTH_VERIFIER=hs256      : LINE_LOGIN_CHANNEL_SECRET で HS256 を検証する（オフライン検証・テスト用）
+//
+// AUTH_CACHE_TTL（既定 5m、0 で無効）の間、検証結果を CachingVerifier でキャッシュする
+func NewTokenVerifierFromEnv() (TokenVerifier, error) {
+	v, err := newBaseTokenVerifierFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := 5 * time.Minute
+	if s := strings.TrimSpace(os.Getenv("AUTH_CACHE_TTL")); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_CACHE_TTL: %w", err)
+		}
+		ttl = d
+	}
+	if ttl <= 0 {
+		return v, nil
+	}
+	return NewCachingVerifier(v, ttl), nil
+}
+
+func newBaseTokenVerifierFromEnv() (TokenVerifier, error) {
+	channelID := strings.TrimSpace(os.Getenv("LINE_LOGIN_CHANNEL_ID"))
+	if channelID == "" {
+		return nil, fmt.Errorf("LINE_LOGIN_CHANNEL_ID is not set")
+	}
+	baseURL := strings.TrimSpace(os.Getenv("LINE_API_BASE_URL"))
+	if baseURL == "" {
+		baseURL = defaultLineAPIBaseURL
+	}
+	secret := strings.TrimSpace(os.Getenv("LINE_LOGIN_CHANNEL_SECRET"))
+
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("AUTH_VERIFIER"))); mode {
+	case "", "jwks":
+		v := NewJWKSVerifier(strings.TrimSuffix(baseURL, "/")+"/oauth2/v2.1/certs", channelID)
+		if secret != "" {
+			v.HMACSecret = []byte(secret)
+		}
+		return v, nil
+	case "api":
+		return NewLineAPIVerifier(baseURL, channelID), nil
+	case "hs256":
+		if secret == "" {
+			return nil, fmt.Errorf("LINE_LOGIN_CHANNEL_SECRET is required for AUTH_VERIFIER=hs256")
+		}
+		return NewHS256Verifier([]byte(secret), channelID), nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_VERIFIER %q", mode)
+	}
+}
+
+// ---- LINE verify API ----
+
+// LineAPIVerifier は LINE の verify API (POST /oauth2/v2.1/verify) で検証する
+type LineAPIVerifier struct {
+	BaseURL   string
+	ChannelID string
+	Client    *http.Client
+}
+
+func NewLineAPIVerifier(baseURL, channelID string) *LineAPIVerifier {
+	return &LineAPIVerifier{
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		ChannelID: channelID,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *LineAPIVerifier) Verify(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	if idToken == "" {
+		return nil, fmt.Errorf("id_token is required")
+	}
+
+	form := url.Values{}
+	form.Set("id_token", idToken)
+	form.Set("client_id", v.ChannelID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.BaseURL+"/oauth2/v2.1/verify", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build verify request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call verify api: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		// body に LINE からのエラーJSONが入ることが多い
+		return nil, fmt.Errorf("verify failed: status=%d body=%s", res.StatusCode, string(body))
+	}
+
+	var claims IDTokenClaims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse verify response: %w", err)
+	}
+	if claims.Sub == "" {
+		return nil, fmt.Errorf("verify response missing sub")
+	}
+	return &claims, nil
+}
+
+// ---- JWKS (ES256) ----
+
+// JWKSVerifier は LINE の公開鍵(JWKS)で ES256 署名をローカル検証する
+// 鍵は CacheTTL の間キャッシュし、未知の kid が来たときだけ取り直す
+// 取り直しは MinRefetchInterval に1回まで（でたらめな kid で LINE へのリクエストを増やされないように）
+type JWKSVerifier struct {
+	JWKSURL            string
+	ChannelID          string
+	Client             *http.Client
+	CacheTTL           time.Duration
+	MinRefetchInterval time.Duration
+	HMACSecret         []byte // セットされていれば HS256 の ID Token も受け付ける
+
+	mu          sync.Mutex
+	keys        map[string]*ecdsa.PublicKey
+	fetchedAt   time.Time     // 最後に取得に成功した時刻
+	attemptedAt time.Time     // 最後に取得を試みた時刻（失敗も含む）
+	fetchErr    error         // 最後の取得のエラー
+	fetching    chan struct{} // 取得中なら、終わったときに close される
+}
+
+func NewJWKSVerifier(jwksURL, channelID string) *JWKSVerifier {
+	return &JWKSVerifier{
+		JWKSURL:   jwksURL,
+		ChannelID: channelID,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		CacheTTL:  time.Hour,
+
+		MinRefetchInterval: time.Minute,
+	}
+}
+
+func (v *JWKSVerifier) Verify(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	tok, err := parseJWT(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.header.Alg {
+	case "ES256":
+		key, err := v.key(ctx, tok.header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyES256(key, tok.signingInput, tok.signature); err != nil {
+			return nil, err
+		}
+	case "HS256":
+		if len(v.HMACSecret) == 0 {
+			return nil, fmt.Errorf("HS256 id_token is not accepted")
+		}
+		if err := verifyHS256(v.HMACSecret, tok.signingInput, tok.signature); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", tok.header.Alg)
+	}
+
+	if err := validateClaims(&tok.claims, v.ChannelID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &tok.claims, nil
+}
+
+// kid に対応する公開鍵を返す（必要なら JWKS を取り直す）
+// 取得中は v.mu を持たないので、キャッシュにある鍵での検証は取得を待たない
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	for {
+		v.mu.Lock()
+		k, ok := v.keys[kid]
+		if ok && time.Since(v.fetchedAt) < v.CacheTTL {
+			v.mu.Unlock()
+			return k, nil
+		}
+
+		// ほかのリクエストが取得中なら、その結果を待つ
+		if wait := v.fetching; wait != nil {
+			v.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		// 直前に取り直したばかりなら取り直さない
+		// 取得に失敗していても、キャッシュに鍵があればそれで検証を続ける
+		if time.Since(v.attemptedAt) < v.MinRefetchInterval {
+			err := v.fetchErr
+			v.mu.Unlock()
+			return keyOrError(k, ok, kid, err)
+		}
+
+		wait := make(chan struct{})
+		v.fetching = wait
+		prevAttemptedAt := v.attemptedAt
+		v.attemptedAt = time.Now()
+		v.mu.Unlock()
+
+		// 取得の結果はほかのリクエストとも共有するので、呼び出し元の ctx（切断・タイムアウト）には引きずられないようにする
+		fetchCtx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		keys, err := v.fetch(fetchCtx)
+		cancel()
+
+		v.mu.Lock()
+		switch {
+		case err == nil:
+			v.keys = keys
+			v.fetchedAt = time.Now()
+			v.fetchErr = nil
+		case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+			// タイムアウトは LINE 側の一時的な問題かもしれないので覚えておかず、次のリクエストで取り直す
+			v.attemptedAt = prevAttemptedAt
+		default:
+			v.fetchErr = err
+		}
+		v.fetching = nil
+		close(wait)
+		k, ok = v.keys[kid]
+		v.mu.Unlock()
+		return keyOrError(k, ok, kid, err)
+	}
+}
+
+func keyOrError(k *ecdsa.PublicKey, ok bool, kid string, fetchErr error) (*ecdsa.PublicKey, error) {
+	if ok {
+		return k, nil
+	}
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwks request: %w", err)
+	}
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks failed: status=%d", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "EC" || k.Crv != "P-256" {
+			continue
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			continue
+		}
+		keys[k.Kid] = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+	}
+	return keys, nil
+}
+
+// ---- HS256 ----
+
+// HS256Verifier は共有シークレットで HS256 署名を検証する
+// LINE Login の HS256 トークン（チャネルシークレットで署名）の検証や、
+// MintHS256Token と組み合わせたオフラインのテストに使う
+type HS256Verifier struct {
+	Secret    []byte
+	ChannelID string
+}
+
+func NewHS256Verifier(secret []byte, channelID string) *HS256Verifier {
+	return &HS256Verifier{Secret: secret, ChannelID: channelID}
+}
+
+func (v *HS256Verifier) Verify(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	tok, err := parseJWT(idToken)
+	if err != nil {
+		return nil, err
+	}
+	if tok.header.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported alg %q", tok.header.Alg)
+	}
+	if err := verifyHS256(v.Secret, tok.signingInput, tok.signature); err != nil {
+		return nil, err
+	}
+	if err := validateClaims(&tok.claims, v.ChannelID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &tok.claims, nil
+}
+
+// MintHS256Token は HS256Verifier で検証できる ID Token を発行する
+// Iss が空なら LINE の発行者を入れる
+func MintHS256Token(secret []byte, claims IDTokenClaims) (string, error) {
+	if claims.Iss == "" {
+		claims.Iss = lineIssuer
+	}
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// ---- 共通処理 ----
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type parsedJWT struct {
+	header       jwtHeader
+	claims       IDTokenClaims
+	signingInput string
+	signature    []byte
+}
+
+func parseJWT(token string) (*parsedJWT, error) {
+	if token == "" {
+		return nil, fmt.Errorf("id_token is required")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id_token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token header: %w", err)
+	}
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token payload: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token signature: %w", err)
+	}
+
+	var tok parsedJWT
+	if err := json.Unmarshal(headerJSON, &tok.header); err != nil {
+		return nil, fmt.Errorf("malformed id_token header: %w", err)
+	}
+	if err := json.Unmarshal(payloadJSON, &tok.claims); err != nil {
+		return nil, fmt.Errorf("malformed id_token payload: %w", err)
+	}
+	tok.signingInput = parts[0] + "." + parts[1]
+	tok.signature = sig
+	return &tok, nil
+}
+
+func verifyES256(key *ecdsa.PublicKey, signingInput string, sig []byte) error {
+	// JWS の ES256 署名は r||s の 64 バイト固定長
+	if len(sig) != 64 {
+		return fmt.Errorf("invalid ES256 signature length")
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func verifyHS256(secret []byte, signingInput string, sig []byte) error {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// verify API と同じ観点（発行者・宛先・有効期限）でクレームを確認する
+func validateClaims(claims *IDTokenClaims, channelID string, now time.Time) error {
+	if claims.Iss != lineIssuer {
+		return fmt.Errorf("unexpected iss %q", claims.Iss)
+	}
+	if claims.Aud != channelID {
+		return fmt.Errorf("unexpected aud %q", claims.Aud)
+	}
+	if claims.Exp == 0 || now.Unix() >= claims.Exp {
+		return fmt.Errorf("id_token expired")
+	}
+	if claims.Sub == "" {
+		return fmt.Errorf("id_token missing sub")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// kid の公開鍵だけを返す JWKS サーバー
+func newTestJWKSServer(t *testing.T, kid string, key *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": kid,
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// ES256 の ID Token を発行する
+func mintES256Token(t *testing.T, key *ecdsa.PrivateKey, kid string, claims IDTokenClaims) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWKSVerifierES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestJWKSServer(t, "k1", key)
+
+	valid := IDTokenClaims{
+		Sub: "U0123",
+		Aud: "channel",
+		Iss: lineIssuer,
+		Exp: time.Now().Add(time.Hour).Unix(),
+		Iat: time.Now().Unix(),
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		v := NewJWKSVerifier(srv.URL, "channel")
+		claims, err := v.Verify(context.Background(), mintES256Token(t, key, "k1", valid))
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if claims.Sub != "U0123" {
+			t.Errorf("sub = %q, want U0123", claims.Sub)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		kid     string
+		mutate  func(c *IDTokenClaims)
+		wantErr string
+	}{
+		{name: "unknown kid", kid: "other", wantErr: "unknown kid"},
+		{name: "wrong aud", kid: "k1", mutate: func(c *IDTokenClaims) { c.Aud = "another-channel" }, wantErr: "unexpected aud"},
+		{name: "wrong iss", kid: "k1", mutate: func(c *IDTokenClaims) { c.Iss = "https://example.com" }, wantErr: "unexpected iss"},
+		{name: "expired", kid: "k1", mutate: func(c *IDTokenClaims) { c.Exp = time.Now().Add(-time.Minute).Unix() }, wantErr: "expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid
+			if tt.mutate != nil {
+				tt.mutate(&claims)
+			}
+			v := NewJWKSVerifier(srv.URL, "channel")
+			_, err := v.Verify(context.Background(), mintES256Token(t, key, tt.kid, claims))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 取得を始めたリクエストが切断されても、取得は続き、その結果をほかのリクエストも使える
+func TestJWKSVerifierFetchIgnoresCallerCancel(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestJWKSServer(t, "k1", key)
+
+	v := NewJWKSVerifier(srv.URL, "channel")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.key(ctx, "k1"); err != nil {
+		t.Fatalf("key with a cancelled ctx: %v", err)
+	}
+	if _, err := v.key(context.Background(), "k1"); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+}
+
+// でたらめな kid が続いても、JWKS の取り直しは MinRefetchInterval に1回まで
+func TestJWKSVerifierUnknownKidRefetchLimit(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer srv.Close()
+
+	v := NewJWKSVerifier(srv.URL, "channel")
+	for _, kid := range []string{"a", "b", "c"} {
+		if _, err := v.key(context.Background(), kid); err == nil {
+			t.Fatalf("key(%q) should fail for an unknown kid", kid)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("jwks fetched %d times, want 1", n)
+	}
+
+	v.MinRefetchInterval = 0
+	if _, err := v.key(context.Background(), "d"); err == nil {
+		t.Fatal("key(\"d\") should fail for an unknown kid")
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("jwks fetched %d times, want 2", n)
+	}
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/line/line-bot-sdk-go/v7/linebot"
 )
 
 // JST 表示用（DBはUTC保存のままでOK）
@@ -108,15 +107,38 @@ func (h *Handler) CreateTrade(c echo.Context) error {
 	}
 
 	type Request struct {
-		StartAt time.Time `json:"start_at"`
-		EndAt   time.Time `json:"end_at"`
-		Bounty  string    `json:"bounty"`
+		StartAt        time.Time           `json:"start_at"`
+		EndAt          time.Time           `json:"end_at"`
+		Bounty         string              `json:"bounty"`
+		TradeType      string              `json:"trade_type"`      // 省略時は GIVEAWAY
+		CounterShifts  []counterShiftInput `json:"counter_shifts"`  // SWAP のとき引き受け者が差し出すシフト
+		SegmentMinutes int32               `json:"segment_minutes"` // 分割募集の1枠の長さ（分）。0 なら分割しない
 	}
 	var req Request
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
 	}
 
+	now := time.Now()
+	if err := validateShiftRange(req.StartAt, req.EndAt, now); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	tradeType := TradeType(req.TradeType)
+	if tradeType == "" {
+		tradeType = TradeTypeGiveaway
+	}
+	if err := validateCounterShifts(tradeType, req.CounterShifts, now); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	segments, err := buildSegments(req.StartAt, req.EndAt, req.SegmentMinutes)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if len(segments) > 0 && tradeType == TradeTypeSwap {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "SWAP trades cannot be split"})
+	}
+
 	userUUID, err := h.userUUIDFromAuth(c)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -137,63 +159,114 @@ func (h *Handler) CreateTrade(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// シフト交換リクエストの作成
-	trade, err := h.queries.CreateShiftTrade(ctx, database.CreateShiftTradeParams{
+	// シフト交換リクエストの作成と履歴の記録を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	trade, err := qtx.CreateShiftTrade(ctx, database.CreateShiftTradeParams{
 		GroupID:           groupID,
 		RequesterID:       userUUID,
 		ShiftStartAt:      req.StartAt,
 		ShiftEndAt:        req.EndAt,
 		BountyDescription: req.Bounty,
+		TradeType:         string(tradeType),
+		SegmentMinutes:    req.SegmentMinutes,
 	})
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create trade: " + err.Error()})
 	}
 
-	// bot でシフト交換リクエストの作成を通知する（devバイパス時は送らない）
-	if isDevBypassRequest(c) {
-		c.Logger().Info("[notify] skip multicast in dev-bypass request")
-		return c.JSON(http.StatusOK, trade)
-	}
-	go func() {
-
-		ctx := context.Background()
-
-		lineIDs, err := h.queries.GetGroupMemberLineIDs(ctx, groupID)
+	counterShifts := make([]database.ShiftTradeCounterShift, 0, len(req.CounterShifts))
+	for _, cs := range req.CounterShifts {
+		created, err := qtx.CreateCounterShift(ctx, database.CreateCounterShiftParams{
+			TradeID:      trade.ID,
+			ShiftStartAt: cs.StartAt,
+			ShiftEndAt:   cs.EndAt,
+		})
 		if err != nil {
-			c.Logger().Error("Failed to get member line IDs:", err)
-			return
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create counter shift: " + err.Error()})
 		}
+		counterShifts = append(counterShifts, created)
+	}
 
-		var to []string
-		for _, id := range lineIDs {
-			to = append(to, id)
-		}
-		to, skipped := filterValidLineUserIDs(to)
-		if skipped > 0 {
-			c.Logger().Warnf("[notify] multicast: skipped %d invalid line_user_id(s)", skipped)
+	for _, seg := range segments {
+		if _, err := qtx.CreateShiftTradeSegment(ctx, database.CreateShiftTradeSegmentParams{
+			TradeID:        trade.ID,
+			SegmentStartAt: seg.StartAt,
+			SegmentEndAt:   seg.EndAt,
+		}); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create segment: " + err.Error()})
 		}
+	}
+
+	if err := recordTradeTransition(ctx, qtx, trade.ID, "", TradeStatusOpen, actorUUID(userUUID), tradeReasonCreated); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+	}
 
-		if len(to) > 0 {
-			// bot から送信されるメッセージ
-			msg := "📢 新しいシフト募集があります！\n\n" +
-				"グループ: " + groupName + "\n\n" +
-				"日時: " + formatShiftRangeJST(req.StartAt, req.EndAt) + "\n" +
-				"謝礼: " + req.Bounty + "\n\n" +
-				"アプリから確認してください！"
-
-			// Multicast で一斉送信
-			if _, err := h.bot.Multicast(to, linebot.NewTextMessage(msg)).Do(); err != nil {
-				c.Logger().Error("Failed to send multicast:", err)
-			}
+	// bot でシフト交換リクエストの作成を通知する（devバイパス時は送らない）
+	if shouldNotify(c) {
+		msg := newTradeMessage(ctx, qtx, trade, groupName, counterShifts, len(segments))
+		if err := enqueueGroupNotice(ctx, qtx, groupID, "", msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
 		}
+	}
 
-	}()
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
 
 	return c.JSON(http.StatusOK, trade)
 
 }
 
 // 募集中のシフト交代リクエストを一覧取得
+// ボード・一覧API用: 募集中の募集に交換の差し出しシフト・分割募集の枠を添えたもの
+type openTradeView struct {
+	database.ListOpenShiftTradesRow
+	CounterShifts []database.ShiftTradeCounterShift       `json:"counter_shifts"`
+	Segments      []database.ListSegmentsForOpenTradesRow `json:"segments"`
+}
+
+// グループの募集中一覧を差し出しシフト・枠付きで取得する
+func (h *Handler) listOpenTradeViews(ctx context.Context, groupID uuid.UUID) ([]openTradeView, error) {
+	trades, err := h.queries.ListOpenShiftTrades(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	shifts, err := h.queries.ListCounterShiftsForOpenTrades(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := h.queries.ListSegmentsForOpenTrades(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	shiftsByTrade := make(map[uuid.UUID][]database.ShiftTradeCounterShift)
+	for _, s := range shifts {
+		shiftsByTrade[s.TradeID] = append(shiftsByTrade[s.TradeID], s)
+	}
+	segmentsByTrade := make(map[uuid.UUID][]database.ListSegmentsForOpenTradesRow)
+	for _, s := range segments {
+		segmentsByTrade[s.TradeID] = append(segmentsByTrade[s.TradeID], s)
+	}
+
+	views := make([]openTradeView, 0, len(trades))
+	for _, t := range trades {
+		views = append(views, openTradeView{
+			ListOpenShiftTradesRow: t,
+			CounterShifts:          shiftsByTrade[t.ID],
+			Segments:               segmentsByTrade[t.ID],
+		})
+	}
+	return views, nil
+}
+
 func (h *Handler) ListTrades(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -221,7 +294,7 @@ func (h *Handler) ListTrades(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	trades, err := h.queries.ListOpenShiftTrades(ctx, groupID)
+	trades, err := h.listOpenTradeViews(ctx, groupID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -229,6 +302,7 @@ func (h *Handler) ListTrades(c echo.Context) error {
 }
 
 // 状態が OPEN のシフト交代リクエストの削除
+// 行は消さずに CLOSED にし、履歴（shift_trade_events）を残す
 func (h *Handler) DeleteTrade(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -245,19 +319,101 @@ func (h *Handler) DeleteTrade(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	count, err := h.queries.DeleteShiftTrade(ctx, database.DeleteShiftTradeParams{
-		ID:          tradeID,
-		RequesterID: userUUID,
-	})
+	closed, err := h.closeOwnOpenTrade(ctx, tradeID, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if closed {
+		return c.JSON(http.StatusOK, map[string]string{"message": "Trade deleted successfully"})
+	}
+
+	// 作成者でなくても、グループの ADMIN は募集中の募集を削除できる
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	isAdmin, err := h.isGroupAdmin(ctx, groupID, userUUID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	if count == 0 {
+	if !isAdmin {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Cannot delete trade. Either it does not exist, it's not yours, or it's already filled."})
 	}
+
+	// 取り消し・履歴・作成者への通知を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	deleted, err := qtx.CloseOpenShiftTradeInGroup(ctx, database.CloseOpenShiftTradeInGroupParams{
+		ID:      tradeID,
+		GroupID: groupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Cannot delete trade. Either it does not exist or it's already filled."})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 管理者が他人の募集を取り消したことを監査できるよう、操作した管理者を履歴に残す
+	if err := recordTradeTransition(ctx, qtx, deleted.ID, TradeStatusOpen, TradeStatusClosed, actorUUID(userUUID), tradeReasonDeleted); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+	}
+	if _, err := qtx.CancelPendingTradeApplications(ctx, deleted.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 作成者に管理者が削除したことを知らせる（devバイパス時は送らない）
+	if shouldNotify(c) {
+		msg := "🗑 シフト募集がグループ管理者によって削除されました\n\n" +
+			"日時: " + formatShiftRangeJST(deleted.ShiftStartAt, deleted.ShiftEndAt)
+		if err := enqueueUserText(ctx, qtx, deleted.RequesterID, msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"message": "Trade deleted successfully"})
 }
 
+// 作成者が自分の OPEN の募集を取り消す（OPEN → CLOSED、応募中の応募も取り消す）
+// 自分の OPEN の募集でなければ closed=false を返す
+func (h *Handler) closeOwnOpenTrade(ctx context.Context, tradeID, requesterID uuid.UUID) (closed bool, err error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	trade, err := qtx.CloseOwnOpenShiftTrade(ctx, database.CloseOwnOpenShiftTradeParams{
+		ID:          tradeID,
+		RequesterID: requesterID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := recordTradeTransition(ctx, qtx, trade.ID, TradeStatusOpen, TradeStatusClosed, actorUUID(requesterID), tradeReasonDeleted); err != nil {
+		return false, err
+	}
+	if _, err := qtx.CancelPendingTradeApplications(ctx, trade.ID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
 // シフト交代リクエストの応募
 func (h *Handler) AcceptTrade(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -290,68 +446,58 @@ func (h *Handler) AcceptTrade(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	trade, err := h.queries.AcceptShiftTrade(ctx, database.AcceptShiftTradeParams{
-		AcceptorID: uuid.NullUUID{UUID: acceptorUUID, Valid: true},
-		ID:         tradeID,
-		GroupID:    groupID,
-	})
+	type Request struct {
+		SegmentIDs []uuid.UUID `json:"segment_ids"` // 分割募集で引き受ける枠（省略時は残り全て）
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	// 分割募集は枠ごとに早い者勝ちで引き受ける（承認制グループでも同じ）
+	target, err := h.queries.GetTradeByID(ctx, tradeID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusConflict, map[string]string{
-				"error": "Cannot accept trade. Possible reasons: trade not found, already filled, it's your own request, or you are not a member.",
-			})
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
 		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	if target.GroupID != groupID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
+	}
+	if target.SegmentMinutes > 0 {
+		return h.acceptTradeSegments(c, target, acceptorUUID, req.SegmentIDs)
+	}
 
-	// 通知（devバイパス時は送らない）
-	if isDevBypassRequest(c) {
-		c.Logger().Info("[notify] skip push in dev-bypass request")
-		return c.JSON(http.StatusOK, trade)
-	}
-	go func() {
-		ctx := context.Background()
-
-		var acceptorName string
-		var acceptorLineID string
-
-		if trade.AcceptorID.Valid {
-			acceptor, err := h.queries.GetUserByID(ctx, trade.AcceptorID.UUID)
-			if err == nil {
-				acceptorName = acceptor.DisplayName
-				if isValidLineUserID(acceptor.LineUserID) {
-					acceptorLineID = acceptor.LineUserID
-				}
-			} else {
-				acceptorName = "メンバー"
-			}
-		}
-
-		requester, err := h.queries.GetUserByID(ctx, trade.RequesterID)
-		if err == nil && isValidLineUserID(requester.LineUserID) {
-			// メッセージに相手の名前を入れる
-			msg := "🎉 シフトが成立しました！\n\n" +
-				"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
-				"相手: " + acceptorName + " さん\n\n" +
-				"あなたのシフト募集が引き受けられました。\n" +
-				"引き継ぎや業務内容など、詳細を追記するとスムーズです。\n" +
-				"（詳細ページから追記できます）"
-
-			if _, err := h.bot.PushMessage(requester.LineUserID, linebot.NewTextMessage(msg)).Do(); err != nil {
-				c.Logger().Error("Failed to push to requester:", err)
-			}
+	// 承認制グループでは応募として受け付ける（成立は作成者・管理者の承認時）
+	group, err := h.queries.GetJobGroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Group not found"})
 		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if AcceptMode(group.AcceptMode) == AcceptModeApproval {
+		return h.applyForTrade(c, target, acceptorUUID)
+	}
 
-		if isValidLineUserID(acceptorLineID) {
-			msg := "👍 シフトを引き受けました！\n\n" +
-				"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
-				"当日よろしくおねがいします！"
-
-			if _, err := h.bot.PushMessage(acceptorLineID, linebot.NewTextMessage(msg)).Do(); err != nil {
-				c.Logger().Error("Failed to push to acceptor:", err)
-			}
+	// 通知（devバイパス時は送らない）
+	trade, err := h.acceptOpenTrade(ctx, target, acceptorUUID, shouldNotify(c))
+	if err != nil {
+		var conflictErr *shiftConflictError
+		var requesterConflictErr *swapRequesterConflictError
+		switch {
+		case errors.As(err, &conflictErr):
+			return respondShiftConflicts(c, conflictErr.conflicts)
+		case errors.As(err, &requesterConflictErr):
+			return respondSwapRequesterConflicts(c, requesterConflictErr.conflicts)
+		case errors.Is(err, errTradeUnavailable):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Cannot accept trade. Possible reasons: trade not found, already filled, it's your own request, or you are not a member.",
+			})
 		}
-	}()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
 
 	return c.JSON(http.StatusOK, trade)
 }
@@ -373,36 +519,74 @@ func (h *Handler) MarkPaid(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	trade, err := h.queries.MarkTradeAsPaid(ctx, database.MarkTradeAsPaidParams{
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	// FILLED → COMPLETED（作成者のみ。MarkTradeAsPaid の WHERE で作成者本人を保証）
+	trade, err := qtx.MarkTradeAsPaid(ctx, database.MarkTradeAsPaidParams{
 		ID:          tradeID,
 		RequesterID: userUUID,
 	})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Cannot mark as paid. Either it does not exist, it's not yours, it's not filled, or it's a swap reciprocal."})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update payment status"})
 	}
 
+	if err := recordTradeTransition(ctx, qtx, trade.ID, TradeStatusFilled, TradeStatusCompleted, actorUUID(userUUID), tradeReasonPaid); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+	}
+
 	// 支払い通知の送信（devバイパス時は送らない）
-	if isDevBypassRequest(c) {
-		c.Logger().Info("[notify] skip paid notification in dev-bypass request")
-		return c.JSON(http.StatusOK, trade)
-	}
-	go func() {
-		if trade.AcceptorID.Valid {
-			acceptor, err := h.queries.GetUserByID(context.Background(), trade.AcceptorID.UUID)
-			if err == nil && isValidLineUserID(acceptor.LineUserID) {
-				requester, _ := h.queries.GetUserByID(context.Background(), trade.RequesterID)
-				requesterName := requester.DisplayName
-
-				msg := "💰 謝礼の支払いが記録されました！\n\n" +
-					"支払者: " + requesterName + "\n" +
-					"日時: " + formatDateJST(trade.ShiftStartAt) + " のシフト\n\n" +
-					"手渡し、または送金アプリ等で着金を確認してください。"
-
-				h.bot.PushMessage(acceptor.LineUserID, linebot.NewTextMessage(msg)).Do()
-			}
+	if shouldNotify(c) && trade.AcceptorID.Valid {
+		requester, err := qtx.GetUserByID(ctx, trade.RequesterID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
-	}()
+
+		msg := "💰 謝礼の支払いが記録されました！\n\n" +
+			"支払者: " + requester.DisplayName + "\n" +
+			"日時: " + formatDateJST(trade.ShiftStartAt) + " のシフト\n\n" +
+			"手渡し、または送金アプリ等で着金を確認してください。"
+		if err := enqueueUserText(ctx, qtx, trade.AcceptorID.UUID, msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
 
 	return c.JSON(http.StatusOK, trade)
 
 }
+
+// 成立した募集の作成者と引き受け者に知らせる（成立と同じトランザクションの q を渡す）
+// reciprocals は交換募集の「お返し」の募集（交換でなければ空）
+func notifyTradeAccepted(ctx context.Context, q *database.Queries, trade database.ShiftTrade, reciprocals []database.ShiftTrade) error {
+	acceptorName := "メンバー"
+	if trade.AcceptorID.Valid {
+		if acceptor, err := q.GetUserByID(ctx, trade.AcceptorID.UUID); err == nil {
+			acceptorName = acceptor.DisplayName
+		}
+	}
+
+	// メッセージに相手の名前を入れる
+	toRequester, toAcceptor := tradeAcceptedMessages(trade, acceptorName, reciprocals)
+	if err := enqueueUserMessages(ctx, q, trade.RequesterID, toRequester); err != nil {
+		return err
+	}
+	if err := enqueueChatFilled(ctx, q, trade, acceptorName); err != nil {
+		return err
+	}
+	if !trade.AcceptorID.Valid {
+		return nil
+	}
+	return enqueueUserMessages(ctx, q, trade.AcceptorID.UUID, toAcceptor)
+}
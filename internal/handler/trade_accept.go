@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shift-change-app/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 引き受け・応募できなかった理由（画面の API と LINE の postback で共通）
+var (
+	errTradeUnavailable = errors.New("trade is no longer open")
+	errOwnTrade         = errors.New("cannot accept your own request")
+	errAlreadyApplied   = errors.New("already applied")
+)
+
+// すでに引き受けているシフトと重なる
+type shiftConflictError struct {
+	conflicts []database.ListAcceptorConflictsRow
+}
+
+func (e *shiftConflictError) Error() string {
+	return "the shift overlaps with shifts already accepted"
+}
+
+// 早い者勝ちのグループで募集を引き受ける（OPEN → FILLED）
+// 交換募集なら「お返し」の募集も作り、notify なら成立の通知も同じトランザクションで積む
+func (h *Handler) acceptOpenTrade(ctx context.Context, target database.ShiftTrade, acceptorID uuid.UUID, notify bool) (database.ShiftTrade, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.ShiftTrade{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	// 他グループも含め、すでに引き受けているシフトと重なるなら引き受けられない
+	if err := lockTradeParties(ctx, qtx, target, acceptorID); err != nil {
+		return database.ShiftTrade{}, err
+	}
+	conflicts, err := findAcceptorConflicts(ctx, qtx, acceptorID, target.ID, target.ShiftStartAt, target.ShiftEndAt)
+	if err != nil {
+		return database.ShiftTrade{}, err
+	}
+	if len(conflicts) > 0 {
+		return database.ShiftTrade{}, &shiftConflictError{conflicts: conflicts}
+	}
+	// 交換募集なら、作成者が受け取る差し出しシフトも作成者の予定と重なってはいけない
+	requesterConflicts, err := findSwapRequesterConflicts(ctx, qtx, target)
+	if err != nil {
+		return database.ShiftTrade{}, err
+	}
+	if len(requesterConflicts) > 0 {
+		return database.ShiftTrade{}, &swapRequesterConflictError{conflicts: requesterConflicts}
+	}
+
+	// OPEN → FILLED（AcceptShiftTrade の WHERE で OPEN・本人以外・所属を保証）
+	trade, err := qtx.AcceptShiftTrade(ctx, database.AcceptShiftTradeParams{
+		AcceptorID: uuid.NullUUID{UUID: acceptorID, Valid: true},
+		ID:         target.ID,
+		GroupID:    target.GroupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.ShiftTrade{}, errTradeUnavailable
+		}
+		return database.ShiftTrade{}, err
+	}
+
+	if err := recordTradeTransition(ctx, qtx, trade.ID, TradeStatusOpen, TradeStatusFilled, actorUUID(acceptorID), tradeReasonAccepted); err != nil {
+		return database.ShiftTrade{}, err
+	}
+
+	// 交換募集なら「お返し」の成立済み募集も同じトランザクションで作る
+	reciprocals, err := createSwapReciprocalTrades(ctx, qtx, trade, acceptorID)
+	if err != nil {
+		return database.ShiftTrade{}, err
+	}
+
+	if notify {
+		if err := notifyTradeAccepted(ctx, qtx, trade, reciprocals); err != nil {
+			return database.ShiftTrade{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.ShiftTrade{}, err
+	}
+	return trade, nil
+}
+
+// 承認制のグループで募集に応募する（成立は作成者・管理者の承認時）
+// notify なら作成者への通知も同じトランザクションで積む
+func (h *Handler) applyToTrade(ctx context.Context, trade database.ShiftTrade, applicantID uuid.UUID, notify bool) (database.TradeApplication, error) {
+	// 始まったシフトは一覧に出ないが、古い通知のボタンなどから応募されることがある
+	if TradeStatus(trade.Status) != TradeStatusOpen || !trade.ShiftStartAt.After(time.Now()) {
+		return database.TradeApplication{}, errTradeUnavailable
+	}
+	if trade.RequesterID == applicantID {
+		return database.TradeApplication{}, errOwnTrade
+	}
+
+	// 応募と作成者への通知を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.TradeApplication{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	// すでに引き受けているシフトと重なるなら応募できない
+	if err := lockAcceptor(ctx, qtx, applicantID); err != nil {
+		return database.TradeApplication{}, err
+	}
+	conflicts, err := findAcceptorConflicts(ctx, qtx, applicantID, trade.ID, trade.ShiftStartAt, trade.ShiftEndAt)
+	if err != nil {
+		return database.TradeApplication{}, err
+	}
+	if len(conflicts) > 0 {
+		return database.TradeApplication{}, &shiftConflictError{conflicts: conflicts}
+	}
+
+	app, err := qtx.CreateTradeApplication(ctx, database.CreateTradeApplicationParams{
+		TradeID:     trade.ID,
+		ApplicantID: applicantID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.TradeApplication{}, errAlreadyApplied
+		}
+		return database.TradeApplication{}, err
+	}
+
+	if notify {
+		applicantName := "メンバー"
+		if applicant, err := qtx.GetUserByID(ctx, applicantID); err == nil {
+			applicantName = applicant.DisplayName
+		}
+		msg := "📝 シフトへの応募がありました\n\n" +
+			"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
+			"応募者: " + applicantName + " さん\n\n" +
+			"詳細ページから応募者を選んで承認してください。"
+		if err := enqueueUserText(ctx, qtx, trade.RequesterID, msg); err != nil {
+			return database.TradeApplication{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.TradeApplication{}, err
+	}
+	return app, nil
+}
@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/outbox"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// ApplicationStatus は応募の状態（trade_applications.status）
+type ApplicationStatus string
+
+const (
+	ApplicationStatusPending   ApplicationStatus = "PENDING"   // 応募中
+	ApplicationStatusApproved  ApplicationStatus = "APPROVED"  // 承認（シフト成立）
+	ApplicationStatusRejected  ApplicationStatus = "REJECTED"  // 却下（他の人に決まった場合も含む）
+	ApplicationStatusWithdrawn ApplicationStatus = "WITHDRAWN" // 応募者が取り下げ
+	ApplicationStatusCancelled ApplicationStatus = "CANCELLED" // 成立後に引き受けが取り消された・募集が削除された
+)
+
+// 応募を管理できる主体（作成者 or グループ管理者）を返す。どちらでもなければ ok=false
+func (h *Handler) applicationManagerActor(ctx context.Context, trade database.ShiftTrade, userID uuid.UUID) (TradeActor, bool, error) {
+	if trade.RequesterID == userID {
+		return TradeActorRequester, true, nil
+	}
+	gm, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: trade.GroupID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if GroupRole(gm.Role) == GroupRoleAdmin {
+		return TradeActorAdmin, true, nil
+	}
+	return "", false, nil
+}
+
+// 応募系APIの共通前処理: パラメータ・ログインユーザー・所属・trade の取得
+// ok=false のときはエラーレスポンスを書き込み済み
+func (h *Handler) loadTradeForApplication(c echo.Context) (database.ShiftTrade, uuid.UUID, bool) {
+	ctx := c.Request().Context()
+
+	fail := func(status int, msg string) (database.ShiftTrade, uuid.UUID, bool) {
+		_ = c.JSON(status, map[string]string{"error": msg})
+		return database.ShiftTrade{}, uuid.Nil, false
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return fail(http.StatusBadRequest, "Invalid group_id")
+	}
+	tradeID, err := uuid.Parse(c.Param("trade_id"))
+	if err != nil {
+		return fail(http.StatusBadRequest, "Invalid trade_id")
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fail(http.StatusNotFound, "User not registered")
+		}
+		return fail(http.StatusUnauthorized, "unauthorized")
+	}
+
+	isMember, err := h.isGroupMember(ctx, groupID, userUUID)
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	if !isMember {
+		return fail(http.StatusForbidden, "You are not a member of this group")
+	}
+
+	trade, err := h.queries.GetTradeByID(ctx, tradeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fail(http.StatusNotFound, "Trade not found")
+		}
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	if trade.GroupID != groupID {
+		return fail(http.StatusNotFound, "Trade not found")
+	}
+
+	return trade, userUUID, true
+}
+
+// 承認制グループでの応募（AcceptTrade から呼ばれる）
+func (h *Handler) applyForTrade(c echo.Context, trade database.ShiftTrade, applicantUUID uuid.UUID) error {
+	// 通知（devバイパス時は送らない）
+	app, err := h.applyToTrade(c.Request().Context(), trade, applicantUUID, shouldNotify(c))
+	if err != nil {
+		var conflictErr *shiftConflictError
+		switch {
+		case errors.As(err, &conflictErr):
+			return respondShiftConflicts(c, conflictErr.conflicts)
+		case errors.Is(err, errTradeUnavailable):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "This trade is no longer open"})
+		case errors.Is(err, errOwnTrade):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "You cannot apply to your own request"})
+		case errors.Is(err, errAlreadyApplied):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "You have already applied"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, app)
+}
+
+// 応募一覧（作成者・管理者のみ）
+func (h *Handler) ListTradeApplications(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	trade, userUUID, ok := h.loadTradeForApplication(c)
+	if !ok {
+		return nil
+	}
+
+	_, isManager, err := h.applicationManagerActor(ctx, trade, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !isManager {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the requester or a group admin can view applications"})
+	}
+
+	apps, err := h.queries.ListTradeApplications(ctx, trade.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch applications"})
+	}
+
+	return c.JSON(http.StatusOK, apps)
+}
+
+// 応募を承認する（OPEN → FILLED。残りの応募は自動で却下）
+func (h *Handler) ApproveTradeApplication(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	trade, userUUID, ok := h.loadTradeForApplication(c)
+	if !ok {
+		return nil
+	}
+	applicationID, err := uuid.Parse(c.Param("application_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid application_id"})
+	}
+
+	actor, ok, err := h.applicationManagerActor(ctx, trade, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the requester or a group admin can approve applications"})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	app, err := qtx.ApproveTradeApplication(ctx, database.ApproveTradeApplicationParams{
+		ID:        applicationID,
+		TradeID:   trade.ID,
+		DecidedBy: actorUUID(userUUID),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Application not found or already decided"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	applicant, err := qtx.GetUserByID(ctx, app.ApplicantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if applicant.DeletedAt.Valid {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The applicant has left the service"})
+	}
+
+	// 応募後に別のシフトを引き受けていることがあるので承認時にも確認する
+	if err := lockTradeParties(ctx, qtx, trade, app.ApplicantID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	conflicts, err := findAcceptorConflicts(ctx, qtx, app.ApplicantID, trade.ID, trade.ShiftStartAt, trade.ShiftEndAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(conflicts) > 0 {
+		return respondShiftConflicts(c, conflicts)
+	}
+	requesterConflicts, err := findSwapRequesterConflicts(ctx, qtx, trade)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(requesterConflicts) > 0 {
+		return respondSwapRequesterConflicts(c, requesterConflicts)
+	}
+
+	// OPEN → FILLED（AcceptShiftTrade の WHERE で OPEN・本人以外・所属を保証）
+	filled, err := qtx.AcceptShiftTrade(ctx, database.AcceptShiftTradeParams{
+		AcceptorID: actorUUID(app.ApplicantID),
+		ID:         trade.ID,
+		GroupID:    trade.GroupID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Cannot approve. The trade may no longer be open or the applicant left the group."})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if err := recordTradeTransition(ctx, qtx, filled.ID, TradeStatusOpen, TradeStatusFilled, actorUUID(userUUID), tradeReasonApplicationApproved); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+	}
+
+	// 交換募集なら「お返し」の成立済み募集も同じトランザクションで作る
+	reciprocals, err := createSwapReciprocalTrades(ctx, qtx, filled, app.ApplicantID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create swap trades"})
+	}
+
+	rejectedIDs, err := qtx.RejectPendingTradeApplications(ctx, database.RejectPendingTradeApplicationsParams{
+		TradeID:   trade.ID,
+		DecidedBy: actorUUID(userUUID),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to reject other applications"})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) {
+		if err := notifyApplicationApproved(ctx, qtx, filled, applicant, actor, reciprocals, rejectedIDs); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, filled)
+}
+
+// 応募を却下する（作成者・管理者）
+func (h *Handler) RejectTradeApplication(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	trade, userUUID, ok := h.loadTradeForApplication(c)
+	if !ok {
+		return nil
+	}
+	applicationID, err := uuid.Parse(c.Param("application_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid application_id"})
+	}
+
+	_, isManager, err := h.applicationManagerActor(ctx, trade, userUUID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !isManager {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the requester or a group admin can reject applications"})
+	}
+
+	// 見送りと応募者への通知を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	app, err := qtx.RejectTradeApplication(ctx, database.RejectTradeApplicationParams{
+		ID:        applicationID,
+		TradeID:   trade.ID,
+		DecidedBy: actorUUID(userUUID),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Application not found or already decided"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) {
+		msg := "🙇 応募ありがとうございました\n\n" +
+			"日時: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n\n" +
+			"今回は見送りとなりました。"
+		if err := enqueueUserText(ctx, qtx, app.ApplicantID, msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, app)
+}
+
+// 応募を取り下げる（応募者本人）
+func (h *Handler) WithdrawTradeApplication(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	trade, userUUID, ok := h.loadTradeForApplication(c)
+	if !ok {
+		return nil
+	}
+	applicationID, err := uuid.Parse(c.Param("application_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid application_id"})
+	}
+
+	app, err := h.queries.WithdrawTradeApplication(ctx, database.WithdrawTradeApplicationParams{
+		ID:          applicationID,
+		TradeID:     trade.ID,
+		ApplicantID: userUUID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Application not found or already decided"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, app)
+}
+
+// 応募の承認を応募者・作成者（管理者が承認したとき）・見送られた応募者に知らせる（承認と同じトランザクションの q を渡す）
+func notifyApplicationApproved(ctx context.Context, q *database.Queries, filled database.ShiftTrade, applicant database.User, actor TradeActor, reciprocals []database.ShiftTrade, rejectedIDs []uuid.UUID) error {
+	shiftRange := formatShiftRangeJST(filled.ShiftStartAt, filled.ShiftEndAt)
+
+	msg := "🎉 応募が承認されました！\n\n" +
+		"日時: " + shiftRange + "\n\n" +
+		formatSwapNoteJST("🔄 お返しに代わってもらうシフト:", reciprocals) +
+		"当日よろしくおねがいします！"
+	if err := enqueueUserMessages(ctx, q, applicant.ID, linebot.NewTextMessage(msg)); err != nil {
+		return err
+	}
+	if err := enqueueChatFilled(ctx, q, filled, applicant.DisplayName); err != nil {
+		return err
+	}
+
+	// 管理者が承認した場合は作成者にも知らせる
+	if actor == TradeActorAdmin {
+		msg := "🎉 シフトが成立しました！\n\n" +
+			"日時: " + shiftRange + "\n" +
+			"相手: " + applicant.DisplayName + " さん\n\n" +
+			formatSwapNoteJST("🔄 お返しにあなたが入るシフト:", reciprocals) +
+			"グループ管理者が応募を承認しました。"
+		if err := enqueueUserText(ctx, q, filled.RequesterID, msg); err != nil {
+			return err
+		}
+	}
+
+	// bot をブロックした人には送らない
+	var rejectedLineIDs []string
+	for _, id := range rejectedIDs {
+		u, err := q.GetUserByID(ctx, id)
+		if err != nil || !u.BotReachable {
+			continue
+		}
+		rejectedLineIDs = append(rejectedLineIDs, u.LineUserID)
+	}
+	valid, _ := filterValidLineUserIDs(rejectedLineIDs)
+	if len(valid) == 0 {
+		return nil
+	}
+	msg = "🙇 応募ありがとうございました\n\n" +
+		"日時: " + shiftRange + "\n\n" +
+		"今回は別の方に決まりました。"
+	return outbox.Multicast(ctx, q, valid, linebot.NewTextMessage(msg))
+}
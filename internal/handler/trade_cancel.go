@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"shift-change-app/internal/database"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+const defaultCancelAcceptanceCutoff = 24 * time.Hour
+
+// 引き受けを取り消せるのはシフト開始のこの時間前まで
+// CANCEL_ACCEPTANCE_CUTOFF（例: 12h）で変更できる
+func cancelAcceptanceCutoff() time.Duration {
+	s := strings.TrimSpace(os.Getenv("CANCEL_ACCEPTANCE_CUTOFF"))
+	if s == "" {
+		return defaultCancelAcceptanceCutoff
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return defaultCancelAcceptanceCutoff
+	}
+	return d
+}
+
+// 引き受けの取り消し（FILLED → OPEN）
+//
+//	引き受け者: そのまま取り消して募集を再開する。作成者から依頼が来ている場合は {"consent": false} で断れる
+//	作成者    : 引き受け者に取り消しを依頼する（引き受け者が同意 = このAPIを呼ぶと再開）
+func (h *Handler) CancelAcceptance(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	tradeID, err := uuid.Parse(c.Param("trade_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid trade_id"})
+	}
+
+	type Request struct {
+		Consent *bool `json:"consent"`
+	}
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	// 所属チェック
+	if _, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userUUID,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this group"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// trade 取得して group を一致確認
+	trade, err := h.queries.GetTradeByID(ctx, tradeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if trade.GroupID != groupID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
+	}
+
+	if TradeStatus(trade.Status) != TradeStatusFilled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Only filled trades can be cancelled"})
+	}
+
+	// 分割募集は枠ごとに引き受け者が違うため、取り消しには対応していない
+	if trade.SegmentMinutes > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Cancelling acceptance is not supported for split trades"})
+	}
+
+	// 「お返し」の募集は単独では取り消せない（元の交換募集ごと取り消す）
+	if trade.SwapParentID.Valid {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This is part of a swap. Cancel the original swap trade instead."})
+	}
+
+	cutoff := cancelAcceptanceCutoff()
+	deadline := trade.ShiftStartAt.Add(-cutoff)
+	if !time.Now().Before(deadline) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error":    "Cancellation deadline has passed",
+			"deadline": deadline.Format(time.RFC3339),
+		})
+	}
+
+	isAcceptor := trade.AcceptorID.Valid && trade.AcceptorID.UUID == userUUID
+	isRequester := trade.RequesterID == userUUID
+
+	switch {
+	case isAcceptor:
+		// 作成者からの依頼を断る（依頼が無いのに断られたら、引き受けは取り消さない）
+		if req.Consent != nil && !*req.Consent {
+			if !trade.CancelRequestedAt.Valid {
+				return c.JSON(http.StatusConflict, map[string]string{"error": "No cancellation request is pending"})
+			}
+			return h.declineCancelAcceptance(c, trade, userUUID)
+		}
+		return h.reopenTrade(c, trade, userUUID, cutoff)
+
+	case isRequester:
+		return h.requestCancelAcceptance(c, trade, userUUID)
+
+	default:
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only the acceptor or the requester can cancel acceptance"})
+	}
+}
+
+// 引き受けを取り消して募集を再開し、グループに再通知する
+func (h *Handler) reopenTrade(c echo.Context, trade database.ShiftTrade, acceptorUUID uuid.UUID, cutoff time.Duration) error {
+	ctx := c.Request().Context()
+
+	// 作成者からの依頼に同意した場合と、引き受け者が自分で取り消した場合を区別して記録する
+	reason := tradeReasonAcceptanceCancelled
+	if trade.CancelRequestedAt.Valid {
+		reason = tradeReasonCancelAgreed
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	// シフト開始が now + cutoff より後のものだけ再開できる（ReopenShiftTrade の WHERE で引き受け者本人を保証）
+	reopened, err := qtx.ReopenShiftTrade(ctx, database.ReopenShiftTradeParams{
+		ID:           trade.ID,
+		AcceptorID:   actorUUID(acceptorUUID),
+		ShiftStartAt: time.Now().Add(cutoff),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Cannot cancel acceptance. The trade may have been changed."})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if err := recordTradeTransition(ctx, qtx, reopened.ID, TradeStatusFilled, TradeStatusOpen, actorUUID(acceptorUUID), reason); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+	}
+
+	// 交換だった場合は「お返し」の募集も終了させる
+	if _, err := closeSwapReciprocalTrades(ctx, qtx, reopened.ID, acceptorUUID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to close swap trades"})
+	}
+
+	// 承認制で成立していた場合は承認済みの応募を取り消し扱いにする（再応募できる）
+	if err := qtx.CancelApprovedTradeApplication(ctx, reopened.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update applications"})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) {
+		if err := notifyTradeReopened(ctx, qtx, reopened, acceptorUUID); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, reopened)
+}
+
+// 引き受けの取り消しを作成者に知らせ、グループに募集の再開を再通知する（再開と同じトランザクションの q を渡す）
+func notifyTradeReopened(ctx context.Context, q *database.Queries, reopened database.ShiftTrade, acceptorUUID uuid.UUID) error {
+	acceptorName := "メンバー"
+	if acceptor, err := q.GetUserByID(ctx, acceptorUUID); err == nil {
+		acceptorName = acceptor.DisplayName
+	}
+
+	msg := "↩️ シフトの引き受けが取り消されました\n\n" +
+		"日時: " + formatShiftRangeJST(reopened.ShiftStartAt, reopened.ShiftEndAt) + "\n" +
+		"取り消した人: " + acceptorName + " さん\n\n" +
+		"募集を再開し、グループに再通知しました。"
+	if err := enqueueUserText(ctx, q, reopened.RequesterID, msg); err != nil {
+		return err
+	}
+
+	groupName, err := q.GetGroupName(ctx, reopened.GroupID)
+	if err != nil {
+		return err
+	}
+	msg = "📢 シフト募集が再開されました！\n\n" +
+		"グループ: " + groupName + "\n\n" +
+		"日時: " + formatShiftRangeJST(reopened.ShiftStartAt, reopened.ShiftEndAt) + "\n" +
+		"謝礼: " + reopened.BountyDescription + "\n\n" +
+		"アプリから確認してください！"
+	return enqueueGroupNotice(ctx, q, reopened.GroupID, "", linebot.NewTextMessage(msg))
+}
+
+// 作成者から引き受け者へ取り消しを依頼する
+func (h *Handler) requestCancelAcceptance(c echo.Context, trade database.ShiftTrade, requesterUUID uuid.UUID) error {
+	ctx := c.Request().Context()
+
+	if trade.CancelRequestedAt.Valid {
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"message": "Cancellation already requested. Waiting for the acceptor's consent.",
+			"trade":   trade,
+		})
+	}
+
+	// 依頼と引き受け者への通知を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	updated, err := qtx.RequestCancelAcceptance(ctx, database.RequestCancelAcceptanceParams{
+		ID:          trade.ID,
+		RequesterID: requesterUUID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Cannot request cancellation. The trade may have been changed."})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) && updated.AcceptorID.Valid {
+		requesterName := "メンバー"
+		if requester, err := qtx.GetUserByID(ctx, updated.RequesterID); err == nil {
+			requesterName = requester.DisplayName
+		}
+
+		msg := "🙏 引き受けの取り消し依頼が届きました\n\n" +
+			"日時: " + formatShiftRangeJST(updated.ShiftStartAt, updated.ShiftEndAt) + "\n" +
+			"依頼者: " + requesterName + " さん\n\n" +
+			"同意する場合はアプリの詳細ページから取り消してください。"
+		if err := enqueueUserText(ctx, qtx, updated.AcceptorID.UUID, msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Cancellation requested. Waiting for the acceptor's consent.",
+		"trade":   updated,
+	})
+}
+
+// 引き受け者が作成者からの取り消し依頼を断る
+func (h *Handler) declineCancelAcceptance(c echo.Context, trade database.ShiftTrade, acceptorUUID uuid.UUID) error {
+	ctx := c.Request().Context()
+
+	// 依頼の取り下げと作成者への通知を同一トランザクションで行う
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	updated, err := qtx.DeclineCancelAcceptance(ctx, database.DeclineCancelAcceptanceParams{
+		ID:         trade.ID,
+		AcceptorID: actorUUID(acceptorUUID),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "No pending cancellation request"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) {
+		msg := "🙅 引き受けの取り消し依頼は見送られました\n\n" +
+			"日時: " + formatShiftRangeJST(updated.ShiftStartAt, updated.ShiftEndAt) + "\n\n" +
+			"シフトはこのまま成立しています。"
+		if err := enqueueUserText(ctx, qtx, updated.RequesterID, msg); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
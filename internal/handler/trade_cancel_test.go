@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancelAcceptanceCutoff(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{env: "", want: defaultCancelAcceptanceCutoff},
+		{env: "12h", want: 12 * time.Hour},
+		{env: " 90m ", want: 90 * time.Minute},
+		{env: "0", want: 0},
+		{env: "-1h", want: defaultCancelAcceptanceCutoff},
+		{env: "soon", want: defaultCancelAcceptanceCutoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("CANCEL_ACCEPTANCE_CUTOFF", tt.env)
+			if got := cancelAcceptanceCutoff(); got != tt.want {
+				t.Errorf("cancelAcceptanceCutoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
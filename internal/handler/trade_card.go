@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/flex"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// 新しい募集の通知（グループ全員に送る）
+// segmentCount は分割募集の枠数（分割しなければ 0）
+func newTradeMessage(ctx context.Context, q *database.Queries, trade database.ShiftTrade, groupName string, counterShifts []database.ShiftTradeCounterShift, segmentCount int) linebot.SendingMessage {
+	shiftRange := formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt)
+	card := flex.Card{
+		Title:     "📢 新しいシフト募集があります！",
+		Requester: requesterPerson(ctx, q, trade.RequesterID),
+		Rows: []flex.Row{
+			{Label: "グループ", Value: groupName},
+			{Label: "日時", Value: shiftRange},
+			{Label: "謝礼", Value: trade.BountyDescription},
+		},
+		Actions: tradeActions(trade, true),
+		AltText: "📢 新しいシフト募集があります！\n\n" +
+			"グループ: " + groupName + "\n\n" +
+			"日時: " + shiftRange + "\n" +
+			"謝礼: " + trade.BountyDescription + "\n\n" +
+			"アプリから確認してください！",
+	}
+
+	switch {
+	case TradeType(trade.TradeType) == TradeTypeSwap:
+		card.Title = "🔄 新しいシフト交換の募集があります！"
+		card.Rows = []flex.Row{
+			{Label: "グループ", Value: groupName},
+			{Label: "代わってほしいシフト", Value: shiftRange},
+			{Label: "お返し", Value: formatCounterShiftsJST(counterShifts)},
+		}
+		card.Note = "お返しに作成者が上のシフトのどれかに代わります。"
+		card.AltText = "🔄 新しいシフト交換の募集があります！\n\n" +
+			"グループ: " + groupName + "\n\n" +
+			"代わってほしいシフト: " + shiftRange + "\n" +
+			"お返しに作成者が代わるシフト:\n" + formatCounterShiftsJST(counterShifts) + "\n\n" +
+			"アプリから確認してください！"
+	case segmentCount > 0:
+		slots := strconv.Itoa(int(trade.SegmentMinutes)) + "分 × " + strconv.Itoa(segmentCount) + "枠"
+		card.Title = "🧩 新しいシフト募集があります！（時間帯ごとに引き受けOK）"
+		card.Rows = append(card.Rows, flex.Row{Label: "1枠", Value: slots})
+		card.AltText = "🧩 新しいシフト募集があります！（時間帯ごとに引き受けOK）\n\n" +
+			"グループ: " + groupName + "\n\n" +
+			"日時: " + shiftRange + "\n" +
+			"1枠: " + slots + "\n" +
+			"謝礼: " + trade.BountyDescription + "\n\n" +
+			"アプリから確認してください！"
+	}
+	return flex.Message(card)
+}
+
+// 未成立シフトのリマインド（作成者向けと、他のメンバー向け）
+// stage は「5時間前」のような段階、openText はまだ埋まっていない時間帯（分割募集でなければ空）
+func reminderMessages(r database.ListDueTradeRemindersRow, stage, openText string) (toRequester, toMembers linebot.SendingMessage) {
+	shiftRange := formatShiftRangeJST(r.ShiftStartAt, r.ShiftEndAt)
+	rows := []flex.Row{
+		{Label: "グループ", Value: r.GroupName},
+		{Label: "日時", Value: shiftRange},
+	}
+	if openText != "" {
+		rows = append(rows, flex.Row{Label: "空き", Value: openText})
+	}
+	shiftText := "グループ: " + r.GroupName + "\n" +
+		"日時: " + shiftRange
+	openNote := ""
+	if openText != "" {
+		openNote = "\n\nまだ埋まっていない時間帯:\n" + openText
+	}
+
+	toRequester = flex.Message(flex.Card{
+		Title:   "⚠️ 【重要】シフト成立期限が迫っています",
+		Rows:    rows,
+		Note:    "開始" + stage + "になりましたが、まだ代わりの人が見つかっていません。見つからない場合は、早めにバイト先に連絡しましょう！",
+		Actions: tradeActions(database.ShiftTrade{ID: r.ID, GroupID: r.GroupID}, false),
+		AltText: "⚠️ 【重要】シフト成立期限が迫っています\n\n" +
+			shiftText + "\n\n" +
+			"開始" + stage + "になりましたが、まだ代わりの人が見つかっていません。" +
+			openNote + "\n\n" +
+			"見つからない場合は、早めにバイト先に連絡しましょう！",
+	})
+
+	toMembers = flex.Message(flex.Card{
+		Title:   "🆘 代わりの人を探しています（開始" + stage + "）",
+		Rows:    rows,
+		Actions: tradeActions(database.ShiftTrade{ID: r.ID, GroupID: r.GroupID, SegmentMinutes: r.SegmentMinutes}, true),
+		AltText: "🆘 代わりの人を探しています（開始" + stage + "）\n\n" +
+			shiftText +
+			openNote + "\n\n" +
+			"引き受けられる方はアプリから「代わる」を押してください！",
+	})
+	return toRequester, toMembers
+}
+
+// 成立した募集の通知（作成者向けと、引き受け者向け）
+// reciprocals は交換募集の「お返し」の募集（交換でなければ空）
+func tradeAcceptedMessages(trade database.ShiftTrade, acceptorName string, reciprocals []database.ShiftTrade) (toRequester, toAcceptor linebot.SendingMessage) {
+	shiftRange := formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt)
+	actions := tradeActions(trade, false)
+
+	requesterRows := []flex.Row{
+		{Label: "日時", Value: shiftRange},
+		{Label: "相手", Value: acceptorName + " さん"},
+	}
+	acceptorRows := []flex.Row{
+		{Label: "日時", Value: shiftRange},
+	}
+	if len(reciprocals) > 0 {
+		requesterRows = append(requesterRows, flex.Row{Label: "お返し", Value: formatReciprocalsJST(reciprocals)})
+		acceptorRows = append(acceptorRows, flex.Row{Label: "お返し", Value: formatReciprocalsJST(reciprocals)})
+	}
+
+	toRequester = flex.Message(flex.Card{
+		Title:   "🎉 シフトが成立しました！",
+		Rows:    requesterRows,
+		Note:    "引き継ぎや業務内容など、詳細を追記するとスムーズです。",
+		Actions: actions,
+		AltText: "🎉 シフトが成立しました！\n\n" +
+			"日時: " + shiftRange + "\n" +
+			"相手: " + acceptorName + " さん\n\n" +
+			formatSwapNoteJST("🔄 お返しにあなたが入るシフト:", reciprocals) +
+			"あなたのシフト募集が引き受けられました。\n" +
+			"引き継ぎや業務内容など、詳細を追記するとスムーズです。\n" +
+			"（詳細ページから追記できます）",
+	})
+	toAcceptor = flex.Message(flex.Card{
+		Title:   "👍 シフトを引き受けました！",
+		Rows:    acceptorRows,
+		Note:    "当日よろしくおねがいします！",
+		Actions: actions,
+		AltText: "👍 シフトを引き受けました！\n\n" +
+			"日時: " + shiftRange + "\n\n" +
+			formatSwapNoteJST("🔄 お返しに代わってもらうシフト:", reciprocals) +
+			"当日よろしくおねがいします！",
+	})
+	return toRequester, toAcceptor
+}
+
+// 募集カードの作成者欄（取得できなければ nil）
+func requesterPerson(ctx context.Context, q *database.Queries, userID uuid.UUID) *flex.Person {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	return &flex.Person{Name: user.DisplayName, ImageURL: user.ProfileImageUrl.String}
+}
+
+// 募集カードのボタン
+// acceptable なら LINE 上で引き受ける postback ボタンを先頭に付ける（分割募集は枠を選ぶ必要があるので付けない）
+// 詳細画面を LIFF で開くボタンは LIFF_ID が設定されているときだけ付ける
+func tradeActions(trade database.ShiftTrade, acceptable bool) []flex.Action {
+	actions := []flex.Action{}
+	if acceptable && trade.SegmentMinutes == 0 {
+		actions = append(actions, flex.Action{Label: "このシフトを代わる", Data: acceptPostbackData(trade.ID)})
+	}
+	if uri := flex.TradeURL(trade.GroupID.String(), trade.ID.String()); uri != "" {
+		actions = append(actions, flex.Action{Label: "詳細を見る", URI: uri})
+	}
+	return actions
+}
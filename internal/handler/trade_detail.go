@@ -24,8 +24,10 @@ func (h *Handler) ShowTradeDetail(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Invalid trade_id")
 	}
 
-	// user_id は表示のためだけに持ってる
-	userIDStr := c.QueryParam("user_id")
+	userID, ok := ViewUserID(c)
+	if !ok {
+		return h.ShowHomeEntry(c)
+	}
 
 	// データ取得
 	trade, err := h.queries.GetTradeByID(ctx, tradeID)
@@ -44,23 +46,56 @@ func (h *Handler) ShowTradeDetail(c echo.Context) error {
 		return c.String(http.StatusNotFound, "Group not found")
 	}
 
+	// 所属チェック
+	isMember, err := h.isGroupMember(ctx, groupID, userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to check membership")
+	}
+	if !isMember {
+		return c.String(http.StatusForbidden, "You are not a member of this group")
+	}
+
 	requester, _ := h.queries.GetUserByID(ctx, trade.RequesterID)
 
-	canEdit := false
-	if userIDStr != "" {
-		if userUUID, err := uuid.Parse(userIDStr); err == nil {
-			canEdit = (trade.RequesterID == userUUID)
+	canEdit := trade.RequesterID == userID
+
+	// 承認制グループの応募一覧（作成者・管理者のみ）
+	_, canManage, err := h.applicationManagerActor(ctx, trade, userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to check role")
+	}
+	var applications []database.ListTradeApplicationsRow
+	if canManage {
+		applications, err = h.queries.ListTradeApplications(ctx, trade.ID)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, "Failed to fetch applications")
 		}
 	}
 
+	// 交換募集の差し出しシフト（「お返し」の募集なら元の募集へのリンクを出す）
+	counterShifts, err := h.queries.ListCounterShifts(ctx, trade.ID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to fetch counter shifts")
+	}
+
+	// 分割募集の枠（引き受け者名つき）
+	segments, err := h.queries.ListShiftTradeSegments(ctx, trade.ID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to fetch segments")
+	}
+
 	data := map[string]interface{}{
-		"Group":          group,
-		"Trade":          trade,
-		"Requester":      requester,
-		"CurrentUserID":  userIDStr,
-		"GroupID":        groupID.String(),
-		"CanEditDetails": canEdit,
-		"LiffID":         os.Getenv("LIFF_ID"),
+		"Segments":              segments,
+		"CounterShifts":         counterShifts,
+		"Group":                 group,
+		"Trade":                 trade,
+		"Requester":             requester,
+		"CurrentUserID":         userID.String(),
+		"GroupID":               groupID.String(),
+		"CanEditDetails":        canEdit,
+		"CanManageApplications": canManage,
+		"Applications":          applications,
+		"LiffID":                os.Getenv("LIFF_ID"),
 	}
 	return c.Render(http.StatusOK, "trade_detail.html", data)
 }
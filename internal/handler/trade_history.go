@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// シフト交代リクエストの状態遷移履歴
+func (h *Handler) ListTradeHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid group_id"})
+	}
+	tradeID, err := uuid.Parse(c.Param("trade_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid trade_id"})
+	}
+
+	userUUID, err := h.userUUIDFromAuth(c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not registered"})
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	// 所属チェック
+	if _, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userUUID,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this group"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// trade 取得して group を一致確認
+	trade, err := h.queries.GetTradeByID(ctx, tradeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if trade.GroupID != groupID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Trade not found"})
+	}
+
+	events, err := h.queries.ListShiftTradeEvents(ctx, tradeID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"trade_id": tradeID,
+		"status":   trade.Status,
+		"events":   events,
+	})
+}
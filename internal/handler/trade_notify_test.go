@@ -0,0 +1,225 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/handler"
+	"shift-change-app/internal/outbox"
+	"shift-change-app/internal/router"
+
+	"github.com/labstack/echo/v4"
+	_ "github.com/lib/pq"
+)
+
+// 募集の作成と引き受けの通知が outbox を通って LINE に送られるまでを確かめる
+// TEST_DATABASE_URL（テスト用の Postgres）が無ければスキップする
+func TestTradeNotificationsThroughOutbox(t *testing.T) {
+	db := openTestDB(t)
+	t.Setenv("DEV_AUTH_TOKEN", "")
+	t.Setenv("LIFF_ID", "")
+	t.Setenv("NOTIFY_FORMAT", "text")
+
+	queries := database.New(db)
+	recorder := handler.NewRecordingNotifier()
+	h := handler.NewHandler(db, queries, recorder, "", handler.NewSessionSigner([]byte("test"), time.Hour, false))
+	e := echo.New()
+	router.SetupRoutes(e, h, subVerifier{})
+
+	requester := newLineUserID(t)
+	acceptor := newLineUserID(t)
+
+	call(t, e, requester, http.MethodPost, "/api/users", map[string]string{"name": "作成者"}, nil)
+	call(t, e, acceptor, http.MethodPost, "/api/users", map[string]string{"name": "引き受け者"}, nil)
+
+	var group database.JobGroup
+	call(t, e, requester, http.MethodPost, "/api/groups", map[string]string{"group_name": "駅前店"}, &group)
+	call(t, e, acceptor, http.MethodPost, "/api/groups/join", map[string]string{"invitation_code": group.InvitationCode}, nil)
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	var trade database.ShiftTrade
+	call(t, e, requester, http.MethodPost, "/api/groups/"+group.ID.String()+"/trades", map[string]any{
+		"start_at": start,
+		"end_at":   start.Add(4 * time.Hour),
+		"bounty":   "ジュース",
+	}, &trade)
+
+	// 送信はワーカーが outbox から取り出すまで行われない
+	if sent := recorder.Sent(); len(sent) != 0 {
+		t.Fatalf("sent before the outbox worker ran: %+v", sent)
+	}
+	deliver(t, queries, recorder)
+
+	created := recorder.Sent()
+	if len(created) != 1 || created[0].Kind != handler.SentMulticast {
+		t.Fatalf("after CreateTrade sent = %+v, want one multicast", created)
+	}
+	if got, want := sortedCopy(created[0].To), sortedCopy([]string{requester, acceptor}); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("multicast to %v, want %v", got, want)
+	}
+	assertMessageContains(t, created[0], "新しいシフト募集", "駅前店", "ジュース")
+	if created[0].RetryKey == "" {
+		t.Error("outbox sends should carry X-Line-Retry-Key")
+	}
+
+	recorder.Reset()
+	call(t, e, acceptor, http.MethodPut, "/api/groups/"+group.ID.String()+"/trades/"+trade.ID.String()+"/accept", map[string]any{}, nil)
+	deliver(t, queries, recorder)
+
+	toRequester := recorder.SentTo(requester)
+	if len(toRequester) != 1 || toRequester[0].Kind != handler.SentPush {
+		t.Fatalf("sent to requester = %+v, want one push", toRequester)
+	}
+	assertMessageContains(t, toRequester[0], "シフトが成立しました", "引き受け者 さん")
+
+	toAcceptor := recorder.SentTo(acceptor)
+	if len(toAcceptor) != 1 || toAcceptor[0].Kind != handler.SentPush {
+		t.Fatalf("sent to acceptor = %+v, want one push", toAcceptor)
+	}
+	assertMessageContains(t, toAcceptor[0], "シフトを引き受けました")
+}
+
+// トークンをそのまま LINE userId として扱う TokenVerifier
+type subVerifier struct{}
+
+func (subVerifier) Verify(_ context.Context, idToken string) (*handler.IDTokenClaims, error) {
+	return &handler.IDTokenClaims{Sub: idToken}, nil
+}
+
+// テストごとに新しいスキーマを作り、migrations を当てた DB を返す
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := "handler_test_" + randomHex(t, 6)
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Logf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	// public は uuid-ossp が既に入っているときのため
+	sep := " "
+	if strings.Contains(dsn, "://") {
+		sep = "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+	}
+	db, err := sql.Open("postgres", dsn+sep+"search_path="+schema+",public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		body, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(body)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(f), err)
+		}
+	}
+	return db
+}
+
+// API を呼び、200 でなければ失敗にする（out が nil でなければレスポンスを読む）
+func call(t *testing.T, e *echo.Echo, lineUserID, method, path string, body, out any) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+lineUserID)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s = %d: %s", method, path, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+}
+
+// outbox に積まれた通知をワーカーで送る
+func deliver(t *testing.T, queries *database.Queries, sender outbox.Sender) {
+	t.Helper()
+	if _, err := outbox.NewWorker(queries, sender).ProcessBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// outbox から送ったメッセージは JSON のまま渡るので、JSON に文字列が含まれるかで確かめる
+func assertMessageContains(t *testing.T, m handler.SentMessage, wants ...string) {
+	t.Helper()
+	raw, err := json.Marshal(m.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	text := fmt.Sprint(decoded)
+	for _, want := range wants {
+		if !strings.Contains(text, want) {
+			t.Errorf("message %s does not contain %q", raw, want)
+		}
+	}
+}
+
+func newLineUserID(t *testing.T) string {
+	return "U" + randomHex(t, 16)
+}
+
+func randomHex(t *testing.T, n int) string {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func sortedCopy(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/flex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 募集カードの「このシフトを代わる」ボタンの postback action
+const postbackActionAcceptTrade = "accept"
+
+// 募集を引き受ける postback の data（action=accept&trade=<id>）
+func acceptPostbackData(tradeID uuid.UUID) string {
+	v := url.Values{}
+	v.Set("action", postbackActionAcceptTrade)
+	v.Set("trade", tradeID.String())
+	return v.Encode()
+}
+
+// LINE の「このシフトを代わる」ボタンで募集を引き受ける。返信する文を返す
+// 画面の「代わる」と同じく、承認制のグループでは応募として受け付ける
+func (h *Handler) handleTradePostback(ctx context.Context, actor database.User, data url.Values) (string, error) {
+	tradeID, err := uuid.Parse(data.Get("trade"))
+	if err != nil {
+		return "この募集は見つかりませんでした", nil
+	}
+
+	trade, err := h.queries.GetTradeByID(ctx, tradeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "この募集は見つかりませんでした（削除された可能性があります）", nil
+		}
+		return "", err
+	}
+
+	if _, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: trade.GroupID,
+		UserID:  actor.ID,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "このグループのメンバーではないため引き受けられません", nil
+		}
+		return "", err
+	}
+
+	group, err := h.queries.GetJobGroupByID(ctx, trade.GroupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "この募集は見つかりませんでした（グループが解散されています）", nil
+		}
+		return "", err
+	}
+
+	if trade.RequesterID == actor.ID {
+		return "自分の募集は引き受けられません", nil
+	}
+	if TradeStatus(trade.Status) != TradeStatusOpen {
+		return "ごめんなさい、この募集はすでに締め切られています（他の人が引き受けました）", nil
+	}
+	if !trade.ShiftStartAt.After(time.Now()) {
+		return "このシフトはすでに始まっているため引き受けられません", nil
+	}
+
+	// 分割募集は引き受ける時間帯を画面で選んでもらう
+	if trade.SegmentMinutes > 0 {
+		reply := "時間帯ごとの募集です。アプリの詳細ページから引き受ける時間帯を選んでください"
+		if uri := flex.TradeURL(trade.GroupID.String(), trade.ID.String()); uri != "" {
+			reply += "\n" + uri
+		}
+		return reply, nil
+	}
+
+	shiftRange := formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt)
+
+	if AcceptMode(group.AcceptMode) == AcceptModeApproval {
+		_, err := h.applyToTrade(ctx, trade, actor.ID, true)
+		if reply, ok := acceptPostbackErrorReply(err); ok {
+			return reply, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return "📝 応募しました！（" + shiftRange + "）\n作成者の承認をお待ちください", nil
+	}
+
+	_, err = h.acceptOpenTrade(ctx, trade, actor.ID, true)
+	if reply, ok := acceptPostbackErrorReply(err); ok {
+		return reply, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "✅ 引き受けが完了しました（" + shiftRange + "）", nil
+}
+
+// 引き受け・応募できなかった理由を返信文にする（該当しないエラーなら ok=false）
+func acceptPostbackErrorReply(err error) (reply string, ok bool) {
+	var conflictErr *shiftConflictError
+	var requesterConflictErr *swapRequesterConflictError
+	switch {
+	case err == nil:
+		return "", false
+	case errors.As(err, &conflictErr):
+		return "すでに引き受けているシフトと時間が重なるため引き受けられません", true
+	case errors.As(err, &requesterConflictErr):
+		return "差し出すシフトが作成者の引き受け済みのシフトと重なるため交換できません", true
+	case errors.Is(err, errTradeUnavailable):
+		return "ごめんなさい、この募集はすでに他の人が引き受けました", true
+	case errors.Is(err, errOwnTrade):
+		return "自分の募集は引き受けられません", true
+	case errors.Is(err, errAlreadyApplied):
+		return "この募集にはすでに応募しています", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// 分割募集の枠の長さの下限と、1つの募集で作れる枠数の上限
+const (
+	minSegmentMinutes = 30
+	maxSegments       = 48
+)
+
+// 分割募集の1枠
+type segmentRange struct {
+	StartAt time.Time
+	EndAt   time.Time
+}
+
+// シフトを segmentMinutes ごとの枠に分ける（0 なら分割しない）
+// シフトの長さは枠の長さで割り切れる必要がある
+func buildSegments(start, end time.Time, segmentMinutes int32) ([]segmentRange, error) {
+	if segmentMinutes == 0 {
+		return nil, nil
+	}
+	if segmentMinutes < minSegmentMinutes {
+		return nil, errors.New("segment_minutes must be at least " + strconv.Itoa(minSegmentMinutes))
+	}
+
+	step := time.Duration(segmentMinutes) * time.Minute
+	total := end.Sub(start)
+	if total <= step {
+		return nil, errors.New("the shift is too short to split")
+	}
+	if total%step != 0 {
+		return nil, errors.New("the shift length must be a multiple of segment_minutes")
+	}
+	if int(total/step) > maxSegments {
+		return nil, errors.New("too many segments")
+	}
+
+	segments := make([]segmentRange, 0, total/step)
+	for s := start; s.Before(end); s = s.Add(step) {
+		segments = append(segments, segmentRange{StartAt: s, EndAt: s.Add(step)})
+	}
+	return segments, nil
+}
+
+// 分割募集の枠を引き受ける（AcceptTrade から呼ばれる）
+// segmentIDs が空なら残りの枠を全て引き受ける。全枠が埋まった時点で FILLED にする
+func (h *Handler) acceptTradeSegments(c echo.Context, trade database.ShiftTrade, acceptorUUID uuid.UUID, segmentIDs []uuid.UUID) error {
+	ctx := c.Request().Context()
+
+	if trade.RequesterID == acceptorUUID {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "You cannot accept your own request"})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start transaction"})
+	}
+	defer tx.Rollback()
+
+	qtx := h.queries.WithTx(tx)
+
+	// 同じ人が重なる募集を同時に引き受けないよう、先に引き受ける人の行をロックする
+	if err := lockAcceptor(ctx, qtx, acceptorUUID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 最後の枠を同時に引き受けたときに成立判定が漏れないよう、募集の行をロックする
+	locked, err := qtx.GetTradeByIDForUpdate(ctx, trade.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if TradeStatus(locked.Status) != TradeStatusOpen {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This trade is no longer open"})
+	}
+	if !locked.ShiftStartAt.After(time.Now()) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This shift has already started"})
+	}
+
+	if len(segmentIDs) == 0 {
+		open, err := qtx.ListOpenShiftTradeSegments(ctx, trade.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		for _, s := range open {
+			segmentIDs = append(segmentIDs, s.ID)
+		}
+	}
+	if len(segmentIDs) == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "No open segments"})
+	}
+
+	accepted, err := qtx.AcceptShiftTradeSegments(ctx, database.AcceptShiftTradeSegmentsParams{
+		AcceptorID: actorUUID(acceptorUUID),
+		TradeID:    trade.ID,
+		SegmentIds: segmentIDs,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(accepted) != len(segmentIDs) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Some segments are already taken or do not belong to this trade"})
+	}
+
+	// 他の募集ですでに引き受けている時間帯と重なる枠は引き受けられない
+	for _, seg := range accepted {
+		conflicts, err := findAcceptorConflicts(ctx, qtx, acceptorUUID, trade.ID, seg.SegmentStartAt, seg.SegmentEndAt)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if len(conflicts) > 0 {
+			return respondShiftConflicts(c, conflicts)
+		}
+	}
+
+	// 全枠が埋まったら OPEN → FILLED
+	result := locked
+	filled, err := qtx.FillSplitShiftTrade(ctx, trade.ID)
+	switch {
+	case err == nil:
+		result = filled
+		if err := recordTradeTransition(ctx, qtx, filled.ID, TradeStatusOpen, TradeStatusFilled, actorUUID(acceptorUUID), tradeReasonSegmentsCovered); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record trade history"})
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		// まだ空き枠がある
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	remaining, err := qtx.ListOpenShiftTradeSegments(ctx, trade.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// 通知（devバイパス時は送らない）
+	if shouldNotify(c) {
+		if err := notifySegmentsAccepted(ctx, qtx, trade, acceptorUUID, accepted, remaining); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to enqueue notification"})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to commit transaction"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"trade":              result,
+		"accepted_segments":  accepted,
+		"remaining_segments": remaining,
+	})
+}
+
+// 枠の引き受けを作成者と引き受け者に知らせる（引き受けと同じトランザクションの q を渡す）
+// remaining が空なら全ての枠が埋まってシフトが成立している
+func notifySegmentsAccepted(ctx context.Context, q *database.Queries, trade database.ShiftTrade, acceptorUUID uuid.UUID, accepted, remaining []database.ShiftTradeSegment) error {
+	acceptorName := "メンバー"
+	if acceptor, err := q.GetUserByID(ctx, acceptorUUID); err == nil {
+		acceptorName = acceptor.DisplayName
+	}
+	acceptedText := formatSegmentsJST(accepted)
+
+	msg := "🧩 シフトの一部が引き受けられました\n\n" +
+		"シフト: " + formatShiftRangeJST(trade.ShiftStartAt, trade.ShiftEndAt) + "\n" +
+		"引き受けた人: " + acceptorName + " さん\n" +
+		acceptedText + "\n\n"
+	if len(remaining) == 0 {
+		msg += "🎉 全ての時間帯が埋まり、シフトが成立しました！"
+	} else {
+		msg += "まだ空いている時間帯:\n" + formatSegmentsJST(remaining)
+	}
+	if err := enqueueUserText(ctx, q, trade.RequesterID, msg); err != nil {
+		return err
+	}
+	if len(remaining) == 0 {
+		if err := enqueueChatFilled(ctx, q, trade, acceptorName); err != nil {
+			return err
+		}
+	}
+
+	msg = "👍 シフトを引き受けました！\n\n" +
+		acceptedText + "\n\n" +
+		"当日よろしくおねがいします！"
+	return enqueueUserText(ctx, q, acceptorUUID, msg)
+}
+
+// 通知文用: 枠を1行ずつ並べる
+func formatSegmentsJST(segments []database.ShiftTradeSegment) string {
+	lines := make([]string, 0, len(segments))
+	for _, s := range segments {
+		lines = append(lines, "・"+formatShiftRangeJST(s.SegmentStartAt, s.SegmentEndAt))
+	}
+	return strings.Join(lines, "\n")
+}
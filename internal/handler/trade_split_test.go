@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildSegments(t *testing.T) {
+	start := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		length         time.Duration
+		segmentMinutes int32
+		wantCount      int
+		wantErr        bool
+	}{
+		{name: "not split", length: 4 * time.Hour, segmentMinutes: 0, wantCount: 0},
+		{name: "hourly", length: 4 * time.Hour, segmentMinutes: 60, wantCount: 4},
+		{name: "minimum segment", length: time.Hour, segmentMinutes: 30, wantCount: 2},
+		{name: "shorter than the minimum", length: 4 * time.Hour, segmentMinutes: 15, wantErr: true},
+		{name: "not divisible", length: 4*time.Hour + 30*time.Minute, segmentMinutes: 60, wantErr: true},
+		{name: "one segment only", length: time.Hour, segmentMinutes: 60, wantErr: true},
+		{name: "shorter than one segment", length: 30 * time.Minute, segmentMinutes: 60, wantErr: true},
+		{name: "at the segment limit", length: 24 * time.Hour, segmentMinutes: 30, wantCount: maxSegments},
+		{name: "over the segment limit", length: 24*time.Hour + 30*time.Minute, segmentMinutes: 30, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := start.Add(tt.length)
+			segments, err := buildSegments(start, end, tt.segmentMinutes)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("buildSegments = %d segments, want an error", len(segments))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildSegments: %v", err)
+			}
+			if len(segments) != tt.wantCount {
+				t.Fatalf("got %d segments, want %d", len(segments), tt.wantCount)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+
+			// 枠は隙間なく続き、シフトの開始から終了までを覆う
+			if !segments[0].StartAt.Equal(start) || !segments[len(segments)-1].EndAt.Equal(end) {
+				t.Errorf("segments cover %v - %v, want %v - %v", segments[0].StartAt, segments[len(segments)-1].EndAt, start, end)
+			}
+			step := time.Duration(tt.segmentMinutes) * time.Minute
+			for i, s := range segments {
+				if s.EndAt.Sub(s.StartAt) != step {
+					t.Errorf("segment %d is %v long, want %v", i, s.EndAt.Sub(s.StartAt), step)
+				}
+				if i > 0 && !s.StartAt.Equal(segments[i-1].EndAt) {
+					t.Errorf("segment %d starts at %v, want %v", i, s.StartAt, segments[i-1].EndAt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"shift-change-app/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// TradeStatus はシフト交代リクエストの状態（shift_trades.status）
+type TradeStatus string
+
+const (
+	TradeStatusOpen      TradeStatus = "OPEN"      // 募集中
+	TradeStatusFilled    TradeStatus = "FILLED"    // 成立（引き受け済み）
+	TradeStatusCompleted TradeStatus = "COMPLETED" // 謝礼の支払いまで完了
+	TradeStatusClosed    TradeStatus = "CLOSED"    // 募集終了（退会・解散など）
+)
+
+// TradeActor は状態遷移を起こす主体
+type TradeActor string
+
+const (
+	TradeActorRequester TradeActor = "REQUESTER" // 募集の作成者
+	TradeActorAcceptor  TradeActor = "ACCEPTOR"  // 引き受けたメンバー
+	TradeActorMember    TradeActor = "MEMBER"    // 作成者以外のグループメンバー
+	TradeActorAdmin     TradeActor = "ADMIN"     // グループの管理者
+	TradeActorSystem    TradeActor = "SYSTEM"    // 解散・退会などに伴う自動処理
+)
+
+// 遷移理由（shift_trade_events.reason）
+// 一括クローズの理由（USER_WITHDRAWN / GROUP_DISSOLVED / SWAP_CANCELLED）は SQL 側で記録する（下の close* を通して実行する）
+// グループ脱退の理由（MEMBER_LEFT / MEMBER_REMOVED）は group_member.go
+const (
+	tradeReasonCreated  = "CREATED"
+	tradeReasonAccepted = "ACCEPTED"
+	tradeReasonPaid     = "PAID"
+
+	tradeReasonApplicationApproved = "APPLICATION_APPROVED" // 承認制グループで応募を承認
+	tradeReasonSwapReciprocal      = "SWAP_RECIPROCAL"      // 交換成立で作られた「お返し」の募集
+	tradeReasonSegmentsCovered     = "SEGMENTS_COVERED"     // 分割募集の全ての枠が埋まった
+
+	tradeReasonDeleted = "DELETED" // 募集の削除（作成者・管理者による取り消し）
+
+	tradeReasonAcceptanceCancelled = "ACCEPTANCE_CANCELLED" // 引き受け者による取り消し
+	tradeReasonCancelAgreed        = "CANCEL_AGREED"        // 作成者の依頼に引き受け者が同意
+)
+
+// 遷移表: from → to → 遷移を許可する主体
+// 実際の遷移は、状態を変える各 UPDATE の WHERE（status = ... と作成者・引き受け者・所属の条件）で保証する
+// 状態を変えるクエリを足すときは、この表に載っている遷移だけを WHERE で許すこと
+var tradeTransitions = map[TradeStatus]map[TradeStatus][]TradeActor{
+	TradeStatusOpen: {
+		// 承認制グループでは作成者・管理者が応募を承認して成立させる
+		TradeStatusFilled: {TradeActorMember, TradeActorRequester, TradeActorAdmin},
+		TradeStatusClosed: {TradeActorRequester, TradeActorAdmin, TradeActorSystem},
+	},
+	TradeStatusFilled: {
+		// 作成者からの取り消しは引き受け者の同意（= 引き受け者の操作）で実行する
+		// 分割募集の枠を引き受けたメンバーがグループを抜けたときは自動で募集中に戻す
+		TradeStatusOpen:      {TradeActorAcceptor, TradeActorSystem},
+		TradeStatusCompleted: {TradeActorRequester},
+		// 交換の引き受けが取り消されたときの「お返し」の募集
+		TradeStatusClosed: {TradeActorSystem},
+	},
+}
+
+// CanTransition は actor が from → to の遷移を行えるかを返す
+func CanTransition(from, to TradeStatus, actor TradeActor) bool {
+	for _, a := range tradeTransitions[from][to] {
+		if a == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// 状態遷移を履歴に記録する
+// 状態の UPDATE と同じトランザクションの q を渡すこと
+// from が空のときは作成（NULL → to）として記録する
+func recordTradeTransition(ctx context.Context, q *database.Queries, tradeID uuid.UUID, from, to TradeStatus, actorID uuid.NullUUID, reason string) error {
+	_, err := q.CreateShiftTradeEvent(ctx, database.CreateShiftTradeEventParams{
+		TradeID:    tradeID,
+		FromStatus: sql.NullString{String: string(from), Valid: from != ""},
+		ToStatus:   string(to),
+		ActorID:    actorID,
+		Reason:     reason,
+	})
+	return err
+}
+
+func actorUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// 退会するユーザーの OPEN の募集を全て CLOSED にする（作成者本人の操作）
+func closeOpenTradesByRequester(ctx context.Context, q *database.Queries, requesterID uuid.UUID) (int64, error) {
+	return q.CloseOpenShiftTradesByRequester(ctx, requesterID)
+}
+
+// グループの OPEN の募集を全て CLOSED にする（owner による解散、または owner の退会に伴う解散）
+func closeOpenTradesByGroup(ctx context.Context, q *database.Queries, groupID, actorID uuid.UUID) (int64, error) {
+	return q.CloseOpenShiftTradesByGroup(ctx, database.CloseOpenShiftTradesByGroupParams{
+		GroupID: groupID,
+		ActorID: actorUUID(actorID),
+	})
+}
+
+// グループを抜ける・外されるメンバーの OPEN の募集を全て CLOSED にして、閉じた募集の id を返す
+func closeOpenTradesByRequesterInGroup(ctx context.Context, q *database.Queries, groupID, requesterID, actorID uuid.UUID, reason string) ([]uuid.UUID, error) {
+	return q.CloseOpenShiftTradesByRequesterInGroup(ctx, database.CloseOpenShiftTradesByRequesterInGroupParams{
+		RequesterID: requesterID,
+		GroupID:     groupID,
+		ActorID:     actorUUID(actorID),
+		Reason:      reason,
+	})
+}
+
+// 交換の引き受けが取り消されたとき「お返し」の募集を CLOSED にする（取り消しに伴う自動処理）
+func closeSwapReciprocalTrades(ctx context.Context, q *database.Queries, parentID, actorID uuid.UUID) (int64, error) {
+	return q.CloseSwapReciprocalTrades(ctx, database.CloseSwapReciprocalTradesParams{
+		SwapParentID: actorUUID(parentID),
+		ActorID:      actorUUID(actorID),
+	})
+}
@@ -0,0 +1,34 @@
+package handler
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to TradeStatus
+		actor    TradeActor
+		want     bool
+	}{
+		{TradeStatusOpen, TradeStatusFilled, TradeActorMember, true},
+		{TradeStatusOpen, TradeStatusFilled, TradeActorAdmin, true},
+		{TradeStatusOpen, TradeStatusFilled, TradeActorSystem, false},
+		{TradeStatusOpen, TradeStatusClosed, TradeActorRequester, true},
+		{TradeStatusOpen, TradeStatusClosed, TradeActorMember, false},
+		{TradeStatusOpen, TradeStatusCompleted, TradeActorRequester, false},
+		{TradeStatusFilled, TradeStatusOpen, TradeActorAcceptor, true},
+		{TradeStatusFilled, TradeStatusOpen, TradeActorSystem, true},
+		{TradeStatusFilled, TradeStatusOpen, TradeActorRequester, false},
+		{TradeStatusFilled, TradeStatusCompleted, TradeActorRequester, true},
+		{TradeStatusFilled, TradeStatusCompleted, TradeActorAcceptor, false},
+		{TradeStatusFilled, TradeStatusClosed, TradeActorSystem, true},
+		{TradeStatusFilled, TradeStatusClosed, TradeActorRequester, false},
+		// 終わった募集からは遷移しない
+		{TradeStatusCompleted, TradeStatusOpen, TradeActorRequester, false},
+		{TradeStatusClosed, TradeStatusOpen, TradeActorRequester, false},
+		{TradeStatusClosed, TradeStatusFilled, TradeActorMember, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to, tt.actor); got != tt.want {
+			t.Errorf("CanTransition(%s, %s, %s) = %t, want %t", tt.from, tt.to, tt.actor, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"shift-change-app/internal/database"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TradeType は募集の種類（shift_trades.trade_type）
+type TradeType string
+
+const (
+	TradeTypeGiveaway TradeType = "GIVEAWAY" // 代わってほしい（一方向）
+	TradeTypeSwap     TradeType = "SWAP"     // 交換（引き受け者は差し出しシフトを作成者に渡す）
+)
+
+// 交換募集で指定できる差し出しシフトの上限
+const maxCounterShifts = 5
+
+// 差し出しシフトの入力
+type counterShiftInput struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+}
+
+// 募集の種類と差し出しシフトの組み合わせを検証する
+func validateCounterShifts(tradeType TradeType, shifts []counterShiftInput, now time.Time) error {
+	switch tradeType {
+	case TradeTypeGiveaway:
+		if len(shifts) > 0 {
+			return errors.New("counter_shifts are only allowed for SWAP trades")
+		}
+	case TradeTypeSwap:
+		if len(shifts) == 0 {
+			return errors.New("SWAP trades need at least one counter shift")
+		}
+		if len(shifts) > maxCounterShifts {
+			return errors.New("too many counter shifts")
+		}
+		for _, s := range shifts {
+			if err := validateShiftRange(s.StartAt, s.EndAt, now); err != nil {
+				return errors.New("counter shift: " + err.Error())
+			}
+		}
+	default:
+		return errors.New("trade_type must be GIVEAWAY or SWAP")
+	}
+	return nil
+}
+
+// 交換募集の作成者がすでに引き受けているシフトと差し出しシフトが重なる
+type swapRequesterConflictError struct {
+	conflicts []database.ListAcceptorConflictsRow
+}
+
+func (e *swapRequesterConflictError) Error() string {
+	return "the counter shifts overlap with shifts the requester has already accepted"
+}
+
+// 引き受け者の行をロックする。交換募集なら作成者も差し出しシフトを引き受けることになるので一緒にロックする
+// 互いの交換募集を同時に引き受けてもデッドロックしないよう、UUID の順にロックする
+func lockTradeParties(ctx context.Context, q *database.Queries, trade database.ShiftTrade, acceptorID uuid.UUID) error {
+	ids := []uuid.UUID{acceptorID}
+	if TradeType(trade.TradeType) == TradeTypeSwap && trade.RequesterID != acceptorID {
+		ids = append(ids, trade.RequesterID)
+		if bytes.Compare(ids[1][:], ids[0][:]) < 0 {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+	}
+	for _, id := range ids {
+		if err := lockAcceptor(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 交換募集の差し出しシフトのうち、作成者がすでに引き受けているシフトと重なるものを返す（交換でなければ空）
+// lockTradeParties で作成者をロックしたトランザクションの q を渡すこと
+func findSwapRequesterConflicts(ctx context.Context, q *database.Queries, trade database.ShiftTrade) ([]database.ListAcceptorConflictsRow, error) {
+	if TradeType(trade.TradeType) != TradeTypeSwap {
+		return nil, nil
+	}
+
+	shifts, err := q.ListCounterShifts(ctx, trade.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []database.ListAcceptorConflictsRow
+	for _, s := range shifts {
+		rows, err := findAcceptorConflicts(ctx, q, trade.RequesterID, trade.ID, s.ShiftStartAt, s.ShiftEndAt)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, rows...)
+	}
+	return conflicts, nil
+}
+
+// 交換が成立したとき、差し出しシフトごとに「お返し」の成立済み募集を作る
+// 元の募集を FILLED にしたのと同じトランザクションの q を渡すこと
+func createSwapReciprocalTrades(ctx context.Context, q *database.Queries, trade database.ShiftTrade, acceptorID uuid.UUID) ([]database.ShiftTrade, error) {
+	if TradeType(trade.TradeType) != TradeTypeSwap {
+		return nil, nil
+	}
+
+	shifts, err := q.ListCounterShifts(ctx, trade.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reciprocals := make([]database.ShiftTrade, 0, len(shifts))
+	for _, s := range shifts {
+		r, err := q.CreateSwapReciprocalTrade(ctx, database.CreateSwapReciprocalTradeParams{
+			GroupID:      trade.GroupID,
+			RequesterID:  acceptorID,
+			AcceptorID:   actorUUID(trade.RequesterID),
+			ShiftStartAt: s.ShiftStartAt,
+			ShiftEndAt:   s.ShiftEndAt,
+			SwapParentID: actorUUID(trade.ID),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := recordTradeTransition(ctx, q, r.ID, "", TradeStatusFilled, actorUUID(acceptorID), tradeReasonSwapReciprocal); err != nil {
+			return nil, err
+		}
+		reciprocals = append(reciprocals, r)
+	}
+	return reciprocals, nil
+}
+
+// 通知文用: 差し出しシフトを1行ずつ並べる
+func formatCounterShiftsJST(shifts []database.ShiftTradeCounterShift) string {
+	lines := make([]string, 0, len(shifts))
+	for _, s := range shifts {
+		lines = append(lines, "・"+formatShiftRangeJST(s.ShiftStartAt, s.ShiftEndAt))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 通知文用: 交換成立時に作られた「お返し」の募集を並べる（交換でなければ空文字）
+func formatSwapNoteJST(heading string, reciprocals []database.ShiftTrade) string {
+	if len(reciprocals) == 0 {
+		return ""
+	}
+	return heading + "\n" + formatReciprocalsJST(reciprocals) + "\n\n"
+}
+
+// 通知文用: 「お返し」の募集を1行ずつ並べる
+func formatReciprocalsJST(reciprocals []database.ShiftTrade) string {
+	lines := make([]string, 0, len(reciprocals))
+	for _, r := range reciprocals {
+		lines = append(lines, "・"+formatShiftRangeJST(r.ShiftStartAt, r.ShiftEndAt))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"shift-change-app/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// 1つのシフトとして募集できる最大の長さ（日をまたぐ連続勤務を防ぐ）
+const maxShiftDuration = 24 * time.Hour
+
+// シフトの時間帯を検証する（開始 < 終了、最大の長さ、開始が未来）
+func validateShiftRange(start, end, now time.Time) error {
+	if start.IsZero() || end.IsZero() {
+		return errors.New("start_at and end_at are required")
+	}
+	if !start.Before(end) {
+		return errors.New("start_at must be before end_at")
+	}
+	if end.Sub(start) > maxShiftDuration {
+		return errors.New("the shift must not be longer than 24 hours")
+	}
+	if !start.After(now) {
+		return errors.New("start_at must be in the future")
+	}
+	return nil
+}
+
+// acceptorID の行をロックして、同じ人の引き受けを直列にする
+// findAcceptorConflicts の前に、引き受けと同じトランザクションで呼ぶ（募集の行より先にロックする）
+// ロックしないと、重なる2つの募集を同時に引き受けたときに両方とも重なりのチェックを通ってしまう
+func lockAcceptor(ctx context.Context, q *database.Queries, acceptorID uuid.UUID) error {
+	return q.LockUserForAcceptance(ctx, acceptorID)
+}
+
+// acceptorID がすでに引き受けている時間帯のうち [start, end) と重なるものを返す
+// excludeTradeID の募集（引き受けようとしている募集自身）は除く
+func findAcceptorConflicts(ctx context.Context, q *database.Queries, acceptorID, excludeTradeID uuid.UUID, start, end time.Time) ([]database.ListAcceptorConflictsRow, error) {
+	return q.ListAcceptorConflicts(ctx, database.ListAcceptorConflictsParams{
+		AcceptorID:     actorUUID(acceptorID),
+		ExcludeTradeID: excludeTradeID,
+		StartAt:        start,
+		EndAt:          end,
+	})
+}
+
+// 重なる募集を 409 で返す
+func respondShiftConflicts(c echo.Context, conflicts []database.ListAcceptorConflictsRow) error {
+	return c.JSON(http.StatusConflict, map[string]interface{}{
+		"error":     "The shift overlaps with shifts you have already accepted",
+		"conflicts": conflicts,
+	})
+}
+
+// 交換募集の作成者側で重なる募集を 409 で返す
+func respondSwapRequesterConflicts(c echo.Context, conflicts []database.ListAcceptorConflictsRow) error {
+	return c.JSON(http.StatusConflict, map[string]interface{}{
+		"error":     "The counter shifts overlap with shifts the requester has already accepted",
+		"conflicts": conflicts,
+	})
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateShiftRange(t *testing.T) {
+	now := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+	start := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		wantErr    bool
+	}{
+		{name: "valid", start: start, end: start.Add(4 * time.Hour)},
+		{name: "exactly 24 hours", start: start, end: start.Add(maxShiftDuration)},
+		{name: "missing start", end: start.Add(time.Hour), wantErr: true},
+		{name: "missing end", start: start, wantErr: true},
+		{name: "end before start", start: start, end: start.Add(-time.Hour), wantErr: true},
+		{name: "empty range", start: start, end: start, wantErr: true},
+		{name: "longer than 24 hours", start: start, end: start.Add(maxShiftDuration + time.Minute), wantErr: true},
+		{name: "starts now", start: now, end: now.Add(time.Hour), wantErr: true},
+		{name: "in the past", start: now.Add(-2 * time.Hour), end: now.Add(-time.Hour), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateShiftRange(tt.start, tt.end, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateShiftRange error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
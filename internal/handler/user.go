@@ -83,8 +83,33 @@ func (h *Handler) WithdrawMe(c echo.Context) error {
 
 	qtx := h.queries.WithTx(tx)
 
+	// owner のグループは最も古くからいる ADMIN に引き継ぐ（自分しかいなければ解散）
+	transferred, blocked, err := releaseOwnedGroups(ctx, qtx, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to release owned groups"})
+	}
+	if len(blocked) > 0 {
+		groups := make([]map[string]string, 0, len(blocked))
+		for _, g := range blocked {
+			groups = append(groups, map[string]string{"id": g.ID.String(), "name": g.Name})
+		}
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":  "Transfer ownership of these groups before withdrawing",
+			"groups": groups,
+		})
+	}
+
+	// 引き継いだグループの新しい owner に知らせる（devバイパス時は送らない）
+	if shouldNotify(c) {
+		for _, g := range transferred {
+			if err := notifyNewOwner(ctx, qtx, g); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to enqueue notification"})
+			}
+		}
+	}
+
 	// 退会ユーザーの OPEN 募集を全部 CLOSED にする
-	_, err = qtx.CloseOpenShiftTradesByRequester(ctx, user.ID)
+	_, err = closeOpenTradesByRequester(ctx, qtx, user.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to close open trades"})
 	}
@@ -106,5 +131,7 @@ func (h *Handler) WithdrawMe(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to commit tx"})
 	}
 
+	h.sessions.ClearCookie(c)
+
 	return c.NoContent(http.StatusOK)
 }
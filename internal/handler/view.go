@@ -1,16 +1,31 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"os"
+	"shift-change-app/internal/database"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 func (h *Handler) ShowHomeEntry(c echo.Context) error {
+	next := c.QueryParam("next")
+	if next == "" {
+		// LIFF URL（https://liff.line.me/{LIFF_ID}/groups/...）から開いた場合は liff.state に遷移先が入る
+		next = c.QueryParam("liff.state")
+	}
+	return h.showHomeEntryWithNext(c, next)
+}
+
+// 入口画面（LIFF ログイン → /api/me でセッション発行 → next へ遷移）
+func (h *Handler) showHomeEntryWithNext(c echo.Context, next string) error {
 	data := map[string]interface{}{
 		"LiffID": os.Getenv("LIFF_ID"),
+		"Next":   safeNextPath(next),
 	}
 	return c.Render(http.StatusOK, "home_entry.html", data)
 }
@@ -18,14 +33,10 @@ func (h *Handler) ShowHomeEntry(c echo.Context) error {
 // ホーム画面 (グループ選択)
 func (h *Handler) ShowHome(c echo.Context) error {
 	ctx := c.Request().Context()
-	userIDStr := c.QueryParam("user_id")
 
-	if userIDStr == "" {
-		return c.String(http.StatusBadRequest, "user_id is required")
-	}
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		return c.String(http.StatusBadRequest, "Invalid user_id")
+	userID, ok := ViewUserID(c)
+	if !ok {
+		return h.ShowHomeEntry(c)
 	}
 
 	user, err := h.queries.GetUserByID(ctx, userID)
@@ -41,7 +52,7 @@ func (h *Handler) ShowHome(c echo.Context) error {
 
 	data := map[string]interface{}{
 		"User":          user,
-		"CurrentUserID": userIDStr,
+		"CurrentUserID": userID.String(),
 		"UserGroups":    groups,
 		"LiffID":        os.Getenv("LIFF_ID"),
 	}
@@ -53,17 +64,16 @@ func (h *Handler) ShowGroupBoard(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	groupIDStr := c.Param("group_id")
-	userIDStr := c.QueryParam("user_id")
-
-	if userIDStr == "" {
-		return c.String(http.StatusBadRequest, "user_id is required")
-	}
-	userID, _ := uuid.Parse(userIDStr)
 	groupID, err := uuid.Parse(groupIDStr)
 	if err != nil {
 		return c.String(http.StatusBadRequest, "Invalid group_id")
 	}
 
+	userID, ok := ViewUserID(c)
+	if !ok {
+		return h.ShowHomeEntry(c)
+	}
+
 	// 各種データ取得
 	user, err := h.queries.GetUserByID(ctx, userID)
 	if err != nil {
@@ -75,22 +85,58 @@ func (h *Handler) ShowGroupBoard(c echo.Context) error {
 		return c.String(http.StatusNotFound, "Group error")
 	}
 
-	trades, err := h.queries.ListOpenShiftTrades(ctx, groupID)
+	// 所属チェック
+	isMember, err := h.isGroupMember(ctx, groupID, userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to check membership")
+	}
+	if !isMember {
+		return c.String(http.StatusForbidden, "You are not a member of this group")
+	}
+
+	isAdmin, err := h.isGroupAdmin(ctx, groupID, userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to check role")
+	}
+
+	trades, err := h.listOpenTradeViews(ctx, groupID)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, "Trade error")
 	}
 
 	myTrades, err := h.queries.ListUserTrades(ctx, userID)
 
+	members, err := h.queries.ListGroupMembers(ctx, groupID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Member error")
+	}
+
 	data := map[string]interface{}{
 		"User":          user,
 		"Group":         group,
-		"CurrentUserID": userIDStr,
+		"CurrentUserID": userID.String(),
 		"GroupID":       groupIDStr,
+		"IsAdmin":       isAdmin,
 		"Trades":        trades,
 		"MyTrades":      myTrades,
+		"Members":       members,
 		"LiffID":        os.Getenv("LIFF_ID"),
 	}
 
 	return c.Render(http.StatusOK, "board.html", data) // board.html を表示
 }
+
+// グループに所属しているか
+func (h *Handler) isGroupMember(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	_, err := h.queries.GetGroupMember(ctx, database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -1,18 +1,31 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"shift-change-app/internal/database"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/line/line-bot-sdk-go/v7/linebot"
 )
 
+// Webhook イベント1件の処理にかける時間の上限（これを過ぎて同じイベントが届いたら処理し直す）
+const webhookEventTimeout = 30 * time.Second
+
+// Webhook は署名を検証してすぐに 200 を返し、イベントはバックグラウンドで処理する
+// DB が遅くても LINE への応答を待たせない。200 を返したあとに処理が失敗しても LINE からは再送されない
+// 同時に処理する配信は maxConcurrentWebhookBatches まで。あふれたら 503 を返して受け取らない（LINE の再送設定が有効なら再送される）
+// 1回の配信に含まれるイベントは届いた順に1件ずつ処理する（unfollow → follow などの順序を保つ）
 func (h *Handler) Webhook(c echo.Context) error {
 	req := c.Request()
 
-	events, err := h.bot.ParseRequest(req)
+	events, err := linebot.ParseRequest(h.channelSecret, req)
 	if err != nil {
 		// 署名不正など。
 		c.Logger().Errorf("ParseRequest error: %v", err)
@@ -22,60 +35,245 @@ func (h *Handler) Webhook(c echo.Context) error {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	registerURL := os.Getenv("REGISTER_URL")
-	if registerURL == "" {
-		c.Logger().Warn("REGISTER_URL is not set")
+	if len(events) == 0 {
+		return c.NoContent(http.StatusOK)
+	}
+
+	// goroutine を起動する前に枠を取る（処理だけでなく goroutine の数も抑える）
+	select {
+	case h.webhookSem <- struct{}{}:
+	default:
+		c.Logger().Warnf("[webhook] too many deliveries in progress, drop %d event(s)", len(events))
+		return c.NoContent(http.StatusServiceUnavailable)
 	}
 
-	for _, event := range events {
-		userID := ""
-		if event.Source != nil {
-			userID = event.Source.UserID
+	h.webhookWG.Add(1)
+	go func() {
+		defer h.webhookWG.Done()
+		defer func() { <-h.webhookSem }()
+
+		for _, event := range events {
+			h.processWebhookEventWithTimeout(event)
 		}
-		if userID == "" {
-			continue
+	}()
+
+	return c.NoContent(http.StatusOK)
+}
+
+// WaitWebhooks は処理中の Webhook イベントが全て終わるか ctx が終わるまで待つ
+func (h *Handler) WaitWebhooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.webhookWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Handler) processWebhookEventWithTimeout(event *linebot.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookEventTimeout)
+	defer cancel()
+	h.processWebhookEvent(ctx, event)
+}
+
+// webhookEventId で重複を除いてからイベントを処理する
+// 処理済み・処理中のイベントがもう一度届いたら何もしない
+// 失敗したイベントは処理済みにしないので、同じイベントがもう一度届けば（LINE が応答を待ちきれずに再送したときなど）処理し直すが、
+// 200 を返したあとの失敗を LINE が再送することはないので、その場合はログに残るだけ
+func (h *Handler) processWebhookEvent(ctx context.Context, event *linebot.Event) {
+	if event.WebhookEventID != "" {
+		claimed, err := h.queries.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+			WebhookEventID: event.WebhookEventID,
+			EventType:      string(event.Type),
+			IsRedelivery:   event.DeliveryContext.IsRedelivery,
+			LockedUntil:    time.Now().Add(webhookEventTimeout),
+		})
+		if err != nil {
+			log.Printf("[webhook] failed to claim event %s: %v", event.WebhookEventID, err)
+			return
 		}
+		if claimed == 0 {
+			log.Printf("[webhook] skip duplicate event %s (redelivery=%t)", event.WebhookEventID, event.DeliveryContext.IsRedelivery)
+			return
+		}
+	}
 
-		// 既に登録済みかを判定（
-		_, user := h.queries.GetUserByLineID(req.Context(), userID)
-		registered := true
-		if user != nil {
-			if user == sql.ErrNoRows {
-				registered = false
-			} else {
-				// DBエラー等。とりあえずログだけ出して次へ
-				c.Logger().Errorf("GetUserByLineID error: %v", user)
-				continue
+	if err := h.handleWebhookEvent(ctx, event); err != nil {
+		log.Printf("[webhook] failed to handle event %s: %v", event.WebhookEventID, err)
+		if event.WebhookEventID != "" {
+			if err := h.queries.ReleaseWebhookEvent(context.Background(), event.WebhookEventID); err != nil {
+				log.Printf("[webhook] failed to release event %s: %v", event.WebhookEventID, err)
 			}
 		}
+		return
+	}
 
-		// 未登録のときだけ案内を返す
-		if !registered {
-			switch event.Type {
-			case linebot.EventTypeFollow:
-				// 友だち追加直後
-				msg := linebot.NewTextMessage(
-					"友だち追加ありがとうございます！🙇\n\n" +
-						"シフト管理アプリへようこそ。\n" +
-						"まずは以下から利用登録を完了させてください！\n" +
-						registerURL,
-				)
-				if _, err := h.bot.ReplyMessage(event.ReplyToken, msg).Do(); err != nil {
-					c.Logger().Error(err)
-				}
-
-			case linebot.EventTypeMessage:
-				// ブロック解除後など、ユーザーが何か送ってきたタイミングで案内
-				msg := linebot.NewTextMessage(
-					"利用には登録が必要です！\nこちらから登録してください👇\n" + registerURL,
-				)
-				if _, err := h.bot.ReplyMessage(event.ReplyToken, msg).Do(); err != nil {
-					c.Logger().Error(err)
-				}
-			}
+	if event.WebhookEventID != "" {
+		if err := h.queries.MarkWebhookEventProcessed(context.Background(), event.WebhookEventID); err != nil {
+			log.Printf("[webhook] failed to mark event %s processed: %v", event.WebhookEventID, err)
 		}
+	}
+}
 
+// イベントの種類と登録状況ごとに振り分ける
+// エラーを返すとイベントを処理済みにしない（同じイベントがもう一度届いたときに処理し直す）
+func (h *Handler) handleWebhookEvent(ctx context.Context, event *linebot.Event) error {
+	// トークでのイベント（募集カードのボタン以外）は連携用に処理する
+	if chatID, ok := eventChatID(event.Source); ok && event.Type != linebot.EventTypePostback {
+		return h.handleChatEvent(ctx, event, chatID)
 	}
 
-	return c.NoContent(http.StatusOK)
+	userID := ""
+	if event.Source != nil {
+		userID = event.Source.UserID
+	}
+	if userID == "" {
+		return nil
+	}
+
+	// ブロックされたら以降の通知を止める（reply token はないので返信しない）
+	if event.Type == linebot.EventTypeUnfollow {
+		return h.setBotReachable(ctx, userID, false)
+	}
+
+	// 既に登録済みかを判定
+	user, err := h.queries.GetUserByLineID(ctx, userID)
+	registered := true
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		registered = false
+	}
+
+	// 登録済みユーザーのブロック解除（友だち再追加）で通知を再開する
+	if registered && event.Type == linebot.EventTypeFollow {
+		if err := h.setBotReachable(ctx, userID, true); err != nil {
+			return err
+		}
+		h.replyEvent(ctx, event, linebot.NewTextMessage(
+			"おかえりなさい！🙌\n\nシフト募集などの通知を再開します。\n「ヘルプ」と送ると使えるコマンドを表示します",
+		))
+		return nil
+	}
+
+	// 登録済みユーザーのボタン操作（postback）
+	if registered && event.Type == linebot.EventTypePostback && event.Postback != nil {
+		h.handlePostback(ctx, event, user)
+		return nil
+	}
+
+	// 登録済みユーザーからのテキストはコマンドとして処理する
+	if registered && event.Type == linebot.EventTypeMessage {
+		if text, ok := event.Message.(*linebot.TextMessage); ok {
+			h.handleTextCommand(ctx, event, user, text.Text)
+		}
+		return nil
+	}
+
+	// 未登録のときだけ案内を返す
+	if !registered {
+		registerURL := os.Getenv("REGISTER_URL")
+		if registerURL == "" {
+			log.Println("[webhook] REGISTER_URL is not set")
+		}
+
+		switch event.Type {
+		case linebot.EventTypeFollow:
+			// 友だち追加直後
+			msg := linebot.NewTextMessage(
+				"友だち追加ありがとうございます！🙇\n\n" +
+					"シフト管理アプリへようこそ。\n" +
+					"まずは以下から利用登録を完了させてください！\n" +
+					registerURL,
+			)
+			h.replyEvent(ctx, event, msg)
+
+		case linebot.EventTypeMessage:
+			// ブロック解除後など、ユーザーが何か送ってきたタイミングで案内
+			msg := linebot.NewTextMessage(
+				"利用には登録が必要です！\nこちらから登録してください👇\n" + registerURL,
+			)
+			h.replyEvent(ctx, event, msg)
+		}
+	}
+	return nil
+}
+
+// follow / unfollow に合わせて通知を送るかを記録する
+func (h *Handler) setBotReachable(ctx context.Context, lineUserID string, reachable bool) error {
+	updated, err := h.queries.SetUserBotReachableByLineID(ctx, database.SetUserBotReachableByLineIDParams{
+		BotReachable: reachable,
+		LineUserID:   lineUserID,
+	})
+	if err != nil {
+		return err
+	}
+	if updated > 0 {
+		log.Printf("[webhook] user %s bot_reachable=%t", lineUserID, reachable)
+	}
+	return nil
+}
+
+// イベントに返信する
+// 再送されたイベントは reply token が失効していることがあるので、outbox から送信元（ユーザーまたはトーク）に push で送る
+func (h *Handler) replyEvent(ctx context.Context, event *linebot.Event, messages ...linebot.SendingMessage) {
+	if event.DeliveryContext.IsRedelivery && event.Source != nil {
+		to := event.Source.UserID
+		if chatID, ok := eventChatID(event.Source); ok {
+			to = chatID
+		}
+		if err := enqueueChatOrUserPush(ctx, h.queries, to, messages...); err != nil {
+			log.Println("[webhook] failed to enqueue reply for redelivered event:", err)
+		}
+		return
+	}
+	if err := h.notifier.Reply(ctx, event.ReplyToken, messages...); err != nil {
+		log.Println("[webhook] failed to reply:", err)
+	}
+}
+
+// コマンドの結果を返信する
+func (h *Handler) handleTextCommand(ctx context.Context, event *linebot.Event, user database.User, text string) {
+	reply, err := h.handleChatCommand(ctx, user, text)
+	if err != nil {
+		log.Printf("[webhook] chat command %q error: %v", text, err)
+		reply = "処理に失敗しました。時間をおいてもう一度お試しください"
+	}
+
+	h.replyEvent(ctx, event, linebot.NewTextMessage(reply))
+}
+
+// postback を data の action ごとに振り分け、結果を返信する
+func (h *Handler) handlePostback(ctx context.Context, event *linebot.Event, user database.User) {
+	data, err := url.ParseQuery(event.Postback.Data)
+	if err != nil {
+		log.Printf("[webhook] invalid postback data: %q", event.Postback.Data)
+		return
+	}
+
+	var reply string
+	switch data.Get("action") {
+	case postbackActionApproveJoin, postbackActionRejectJoin:
+		reply, err = h.handleJoinPostback(ctx, user, data)
+	case postbackActionAcceptTrade:
+		reply, err = h.handleTradePostback(ctx, user, data)
+	default:
+		log.Printf("[webhook] unknown postback action: %q", data.Get("action"))
+		return
+	}
+	if err != nil {
+		log.Printf("[webhook] postback %q error: %v", data.Get("action"), err)
+		reply = "処理に失敗しました。時間をおいてもう一度お試しください"
+	}
+	if reply == "" {
+		return
+	}
+
+	h.replyEvent(ctx, event, linebot.NewTextMessage(reply))
 }
@@ -0,0 +1,227 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/handler"
+	"shift-change-app/internal/router"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const testChannelSecret = "test-channel-secret"
+
+// 未登録ユーザーの友だち追加には、Webhook の返信（reply）で登録の案内を送る
+// DB はスタブ（ユーザーは見つからない）なので、Postgres が無くても動く
+func TestWebhookFollowFromUnregisteredUserReplies(t *testing.T) {
+	t.Setenv("REGISTER_URL", "https://example.com/register")
+	e, h, recorder, db := newOfflineWebhookServer(t)
+
+	lineID := newLineUserID(t)
+	rec := postWebhook(t, e, webhookEvent("follow", lineID, "reply-token-1", false))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	waitWebhooks(t, h)
+
+	sent := recorder.Sent()
+	if len(sent) != 1 || sent[0].Kind != handler.SentReply || sent[0].ReplyToken != "reply-token-1" {
+		t.Fatalf("sent = %+v, want one reply to reply-token-1", sent)
+	}
+	assertMessageContains(t, sent[0], "利用登録", "https://example.com/register")
+	if db.executed("notification_outbox", "") {
+		t.Error("a reply should not go through the outbox")
+	}
+}
+
+// 再送されたイベントは reply token が失効していることがあるので、返信ではなく outbox に push を積む
+func TestWebhookRedeliveredEventGoesThroughOutbox(t *testing.T) {
+	t.Setenv("REGISTER_URL", "https://example.com/register")
+	e, h, recorder, db := newOfflineWebhookServer(t)
+
+	lineID := newLineUserID(t)
+	rec := postWebhook(t, e, webhookEvent("follow", lineID, "reply-token-2", true))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	waitWebhooks(t, h)
+
+	if sent := recorder.Sent(); len(sent) != 0 {
+		t.Fatalf("sent = %+v, want nothing sent directly", sent)
+	}
+	if !db.executed("INSERT INTO notification_outbox", lineID) {
+		t.Error("the reply to a redelivered event should be enqueued to notification_outbox for the sender")
+	}
+}
+
+// 署名が合わなければ 400 を返し、何も送らない
+func TestWebhookRejectsInvalidSignature(t *testing.T) {
+	e, h, recorder, _ := newOfflineWebhookServer(t)
+
+	body := webhookEvent("follow", newLineUserID(t), "reply-token-3", false)
+	req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewReader(body))
+	req.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString([]byte("wrong")))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	waitWebhooks(t, h)
+	if sent := recorder.Sent(); len(sent) != 0 {
+		t.Fatalf("sent = %+v, want nothing", sent)
+	}
+}
+
+func newOfflineWebhookServer(t *testing.T) (*echo.Echo, *handler.Handler, *handler.RecordingNotifier, *stubDB) {
+	t.Helper()
+	stub := &stubDB{}
+	db := sql.OpenDB(stub)
+	t.Cleanup(func() { db.Close() })
+
+	recorder := handler.NewRecordingNotifier()
+	h := handler.NewHandler(db, database.New(db), recorder, testChannelSecret, handler.NewSessionSigner([]byte("test"), time.Hour, false))
+	e := echo.New()
+	router.SetupRoutes(e, h, subVerifier{})
+	return e, h, recorder, stub
+}
+
+// webhookEventId を付けない1件のイベント（重複除去の記録を通さない）
+func webhookEvent(eventType, lineUserID, replyToken string, redelivery bool) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"destination": "Ubot",
+		"events": []map[string]any{{
+			"type":            eventType,
+			"mode":            "active",
+			"timestamp":       time.Now().UnixMilli(),
+			"replyToken":      replyToken,
+			"source":          map[string]string{"type": "user", "userId": lineUserID},
+			"deliveryContext": map[string]bool{"isRedelivery": redelivery},
+		}},
+	})
+	return body
+}
+
+func postWebhook(t *testing.T, e *echo.Echo, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	mac := hmac.New(sha256.New, []byte(testChannelSecret))
+	mac.Write(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewReader(body))
+	req.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func waitWebhooks(t *testing.T, h *handler.Handler) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.WaitWebhooks(ctx); err != nil {
+		t.Fatalf("webhook events were not processed: %v", err)
+	}
+}
+
+// ---- database/sql のスタブ（実行した SQL と引数を記録する） ----
+// outbox への INSERT には発行した id を1行返し、それ以外のクエリは0行を返す
+
+type stubDB struct {
+	mu      sync.Mutex
+	queries []stubQuery
+}
+
+type stubQuery struct {
+	query string
+	args  string
+}
+
+func (d *stubDB) Connect(context.Context) (driver.Conn, error) { return stubConn{d}, nil }
+func (d *stubDB) Driver() driver.Driver                        { return stubDriver{d} }
+
+func (d *stubDB) record(query string, args []driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, stubQuery{query: query, args: fmt.Sprint(args)})
+}
+
+// executed は substr を含む SQL が、arg を含む引数で実行されたかを返す
+func (d *stubDB) executed(substr, arg string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, q := range d.queries {
+		if strings.Contains(q.query, substr) && strings.Contains(q.args, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+type stubDriver struct{ d *stubDB }
+
+func (s stubDriver) Open(string) (driver.Conn, error) { return stubConn(s), nil }
+
+type stubConn struct{ d *stubDB }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.d, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct {
+	d     *stubDB
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return -1 }
+
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.record(s.query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (s stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.record(s.query, args)
+	if strings.Contains(s.query, "INSERT INTO notification_outbox") {
+		return &stubRows{rows: [][]driver.Value{{uuid.NewString()}}}, nil
+	}
+	return &stubRows{}, nil
+}
+
+type stubRows struct {
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return []string{"id"} }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
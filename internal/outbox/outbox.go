@@ -0,0 +1,97 @@
+// Package outbox は LINE 通知を Postgres の notification_outbox に積み、ワーカーが送る仕組み
+// ハンドラは状態の変更と同じトランザクションで Push / Multicast を呼ぶだけにして、
+// 送信・再送・DEAD への振り分けは Worker が行う
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"shift-change-app/internal/database"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// 送信方法（notification_outbox.kind）
+const (
+	KindPush      = "PUSH"
+	KindMulticast = "MULTICAST"
+)
+
+// 通知の状態（notification_outbox.status）
+const (
+	StatusPending = "PENDING"
+	StatusSent    = "SENT"
+	StatusDead    = "DEAD"
+)
+
+// Multicast 1回で送れる宛先の上限（LINE の仕様）
+const maxMulticastRecipients = 500
+
+// Push は1ユーザーへの通知を積む（to が空なら何もしない）
+func Push(ctx context.Context, q *database.Queries, to string, messages ...linebot.SendingMessage) error {
+	if to == "" {
+		return nil
+	}
+	return enqueue(ctx, q, KindPush, []string{to}, messages)
+}
+
+// Multicast は複数ユーザーへの通知を積む（上限を超える宛先は分けて積む）
+func Multicast(ctx context.Context, q *database.Queries, to []string, messages ...linebot.SendingMessage) error {
+	for len(to) > 0 {
+		n := len(to)
+		if n > maxMulticastRecipients {
+			n = maxMulticastRecipients
+		}
+		if err := enqueue(ctx, q, KindMulticast, to[:n], messages); err != nil {
+			return err
+		}
+		to = to[n:]
+	}
+	return nil
+}
+
+func enqueue(ctx context.Context, q *database.Queries, kind string, to []string, messages []linebot.SendingMessage) error {
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	_, err = q.EnqueueNotification(ctx, database.EnqueueNotificationParams{
+		Kind:       kind,
+		Recipients: to,
+		Messages:   body,
+	})
+	return err
+}
+
+// 積んだときの JSON をそのまま送るメッセージ
+type rawMessage json.RawMessage
+
+func (m rawMessage) MarshalJSON() ([]byte, error) { return m, nil }
+
+func (rawMessage) Message() {}
+
+func (m rawMessage) Type() linebot.MessageType {
+	var v struct {
+		Type linebot.MessageType `json:"type"`
+	}
+	_ = json.Unmarshal(m, &v)
+	return v.Type
+}
+
+func (m rawMessage) WithQuickReplies(*linebot.QuickReplyItems) linebot.SendingMessage { return m }
+
+func (m rawMessage) WithSender(*linebot.Sender) linebot.SendingMessage { return m }
+
+func (m rawMessage) AddEmoji(*linebot.Emoji) linebot.SendingMessage { return m }
+
+func decodeMessages(body json.RawMessage) ([]linebot.SendingMessage, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+	messages := make([]linebot.SendingMessage, 0, len(items))
+	for _, item := range items {
+		messages = append(messages, rawMessage(item))
+	}
+	return messages, nil
+}
@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+)
+
+type retryKeyContextKey struct{}
+
+// WithRetryKey は LINE API に X-Line-Retry-Key として送るキーを ctx に入れる
+// Worker は通知ごとに固定のキー（notification_outbox.retry_key）を入れて送るので、
+// タイムアウトや 5xx のあとに送り直しても LINE 側で同じ通知が二重に届かない
+func WithRetryKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, retryKeyContextKey{}, key)
+}
+
+// RetryKeyFromContext は WithRetryKey で入れたキーを返す
+func RetryKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(retryKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+// NewHTTPClient は linebot.WithHTTPClient に渡す http.Client を返す
+// リクエストの ctx に WithRetryKey のキーがあれば X-Line-Retry-Key ヘッダーを付ける
+// （linebot の WithRetryKey はクライアント全体に残るため、並行して送る Worker では使えない）
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: retryKeyTransport{base: http.DefaultTransport}}
+}
+
+type retryKeyTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, ok := RetryKeyFromContext(req.Context())
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Line-Retry-Key", key)
+	return t.base.RoundTrip(req)
+}
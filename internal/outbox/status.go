@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	"context"
+	"shift-change-app/internal/database"
+)
+
+// StatusReport は outbox の状態（状態ごとの件数と最近 DEAD になった通知の ID など。宛先は含まない）
+type StatusReport struct {
+	Counts map[string]int64                    `json:"counts"`
+	Dead   []database.ListDeadNotificationsRow `json:"dead"`
+}
+
+// Status は状態ごとの件数と、新しい順に deadLimit 件の DEAD の通知を返す
+func Status(ctx context.Context, q *database.Queries, deadLimit int32) (StatusReport, error) {
+	rows, err := q.CountNotificationsByStatus(ctx)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	counts := map[string]int64{StatusPending: 0, StatusSent: 0, StatusDead: 0}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+
+	dead, err := q.ListDeadNotifications(ctx, deadLimit)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	return StatusReport{Counts: counts, Dead: dead}, nil
+}
@@ -0,0 +1,289 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"log"
+	"math/rand"
+	"os"
+	"shift-change-app/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// 送信結果のメトリクス（METRICS_ENABLED=1 のとき /debug/vars で見られる）
+var (
+	outboxSent    = expvar.NewInt("outbox_sent")
+	outboxRetried = expvar.NewInt("outbox_retried")
+	outboxDead    = expvar.NewInt("outbox_dead")
+)
+
+// Worker の既定値
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 20
+	defaultLease        = time.Minute
+	defaultBaseBackoff  = 10 * time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultRetention    = 7 * 24 * time.Hour
+	cleanupInterval     = time.Hour
+)
+
+// Sender は Worker が実際に送るときに使う送信先（handler.Notifier を満たす）
+type Sender interface {
+	Push(ctx context.Context, to string, messages ...linebot.SendingMessage) error
+	Multicast(ctx context.Context, to []string, messages ...linebot.SendingMessage) error
+}
+
+// Worker は notification_outbox から送信時期が来た通知を取り出して送る
+// 失敗したら指数バックオフで再送し、再送できないエラーや max_attempts を超えたものは DEAD にする
+// 複数台で動かしても FOR UPDATE SKIP LOCKED で同じ通知を取り合わない
+type Worker struct {
+	queries *database.Queries
+	sender  Sender
+
+	PollInterval time.Duration // 送信待ちを確認する間隔
+	BatchSize    int32         // 1回に取り出す件数
+	Lease        time.Duration // 取り出した通知を確保しておく時間（送信中に落ちたら期限後に再送される）
+	BaseBackoff  time.Duration // 1回目の再送までの待ち時間（以降は倍々）
+	MaxBackoff   time.Duration // 再送までの待ち時間の上限
+	Retention    time.Duration // 送信済みの通知を残しておく期間
+}
+
+// NewWorker は既定値で Worker を作る（OUTBOX_POLL_INTERVAL で確認間隔を変えられる）
+func NewWorker(queries *database.Queries, sender Sender) *Worker {
+	w := &Worker{
+		queries:      queries,
+		sender:       sender,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Lease:        defaultLease,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		Retention:    defaultRetention,
+	}
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			w.PollInterval = d
+		} else {
+			log.Printf("[WARN] invalid OUTBOX_POLL_INTERVAL %q; using %s", v, defaultPollInterval)
+		}
+	}
+	return w
+}
+
+// Run は ctx が終わるまで送信待ちの通知を送り続ける
+// ctx が終わると送信中の通知を送り終えてから戻る
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// 取り出せる限り続けて送る
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			n, err := w.ProcessBatch(ctx)
+			if err != nil {
+				log.Println("[outbox] failed to process batch:", err)
+				break
+			}
+			if n < int(w.BatchSize) {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) >= cleanupInterval {
+			w.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch は送信時期が来た通知を最大 BatchSize 件送り、取り出した件数を返す
+func (w *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	batch, err := w.queries.ClaimDueNotifications(ctx, database.ClaimDueNotificationsParams{
+		LockedUntil: sql.NullTime{Time: time.Now().Add(w.Lease), Valid: true},
+		BatchSize:   w.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, n := range batch {
+		if ctx.Err() != nil {
+			// 停止中なら残りはすぐに他のワーカーが送れるよう戻す
+			w.release(batch[i:])
+			break
+		}
+		// 送信中に止められても結果を記録できるよう、キャンセルは伝えない
+		w.deliver(context.WithoutCancel(ctx), n)
+	}
+	return len(batch), nil
+}
+
+func (w *Worker) release(batch []database.NotificationOutbox) {
+	ids := make([]uuid.UUID, 0, len(batch))
+	for _, n := range batch {
+		ids = append(ids, n.ID)
+	}
+	if err := w.queries.ReleaseNotificationClaims(context.Background(), ids); err != nil {
+		log.Println("[outbox] failed to release claimed notifications:", err)
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, n database.NotificationOutbox) {
+	err := w.send(ctx, n)
+	if isRetryKeyAccepted(err) {
+		// 前回の送信が LINE に届いていた（応答を受け取れなかっただけ）
+		log.Printf("[outbox] notification %s was already accepted by LINE", n.ID)
+		err = nil
+	}
+	if err == nil {
+		if err := w.queries.MarkNotificationSent(ctx, n.ID); err != nil {
+			log.Println("[outbox] failed to mark sent:", err)
+		}
+		outboxSent.Add(1)
+		return
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	if !isRetryable(err) || n.Attempts >= n.MaxAttempts {
+		if err := w.queries.MarkNotificationDead(ctx, database.MarkNotificationDeadParams{
+			ID:        n.ID,
+			LastError: lastError,
+		}); err != nil {
+			log.Println("[outbox] failed to mark dead:", err)
+		}
+		outboxDead.Add(1)
+		log.Printf("[outbox] notification %s is dead after %d attempt(s): %v", n.ID, n.Attempts, err)
+		if n.Kind == KindPush && len(n.Recipients) == 1 && isUnreachable(err) {
+			w.markUnreachable(ctx, n.Recipients[0])
+		}
+		return
+	}
+
+	next := time.Now().Add(w.backoff(n.Attempts))
+	if err := w.queries.ScheduleNotificationRetry(ctx, database.ScheduleNotificationRetryParams{
+		ID:            n.ID,
+		NextAttemptAt: next,
+		LastError:     lastError,
+	}); err != nil {
+		log.Println("[outbox] failed to schedule retry:", err)
+	}
+	outboxRetried.Add(1)
+	log.Printf("[outbox] notification %s failed (attempt %d), retry at %s: %v", n.ID, n.Attempts, next.Format(time.RFC3339), err)
+}
+
+func (w *Worker) send(ctx context.Context, n database.NotificationOutbox) error {
+	messages, err := decodeMessages(n.Messages)
+	if err != nil {
+		return permanentError{err}
+	}
+	// 送り直しでも毎回同じキーを付ける
+	ctx = WithRetryKey(ctx, n.RetryKey.String())
+
+	switch n.Kind {
+	case KindPush:
+		if len(n.Recipients) != 1 {
+			return permanentError{errors.New("push needs exactly one recipient")}
+		}
+		return w.sender.Push(ctx, n.Recipients[0], messages...)
+	case KindMulticast:
+		return w.sender.Multicast(ctx, n.Recipients, messages...)
+	default:
+		return permanentError{errors.New("unknown kind: " + n.Kind)}
+	}
+}
+
+// attempts 回目の失敗のあとに待つ時間（BaseBackoff * 2^(attempts-1)、上限 MaxBackoff、±20% のゆらぎ付き）
+func (w *Worker) backoff(attempts int32) time.Duration {
+	d := w.BaseBackoff
+	for i := int32(1); i < attempts && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5*2+1)) - d/5
+	return d + jitter
+}
+
+func (w *Worker) cleanup(ctx context.Context) {
+	deleted, err := w.queries.DeleteSentNotificationsBefore(ctx, sql.NullTime{Time: time.Now().Add(-w.Retention), Valid: true})
+	if err != nil {
+		log.Println("[outbox] failed to clean up sent notifications:", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("[outbox] deleted %d sent notification(s)", deleted)
+	}
+}
+
+// 宛先に届かない push の失敗が続いたユーザーは、以降の通知から外す（follow イベントで戻る）
+func (w *Worker) markUnreachable(ctx context.Context, lineUserID string) {
+	updated, err := w.queries.SetUserBotReachableByLineID(ctx, database.SetUserBotReachableByLineIDParams{
+		BotReachable: false,
+		LineUserID:   lineUserID,
+	})
+	if err != nil {
+		log.Println("[outbox] failed to mark user unreachable:", err)
+		return
+	}
+	if updated > 0 {
+		log.Printf("[outbox] marked %s unreachable", lineUserID)
+	}
+}
+
+// 送り直しても成功しないエラー（壊れた行など）
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// 再送すれば成功しうるか
+// LINE API の 429（レート制限）と 5xx、通信エラーは再送する。それ以外の 4xx は送り直しても失敗する
+func isRetryable(err error) bool {
+	var perm permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	var apiErr *linebot.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 429 || apiErr.Code >= 500
+	}
+	return true
+}
+
+// 宛先のユーザーに届かないエラーか
+// LINE API は友だちでない・存在しないユーザーへの push を 403 / 404 で返す
+func isUnreachable(err error) bool {
+	var apiErr *linebot.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 403 || apiErr.Code == 404
+	}
+	return false
+}
+
+// 同じ X-Line-Retry-Key の送信がすでに受け付けられているか
+// LINE API は受け付け済みのキーで送り直すと 409 を返す（メッセージは送られない）
+func isRetryKeyAccepted(err error) bool {
+	var apiErr *linebot.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 409
+	}
+	return false
+}
@@ -1,12 +1,15 @@
 package router
 
 import (
+	"expvar"
+	"os"
 	"shift-change-app/internal/handler"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
-func SetupRoutes(e *echo.Echo, h *handler.Handler) {
+func SetupRoutes(e *echo.Echo, h *handler.Handler, verifier handler.TokenVerifier) {
 
 	api := e.Group("/api")
 
@@ -15,7 +18,7 @@ func SetupRoutes(e *echo.Echo, h *handler.Handler) {
 
 	// 認証が必要なAPI（Authorization: Bearer <token>）
 	authed := api.Group("")
-	authed.Use(handler.AuthMiddleware())
+	authed.Use(handler.AuthMiddleware(verifier))
 	{
 		authed.POST("/users", h.RegisterUser)
 		authed.POST("/groups", h.CreateGroup)
@@ -23,16 +26,39 @@ func SetupRoutes(e *echo.Echo, h *handler.Handler) {
 		authed.POST("/me", h.Me)
 		authed.DELETE("/me", h.WithdrawMe)
 
-		// グループ管理（ownerのみ）
-		authed.PUT("/groups/:group_id", h.UpdateGroupName)
+		// グループ管理（ADMINのみ。解散・オーナーの引き継ぎは ownerのみ）
+		adminOnly := h.RequireGroupRole(handler.GroupRoleAdmin)
+		authed.PUT("/groups/:group_id", h.UpdateGroupName, adminOnly)
 		authed.DELETE("/groups/:group_id", h.DissolveGroup)
+		authed.POST("/groups/:group_id/transfer-ownership", h.TransferOwnership)
+		authed.PUT("/groups/:group_id/settings", h.UpdateGroupSettings, adminOnly)
+		authed.POST("/groups/:group_id/invitation/rotate", h.RotateInvitationCode, adminOnly)
+
+		// メンバー管理
+		authed.GET("/groups/:group_id/members", h.ListGroupMembers)
+		authed.PUT("/groups/:group_id/members/:user_id/role", h.UpdateMemberRole, adminOnly)
+		authed.DELETE("/groups/:group_id/members/:user_id", h.RemoveGroupMember, adminOnly)
+		authed.POST("/groups/:group_id/leave", h.LeaveGroup)
+
+		// 参加申請（参加承認制のグループ）
+		authed.GET("/groups/:group_id/join-requests", h.ListJoinRequests, adminOnly)
+		authed.PUT("/groups/:group_id/join-requests/:user_id/approve", h.ApproveJoinRequest, adminOnly)
+		authed.PUT("/groups/:group_id/join-requests/:user_id/reject", h.RejectJoinRequest, adminOnly)
 
 		authed.POST("/groups/:group_id/trades", h.CreateTrade)
 		authed.GET("/groups/:group_id/trades", h.ListTrades)
 		authed.DELETE("/groups/:group_id/trades/:trade_id", h.DeleteTrade)
 		authed.PUT("/groups/:group_id/trades/:trade_id/accept", h.AcceptTrade)
+		authed.PUT("/groups/:group_id/trades/:trade_id/cancel-acceptance", h.CancelAcceptance)
 		authed.PUT("/trades/:trade_id/paid", h.MarkPaid)
 		authed.PUT("/groups/:group_id/trades/:trade_id/details", h.UpdateTradeDetails)
+		authed.GET("/groups/:group_id/trades/:trade_id/history", h.ListTradeHistory)
+
+		// 承認制グループの応募（一覧・承認・却下は作成者/ADMIN、取り下げは応募者本人）
+		authed.GET("/groups/:group_id/trades/:trade_id/applications", h.ListTradeApplications)
+		authed.PUT("/groups/:group_id/trades/:trade_id/applications/:application_id/approve", h.ApproveTradeApplication)
+		authed.PUT("/groups/:group_id/trades/:trade_id/applications/:application_id/reject", h.RejectTradeApplication)
+		authed.DELETE("/groups/:group_id/trades/:trade_id/applications/:application_id", h.WithdrawTradeApplication)
 	}
 
 	// 画面表示 (HTML)
@@ -46,20 +72,25 @@ func SetupRoutes(e *echo.Echo, h *handler.Handler) {
 		return h.ShowHomeEntry(c)
 	})
 
-	e.GET("/groups/:group_id", h.ShowGroupBoard)
+	// メトリクス（認証キャッシュのヒット率など）
+	if os.Getenv("METRICS_ENABLED") == "1" {
+		e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+		// outbox の状態は DEBUG_TOKEN を設定したときだけ、そのトークンを付けたリクエストに返す
+		if token := strings.TrimSpace(os.Getenv("DEBUG_TOKEN")); token != "" {
+			e.GET("/debug/outbox", h.OutboxStatus, handler.DebugTokenMiddleware(token))
+		}
+	}
+
 	e.POST("/callback", h.Webhook)
 
 	// 登録関連
 	e.GET("/register", h.ShowRegister)
 
-	// ホーム画面
-	e.GET("/home", func(c echo.Context) error {
-		// まだ user_id が付いてないなら入口へ
-		if c.QueryParam("user_id") == "" {
-			return h.ShowHomeEntry(c)
-		}
-		return h.ShowHome(c)
-	})
+	// セッション Cookie が必要な画面（無ければ入口で LIFF ログインしてから戻ってくる）
+	viewAuth := h.ViewSessionMiddleware()
 
-	e.GET("/groups/:group_id/trades/:trade_id", h.ShowTradeDetail)
+	// ホーム画面
+	e.GET("/home", h.ShowHome, viewAuth)
+	e.GET("/groups/:group_id", h.ShowGroupBoard, viewAuth)
+	e.GET("/groups/:group_id/trades/:trade_id", h.ShowTradeDetail, viewAuth)
 }
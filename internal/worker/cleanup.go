@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"shift-change-app/internal/database"
+	"time"
+)
+
+// 古い記録を消す間隔と、Webhook イベントの記録を残しておく期間
+// LINE の再送は数時間以内なので、それより十分長く残す
+const (
+	cleanupInterval        = time.Hour
+	webhookEventsRetention = 7 * 24 * time.Hour
+)
+
+// RunCleanup は ctx が終わるまで一定間隔で古い記録を消す（何台で動かしても結果は同じ）
+func RunCleanup(ctx context.Context, queries *database.Queries) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := queries.DeleteWebhookEventsBefore(ctx, time.Now().Add(-webhookEventsRetention))
+			if err != nil {
+				log.Println("[worker] failed to clean up webhook events:", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("[worker] deleted %d webhook event(s)", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"shift-change-app/internal/database"
+)
+
+// advisory lock のキー（アプリ内で重ならない値にする）
+const (
+	ReminderLockKey int64 = 0x53484654_0001 // "SHFT" + 1
+)
+
+// RunExclusive は Postgres の advisory lock を取れたときだけ fn を実行する
+// 複数台で動かしても同じキーの処理は同時に1台だけが実行する。ロックを取れなければ ran=false を返す
+func RunExclusive(ctx context.Context, db *sql.DB, key int64, fn func(ctx context.Context) error) (ran bool, err error) {
+	// セッション単位のロックなので、取得から解放まで同じ接続を使う
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	q := database.New(conn)
+	locked, err := q.TryAdvisoryLock(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+
+	defer func() {
+		// ctx が終わっていても解放できるよう切り離した context を使う
+		if _, err := q.AdvisoryUnlock(context.Background(), key); err != nil {
+			log.Printf("[worker] failed to release advisory lock %d: %v", key, err)
+			// ロックを持ったままプールに戻さないよう接続を捨てる
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	return true, fn(ctx)
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/handler"
+	"time"
+)
+
+// REMINDER_INTERVAL が未設定・不正なときのチェック間隔
+const defaultReminderInterval = 5 * time.Minute
+
+// RunReminders は ctx が終わるまで一定間隔で未成立シフトをチェックし、グループごとの段階に応じてリマインドを積む
+// advisory lock を取れたインスタンスだけが実行するので、複数台で動かしても重複して積まない
+func RunReminders(ctx context.Context, db *sql.DB, queries *database.Queries) {
+	ticker := time.NewTicker(reminderIntervalFromEnv())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkReminders(ctx, db, queries)
+		}
+	}
+}
+
+func checkReminders(ctx context.Context, db *sql.DB, queries *database.Queries) {
+	ran, err := RunExclusive(ctx, db, ReminderLockKey, func(ctx context.Context) error {
+		return handler.SendDueReminders(ctx, db, queries, time.Now())
+	})
+	if err != nil {
+		log.Println("Error checking shifts:", err)
+		return
+	}
+	if !ran {
+		log.Println("[worker] reminder check is running on another instance; skipped")
+	}
+}
+
+// チェック間隔（例: REMINDER_INTERVAL=1m）
+func reminderIntervalFromEnv() time.Duration {
+	v := os.Getenv("REMINDER_INTERVAL")
+	if v == "" {
+		return defaultReminderInterval
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("[WARN] invalid REMINDER_INTERVAL %q; using %s", v, defaultReminderInterval)
+		return defaultReminderInterval
+	}
+	return d
+}
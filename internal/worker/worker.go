@@ -0,0 +1,36 @@
+// Package worker は API サーバーとは別に動かせるバックグラウンド処理（リマインド、LINE 通知の送信）をまとめる
+// cmd/worker から単体で、または RUN_WORKERS が有効な cmd/api から起動する
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"shift-change-app/internal/database"
+	"shift-change-app/internal/outbox"
+	"sync"
+)
+
+// Run は ctx が終わるまでリマインド・通知の送信・古い記録の削除を動かし、全て止まってから戻る
+func Run(ctx context.Context, db *sql.DB, queries *database.Queries, sender outbox.Sender) {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		RunReminders(ctx, db, queries)
+	}()
+	go func() {
+		defer wg.Done()
+		outbox.NewWorker(queries, sender).Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		RunCleanup(ctx, queries)
+	}()
+	wg.Wait()
+}
+
+// EnabledInAPI は API サーバーの中でもバックグラウンド処理を動かすか（RUN_WORKERS=0 で無効、既定は有効）
+func EnabledInAPI() bool {
+	return os.Getenv("RUN_WORKERS") != "0"
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS shift_trade_events;
+
+ALTER TABLE shift_trades
+DROP CONSTRAINT IF EXISTS shift_trades_status_check;
+
+UPDATE shift_trades SET status = 'FILLED' WHERE status = 'COMPLETED';
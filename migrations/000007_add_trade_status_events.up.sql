@@ -0,0 +1,21 @@
+-- 支払い済みの成立トレードは COMPLETED に移す
+UPDATE shift_trades SET status = 'COMPLETED' WHERE status = 'FILLED' AND is_paid = TRUE;
+
+-- 想定外の値が入っていれば CLOSED に寄せる
+UPDATE shift_trades SET status = 'CLOSED' WHERE status NOT IN ('OPEN', 'FILLED', 'COMPLETED', 'CLOSED');
+
+ALTER TABLE shift_trades
+    ADD CONSTRAINT shift_trades_status_check CHECK (status IN ('OPEN', 'FILLED', 'COMPLETED', 'CLOSED'));
+
+-- シフト交代リクエストの状態遷移履歴
+CREATE TABLE shift_trade_events (
+                                    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                    trade_id UUID NOT NULL REFERENCES shift_trades(id) ON DELETE CASCADE,
+                                    from_status VARCHAR(20), -- 作成時は NULL
+                                    to_status VARCHAR(20) NOT NULL,
+                                    actor_id UUID REFERENCES users(id), -- システムによる遷移は NULL
+                                    reason VARCHAR(50) NOT NULL DEFAULT '',
+                                    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_trade_events_trade ON shift_trade_events(trade_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE shift_trades
+DROP COLUMN cancel_requested_at;
@@ -0,0 +1,3 @@
+-- 作成者が引き受けの取り消しを依頼した日時（引き受け者の同意待ち）
+ALTER TABLE shift_trades
+    ADD COLUMN cancel_requested_at TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS trade_applications;
+
+ALTER TABLE job_groups
+DROP CONSTRAINT IF EXISTS job_groups_accept_mode_check;
+
+ALTER TABLE job_groups
+DROP COLUMN accept_mode;
@@ -0,0 +1,21 @@
+-- 引き受け方式（FIRST_COME: 早い者勝ち / APPROVAL: 応募から作成者・管理者が選ぶ）
+ALTER TABLE job_groups
+    ADD COLUMN accept_mode VARCHAR(20) NOT NULL DEFAULT 'FIRST_COME';
+
+ALTER TABLE job_groups
+    ADD CONSTRAINT job_groups_accept_mode_check CHECK (accept_mode IN ('FIRST_COME', 'APPROVAL'));
+
+-- シフト交代リクエストへの応募（承認制グループ用）
+CREATE TABLE trade_applications (
+                                    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                    trade_id UUID NOT NULL REFERENCES shift_trades(id) ON DELETE CASCADE,
+                                    applicant_id UUID NOT NULL REFERENCES users(id),
+                                    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+                                    decided_by UUID REFERENCES users(id),
+                                    decided_at TIMESTAMPTZ,
+                                    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                    UNIQUE (trade_id, applicant_id),
+                                    CONSTRAINT trade_applications_status_check CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED', 'WITHDRAWN', 'CANCELLED'))
+);
+
+CREATE INDEX idx_trade_applications_trade_status ON trade_applications(trade_id, status);
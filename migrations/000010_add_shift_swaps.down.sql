@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS shift_trade_counter_shifts;
+
+DROP INDEX IF EXISTS idx_trades_swap_parent;
+
+ALTER TABLE shift_trades
+DROP COLUMN swap_parent_id;
+
+ALTER TABLE shift_trades
+DROP CONSTRAINT IF EXISTS shift_trades_trade_type_check;
+
+ALTER TABLE shift_trades
+DROP COLUMN trade_type;
@@ -0,0 +1,23 @@
+-- 募集の種類（GIVEAWAY: 代わってほしい / SWAP: 交換）
+ALTER TABLE shift_trades
+    ADD COLUMN trade_type VARCHAR(20) NOT NULL DEFAULT 'GIVEAWAY';
+
+ALTER TABLE shift_trades
+    ADD CONSTRAINT shift_trades_trade_type_check CHECK (trade_type IN ('GIVEAWAY', 'SWAP'));
+
+-- 交換成立時に作られる「お返し」の募集は元の募集を指す
+ALTER TABLE shift_trades
+    ADD COLUMN swap_parent_id UUID REFERENCES shift_trades(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_trades_swap_parent ON shift_trades(swap_parent_id);
+
+-- 交換募集で引き受け者が代わりに差し出すシフト（作成者が入る）
+CREATE TABLE shift_trade_counter_shifts (
+                                            id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                            trade_id UUID NOT NULL REFERENCES shift_trades(id) ON DELETE CASCADE,
+                                            shift_start_at TIMESTAMPTZ NOT NULL,
+                                            shift_end_at TIMESTAMPTZ NOT NULL,
+                                            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_counter_shifts_trade ON shift_trade_counter_shifts(trade_id);
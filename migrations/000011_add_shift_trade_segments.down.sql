@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS shift_trade_segments;
+
+ALTER TABLE shift_trades
+DROP CONSTRAINT IF EXISTS shift_trades_segment_minutes_check;
+
+ALTER TABLE shift_trades
+DROP COLUMN segment_minutes;
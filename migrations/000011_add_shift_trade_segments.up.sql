@@ -0,0 +1,20 @@
+-- 分割募集の1枠の長さ（分）。0 は分割しない
+ALTER TABLE shift_trades
+    ADD COLUMN segment_minutes INT NOT NULL DEFAULT 0;
+
+ALTER TABLE shift_trades
+    ADD CONSTRAINT shift_trades_segment_minutes_check CHECK (segment_minutes >= 0);
+
+-- 分割募集の枠（枠ごとに別のメンバーが引き受けられる）
+CREATE TABLE shift_trade_segments (
+                                      id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                      trade_id UUID NOT NULL REFERENCES shift_trades(id) ON DELETE CASCADE,
+                                      segment_start_at TIMESTAMPTZ NOT NULL,
+                                      segment_end_at TIMESTAMPTZ NOT NULL,
+                                      acceptor_id UUID REFERENCES users(id),
+                                      accepted_at TIMESTAMPTZ,
+                                      created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                      UNIQUE (trade_id, segment_start_at)
+);
+
+CREATE INDEX idx_trade_segments_acceptor ON shift_trade_segments(acceptor_id);
@@ -0,0 +1,7 @@
+ALTER TABLE job_groups
+DROP CONSTRAINT IF EXISTS job_groups_invitation_max_uses_check;
+
+ALTER TABLE job_groups
+DROP COLUMN invitation_use_count,
+DROP COLUMN invitation_max_uses,
+DROP COLUMN invitation_expires_at;
@@ -0,0 +1,8 @@
+-- 招待コードの有効期限と利用回数の上限（NULL は無制限）
+ALTER TABLE job_groups
+    ADD COLUMN invitation_expires_at TIMESTAMPTZ,
+    ADD COLUMN invitation_max_uses INT,
+    ADD COLUMN invitation_use_count INT NOT NULL DEFAULT 0;
+
+ALTER TABLE job_groups
+    ADD CONSTRAINT job_groups_invitation_max_uses_check CHECK (invitation_max_uses IS NULL OR invitation_max_uses > 0);
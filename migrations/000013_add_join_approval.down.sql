@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_group_members_group_status;
+
+-- 承認待ちの所属は取り消す
+DELETE FROM group_members WHERE status = 'PENDING';
+
+ALTER TABLE group_members
+    DROP CONSTRAINT IF EXISTS group_members_status_check,
+    DROP COLUMN IF EXISTS approved_by,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE job_groups
+    DROP COLUMN IF EXISTS require_join_approval;
@@ -0,0 +1,13 @@
+-- 参加承認制（true のとき招待コードでの参加は ADMIN の承認待ちになる）
+ALTER TABLE job_groups
+    ADD COLUMN require_join_approval BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 所属の状態（PENDING: 承認待ち / ACTIVE: メンバー）
+ALTER TABLE group_members
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    ADD COLUMN approved_by UUID REFERENCES users(id);
+
+ALTER TABLE group_members
+    ADD CONSTRAINT group_members_status_check CHECK (status IN ('PENDING', 'ACTIVE'));
+
+CREATE INDEX idx_group_members_group_status ON group_members(group_id, status);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS shift_trade_reminders;
+
+ALTER TABLE job_groups
+    DROP COLUMN IF EXISTS reminder_offsets_minutes;
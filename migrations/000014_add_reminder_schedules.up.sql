@@ -0,0 +1,11 @@
+-- 未成立シフトのリマインドを送るタイミング（開始の何分前か）。既定は従来どおり5時間前のみ
+ALTER TABLE job_groups
+    ADD COLUMN reminder_offsets_minutes INT[] NOT NULL DEFAULT '{300}';
+
+-- 送信済みのリマインド（募集ごと・段階ごとに1回だけ送る）
+CREATE TABLE shift_trade_reminders (
+                                       trade_id UUID NOT NULL REFERENCES shift_trades(id) ON DELETE CASCADE,
+                                       offset_minutes INT NOT NULL,
+                                       sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                       PRIMARY KEY (trade_id, offset_minutes)
+);
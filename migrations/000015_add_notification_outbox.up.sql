@@ -0,0 +1,21 @@
+-- 送信待ちの LINE 通知（状態の変更と同じトランザクションで積み、ワーカーが送る）
+CREATE TABLE notification_outbox (
+                                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                     kind VARCHAR(20) NOT NULL,
+                                     recipients TEXT[] NOT NULL,
+                                     messages JSONB NOT NULL,
+                                     status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+                                     attempts INT NOT NULL DEFAULT 0,
+                                     max_attempts INT NOT NULL DEFAULT 8,
+                                     next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     locked_until TIMESTAMPTZ,
+                                     last_error TEXT,
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                     sent_at TIMESTAMPTZ,
+                                     CONSTRAINT notification_outbox_kind_check CHECK (kind IN ('PUSH', 'MULTICAST')),
+                                     CONSTRAINT notification_outbox_status_check CHECK (status IN ('PENDING', 'SENT', 'DEAD'))
+);
+
+CREATE INDEX idx_notification_outbox_due ON notification_outbox(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX idx_notification_outbox_status ON notification_outbox(status, updated_at);
@@ -0,0 +1,12 @@
+-- 受け取った Webhook イベント（webhookEventId で重複処理を防ぐ）
+CREATE TABLE webhook_events (
+                                webhook_event_id TEXT PRIMARY KEY,
+                                event_type VARCHAR(30) NOT NULL,
+                                is_redelivery BOOLEAN NOT NULL DEFAULT FALSE,
+                                attempts INT NOT NULL DEFAULT 1,
+                                locked_until TIMESTAMPTZ NOT NULL,
+                                processed_at TIMESTAMPTZ,
+                                received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_events_received_at ON webhook_events(received_at);
@@ -0,0 +1,3 @@
+-- bot をブロックしている（unfollow）ユーザーには通知を送らない
+ALTER TABLE users
+    ADD COLUMN bot_reachable BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,8 @@
+ALTER TABLE job_groups
+DROP CONSTRAINT IF EXISTS job_groups_notify_mode_check;
+
+ALTER TABLE job_groups
+DROP COLUMN notify_mode;
+
+ALTER TABLE job_groups
+DROP COLUMN line_chat_id;
@@ -0,0 +1,10 @@
+-- 連携した LINE のグループ・複数人トークの ID（bot を招待して /link で連携する）
+ALTER TABLE job_groups
+    ADD COLUMN line_chat_id TEXT UNIQUE;
+
+-- 通知の送り方（INDIVIDUAL: メンバー1人ずつに送る / CHAT: 連携したトークに投稿する）
+ALTER TABLE job_groups
+    ADD COLUMN notify_mode VARCHAR(20) NOT NULL DEFAULT 'INDIVIDUAL';
+
+ALTER TABLE job_groups
+    ADD CONSTRAINT job_groups_notify_mode_check CHECK (notify_mode IN ('INDIVIDUAL', 'CHAT'));
@@ -0,0 +1,3 @@
+-- LINE API の X-Line-Retry-Key に使うキー（再送しても同じ通知が二重に届かないよう、通知ごとに固定する）
+ALTER TABLE notification_outbox
+    ADD COLUMN retry_key UUID NOT NULL DEFAULT uuid_generate_v4();